@@ -6,6 +6,17 @@ import { logger } from "../../shared/logger";
 import { withSpan } from "../../shared/tracing";
 import { EmailJobRequest } from "../../schemas/email.schema";
 
+const EMAIL_TASKS_QUEUE = "email_tasks";
+
+// Jobs go straight to the queue of their priority lane, so high-priority mail
+// never waits behind a bulk backlog; normal priority uses the main queue
+function laneQueue(priority: EmailJobRequest["priority"]): string {
+  if (!priority || priority === "normal") {
+    return EMAIL_TASKS_QUEUE;
+  }
+  return `${EMAIL_TASKS_QUEUE}.${priority}`;
+}
+
 export interface SubmitEmailUseCaseResult {
   jobId: string;
   status: string;
@@ -20,7 +31,8 @@ export class SubmitEmailUseCase {
 
   async execute(request: EmailJobRequest): Promise<SubmitEmailUseCaseResult> {
     return withSpan("email.submit", async (span) => {
-      const { to, subject, body } = request;
+      const { to, subject, body, priority } = request;
+      const queue = laneQueue(priority);
       const jobId = uuidv4();
       const createdAt = new Date().toISOString();
 
@@ -29,6 +41,7 @@ export class SubmitEmailUseCase {
         "email.subject": subject,
         "email.job_id": jobId,
         "email.created_at": createdAt,
+        "email.priority": priority ?? "normal",
       });
 
       const emailMessage = {
@@ -37,6 +50,7 @@ export class SubmitEmailUseCase {
           to,
           subject,
           body,
+          priority,
           created_at: createdAt,
           status: "pending",
           retry_count: 0,
@@ -83,11 +97,11 @@ export class SubmitEmailUseCase {
       await withSpan(
         "broker.sendMessage",
         async () => {
-          await this.messageBroker.sendMessage("email_tasks", emailMessage);
+          await this.messageBroker.sendMessage(queue, emailMessage);
         },
         {
           "broker.operation": "sendMessage",
-          "broker.queue": "email_queue",
+          "broker.queue": queue,
           "job.id": jobId,
         }
       );
@@ -1,7 +1,9 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
+	"time"
 )
 
 // DomainError represents a domain-specific error
@@ -9,6 +11,16 @@ type DomainError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Cause   error  `json:"cause,omitempty"`
+
+	// Permanent marks failures that will not succeed on retry
+	Permanent bool `json:"permanent,omitempty"`
+
+	// SMTP reply details, set when the failure came from the mail server
+	SMTPCode     int    `json:"smtp_code,omitempty"`
+	EnhancedCode string `json:"enhanced_code,omitempty"`
+
+	// HTTP status, set when the failure came from an email provider API
+	HTTPStatus int `json:"http_status,omitempty"`
 }
 
 func (e *DomainError) Error() string {
@@ -36,11 +48,13 @@ const (
 	SMTPErrorCode     = "SMTP_ERROR"
 	
 	// Business logic errors
-	JobProcessingErrorCode = "JOB_PROCESSING_ERROR"
-	RetryExceededErrorCode = "RETRY_EXCEEDED_ERROR"
+	JobProcessingErrorCode     = "JOB_PROCESSING_ERROR"
+	RetryExceededErrorCode     = "RETRY_EXCEEDED_ERROR"
+	JobExpiredErrorCode        = "JOB_EXPIRED_ERROR"
+	InvalidTransitionErrorCode = "INVALID_TRANSITION_ERROR"
 	
 	// System errors
-	ShutdownErrorCode = "SHUTDOWN_ERROR"
+	ShutdownErrorCode    = "SHUTDOWN_ERROR"
 	HealthCheckErrorCode = "HEALTH_CHECK_ERROR"
 )
 
@@ -122,6 +136,42 @@ func NewSMTPErrorWithCause(message string, cause error) *DomainError {
 	}
 }
 
+// NewSMTPReplyError creates an SMTP error from a server reply. 5xx replies are
+// permanent, 4xx replies are transient; an RFC 3463 enhanced status code takes
+// precedence over the basic reply code when present.
+func NewSMTPReplyError(message string, code int, enhancedCode string, cause error) *DomainError {
+	class := code / 100
+	if enhancedCode != "" {
+		class = int(enhancedCode[0] - '0')
+	}
+
+	return &DomainError{
+		Code:         SMTPErrorCode,
+		Message:      message,
+		Cause:        cause,
+		Permanent:    class == 5,
+		SMTPCode:     code,
+		EnhancedCode: enhancedCode,
+	}
+}
+
+// NewEmailProviderError creates an SMTP error from an email provider API
+// response, so that delivery failures share retry policies whatever the
+// transport. Client errors are permanent, except for timeouts and rate limits;
+// server errors are transient.
+func NewEmailProviderError(message string, status int, cause error) *DomainError {
+	permanent := status >= 400 && status < 500 &&
+		status != 408 && status != 425 && status != 429
+
+	return &DomainError{
+		Code:       SMTPErrorCode,
+		Message:    message,
+		Cause:      cause,
+		Permanent:  permanent,
+		HTTPStatus: status,
+	}
+}
+
 // Business logic errors
 func NewJobProcessingError(message string) *DomainError {
 	return &DomainError{
@@ -145,6 +195,23 @@ func NewRetryExceededError(jobID string, maxRetries int) *DomainError {
 	}
 }
 
+// NewJobExpiredError reports a job whose deadline passed before it was sent
+func NewJobExpiredError(jobID string, expiresAt time.Time) *DomainError {
+	return &DomainError{
+		Code:    JobExpiredErrorCode,
+		Message: fmt.Sprintf("job %s expired at %s", jobID, expiresAt.UTC().Format(time.RFC3339)),
+	}
+}
+
+// NewInvalidTransitionError reports a status change the job's current status does not allow
+func NewInvalidTransitionError(jobID, from, to string) *DomainError {
+	return &DomainError{
+		Code:      InvalidTransitionErrorCode,
+		Message:   fmt.Sprintf("job %s cannot move from %s to %s", jobID, from, to),
+		Permanent: true,
+	}
+}
+
 // System errors
 func NewShutdownError(message string) *DomainError {
 	return &DomainError{
@@ -176,28 +243,29 @@ func NewHealthCheckErrorWithCause(message string, cause error) *DomainError {
 	}
 }
 
-// Helper functions for error checking
+// Helper functions for error checking. Each looks through wrapped errors and
+// goes by the outermost DomainError it finds.
 func IsDomainError(err error) bool {
-	_, ok := err.(*DomainError)
+	_, ok := asDomainError(err)
 	return ok
 }
 
 func IsValidationError(err error) bool {
-	if domainErr, ok := err.(*DomainError); ok {
+	if domainErr, ok := asDomainError(err); ok {
 		return domainErr.Code == ValidationErrorCode
 	}
 	return false
 }
 
 func IsConfigError(err error) bool {
-	if domainErr, ok := err.(*DomainError); ok {
+	if domainErr, ok := asDomainError(err); ok {
 		return domainErr.Code == ConfigErrorCode
 	}
 	return false
 }
 
 func IsInfrastructureError(err error) bool {
-	if domainErr, ok := err.(*DomainError); ok {
+	if domainErr, ok := asDomainError(err); ok {
 		return domainErr.Code == RedisErrorCode || 
 			   domainErr.Code == RabbitMQErrorCode || 
 			   domainErr.Code == SMTPErrorCode
@@ -206,11 +274,53 @@ func IsInfrastructureError(err error) bool {
 }
 
 func IsRetryableError(err error) bool {
-	if domainErr, ok := err.(*DomainError); ok {
+	if domainErr, ok := asDomainError(err); ok {
+		if domainErr.Permanent {
+			return false
+		}
 		return domainErr.Code == RedisErrorCode || 
 			   domainErr.Code == RabbitMQErrorCode || 
 			   domainErr.Code == SMTPErrorCode ||
 			   domainErr.Code == JobProcessingErrorCode
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// IsJobExpiredError reports whether err is a job whose deadline passed unsent
+func IsJobExpiredError(err error) bool {
+	if domainErr, ok := asDomainError(err); ok {
+		return domainErr.Code == JobExpiredErrorCode
+	}
+	return false
+}
+
+// IsInvalidTransitionError reports whether err is a disallowed status change
+func IsInvalidTransitionError(err error) bool {
+	if domainErr, ok := asDomainError(err); ok {
+		return domainErr.Code == InvalidTransitionErrorCode
+	}
+	return false
+}
+
+// IsPermanentError reports whether err is a failure that will not succeed on retry
+func IsPermanentError(err error) bool {
+	if domainErr, ok := asDomainError(err); ok {
+		return domainErr.Permanent
+	}
+	return false
+}
+
+// ErrorCode returns the domain error code of err, or an empty string for other errors
+func ErrorCode(err error) string {
+	if domainErr, ok := asDomainError(err); ok {
+		return domainErr.Code
+	}
+	return ""
+}
+
+// asDomainError returns the first DomainError in err's chain
+func asDomainError(err error) (*DomainError, bool) {
+	var domainErr *DomainError
+	ok := stderrors.As(err, &domainErr)
+	return domainErr, ok
+}
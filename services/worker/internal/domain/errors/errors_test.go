@@ -0,0 +1,109 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewSMTPReplyError(t *testing.T) {
+	tests := []struct {
+		code         int
+		enhancedCode string
+		permanent    bool
+	}{
+		{code: 550, enhancedCode: "5.1.1", permanent: true},
+		{code: 554, permanent: true},
+		{code: 553, enhancedCode: "5.6.7", permanent: true},
+		{code: 451, enhancedCode: "4.7.1"},
+		{code: 421},
+		{code: 452, enhancedCode: "4.2.2"},
+		// The enhanced code is more precise than the reply code
+		{code: 550, enhancedCode: "4.2.2"},
+		{code: 450, enhancedCode: "5.7.1", permanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.code, tt.enhancedCode), func(t *testing.T) {
+			err := NewSMTPReplyError("send failed", tt.code, tt.enhancedCode, nil)
+			if err.Permanent != tt.permanent || IsPermanentError(err) != tt.permanent {
+				t.Errorf("permanent = %v, want %v", err.Permanent, tt.permanent)
+			}
+			if IsRetryableError(err) == tt.permanent {
+				t.Errorf("retryable = %v, want %v", !tt.permanent, !tt.permanent)
+			}
+			if err.SMTPCode != tt.code || err.EnhancedCode != tt.enhancedCode {
+				t.Errorf("recorded %d %q, want %d %q", err.SMTPCode, err.EnhancedCode, tt.code, tt.enhancedCode)
+			}
+		})
+	}
+}
+
+func TestNewEmailProviderError(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: 400, permanent: true},
+		{status: 401, permanent: true},
+		{status: 422, permanent: true},
+		{status: 408},
+		{status: 425},
+		{status: 429},
+		{status: 500},
+		{status: 503},
+	}
+
+	for _, tt := range tests {
+		err := NewEmailProviderError("send failed", tt.status, nil)
+		if IsPermanentError(err) != tt.permanent {
+			t.Errorf("status %d permanent = %v, want %v", tt.status, !tt.permanent, tt.permanent)
+		}
+		if ErrorCode(err) != SMTPErrorCode {
+			t.Errorf("status %d has code %s, want %s", tt.status, ErrorCode(err), SMTPErrorCode)
+		}
+	}
+}
+
+func TestHelpersLookThroughWrapping(t *testing.T) {
+	permanent := NewSMTPReplyError("send failed", 550, "5.1.1", nil)
+	wrapped := fmt.Errorf("mx.example.com: %w", permanent)
+
+	if !IsDomainError(wrapped) || ErrorCode(wrapped) != SMTPErrorCode {
+		t.Errorf("wrapped error has code %q, want %s", ErrorCode(wrapped), SMTPErrorCode)
+	}
+	if !IsPermanentError(wrapped) || IsRetryableError(wrapped) {
+		t.Error("wrapped permanent error is retryable")
+	}
+	if !IsInfrastructureError(wrapped) {
+		t.Error("wrapped SMTP error is not an infrastructure error")
+	}
+
+	tests := []struct {
+		err   error
+		check func(error) bool
+	}{
+		{err: NewValidationError("bad"), check: IsValidationError},
+		{err: NewConfigError("bad"), check: IsConfigError},
+		{err: NewRedisError("down"), check: IsRetryableError},
+		{err: NewJobExpiredError("job-1", time.Now()), check: IsJobExpiredError},
+		{err: NewInvalidTransitionError("job-1", "completed", "processing"), check: IsInvalidTransitionError},
+	}
+	for _, tt := range tests {
+		if err := fmt.Errorf("context: %w", tt.err); !tt.check(err) {
+			t.Errorf("%v is not recognized once wrapped", tt.err)
+		}
+	}
+
+	// The outermost domain error decides
+	outer := NewJobProcessingErrorWithCause("job processing interrupted", permanent)
+	if ErrorCode(outer) != JobProcessingErrorCode || IsPermanentError(outer) {
+		t.Errorf("outer error classified as %s permanent=%v", ErrorCode(outer), IsPermanentError(outer))
+	}
+
+	plain := stderrors.New("boom")
+	if IsDomainError(plain) || ErrorCode(plain) != "" || IsRetryableError(plain) || IsPermanentError(nil) {
+		t.Error("a plain error is classified as a domain error")
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+)
+
+// Address is a parsed RFC 5322 mailbox. Domain is always in ASCII form, with
+// internationalized labels converted to punycode, so Addr can go straight into
+// the SMTP envelope.
+type Address struct {
+	Name      string
+	LocalPart string
+	Domain    string
+}
+
+// ParseAddress parses a single mailbox such as "jane@example.com" or
+// "Jane Doe <jane@example.com>". Display names and local parts may contain
+// UTF-8 (RFC 6532); domains may be internationalized.
+func ParseAddress(address string) (*Address, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid email address", address)
+	}
+
+	at := strings.LastIndexByte(parsed.Address, '@')
+	localPart, domain := parsed.Address[:at], parsed.Address[at+1:]
+
+	// RFC 5321 section 4.5.3.1 limits
+	if len(localPart) > 64 {
+		return nil, fmt.Errorf("local part of %q is longer than 64 characters", address)
+	}
+	if !utf8.ValidString(localPart) {
+		return nil, fmt.Errorf("local part of %q is not valid UTF-8", address)
+	}
+
+	if strings.HasPrefix(domain, "[") {
+		if err := validateDomainLiteral(domain); err != nil {
+			return nil, err
+		}
+	} else if domain, err = domainToASCII(domain); err != nil {
+		return nil, err
+	}
+
+	a := &Address{Name: parsed.Name, LocalPart: localPart, Domain: domain}
+	if len(a.Addr()) > 254 {
+		return nil, fmt.Errorf("%q is longer than 254 characters", address)
+	}
+	return a, nil
+}
+
+// validateDomainLiteral checks an address literal such as [192.0.2.1] or
+// [IPv6:2001:db8::1]
+func validateDomainLiteral(literal string) error {
+	value := strings.TrimSuffix(strings.TrimPrefix(literal, "["), "]")
+
+	if v6, ok := strings.CutPrefix(value, "IPv6:"); ok {
+		if ip, err := netip.ParseAddr(v6); err == nil && ip.Is6() {
+			return nil
+		}
+	} else if ip, err := netip.ParseAddr(value); err == nil && ip.Is4() {
+		return nil
+	}
+	return fmt.Errorf("invalid address literal %s", literal)
+}
+
+// Addr returns the addr-spec, quoting the local part if it needs it
+func (a *Address) Addr() string {
+	formatted := (&mail.Address{Address: a.LocalPart + "@" + a.Domain}).String()
+	return strings.TrimSuffix(strings.TrimPrefix(formatted, "<"), ">")
+}
+
+// RequiresSMTPUTF8 reports whether the address can only be delivered through
+// a server that supports SMTPUTF8 (RFC 6531); only non-ASCII local parts do,
+// since domains are converted to punycode
+func (a *Address) RequiresSMTPUTF8() bool {
+	for i := 0; i < len(a.LocalPart); i++ {
+		if a.LocalPart[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses every address in the list
+func (l AddressList) Parse() ([]*Address, error) {
+	addresses := make([]*Address, len(l))
+	for i, address := range l {
+		parsed, err := ParseAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		addresses[i] = parsed
+	}
+	return addresses, nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		addr     string
+		smtputf8 bool
+	}{
+		{input: "jane@example.com", addr: "jane@example.com"},
+		{input: "Jane Doe <jane@example.com>", name: "Jane Doe", addr: "jane@example.com"},
+		{input: `"Doe, Jane" <jane@example.com>`, name: "Doe, Jane", addr: "jane@example.com"},
+		{input: "=?utf-8?q?J=C3=B6rg?= <joerg@example.com>", name: "Jörg", addr: "joerg@example.com"},
+		{input: "Jörg <joerg@example.com>", name: "Jörg", addr: "joerg@example.com"},
+		{input: "jane@Example.COM", addr: "jane@example.com"},
+		{input: `"john doe"@example.com`, addr: `"john doe"@example.com`},
+		{input: "info@münchen.de", addr: "info@xn--mnchen-3ya.de"},
+		{input: "info@bücher.example", addr: "info@xn--bcher-kva.example"},
+		{input: "info@例え.jp", addr: "info@xn--r8jz45g.jp"},
+		{input: "δοκιμή@example.com", addr: "δοκιμή@example.com", smtputf8: true},
+		{input: "用户@例え.jp", addr: "用户@xn--r8jz45g.jp", smtputf8: true},
+		{input: "postmaster@[192.0.2.1]", addr: "postmaster@[192.0.2.1]"},
+		{input: "postmaster@[IPv6:2001:db8::1]", addr: "postmaster@[IPv6:2001:db8::1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			address, err := ParseAddress(tt.input)
+			if err != nil {
+				t.Fatalf("ParseAddress returned %v", err)
+			}
+			if address.Name != tt.name {
+				t.Errorf("Name = %q, want %q", address.Name, tt.name)
+			}
+			if address.Addr() != tt.addr {
+				t.Errorf("Addr() = %q, want %q", address.Addr(), tt.addr)
+			}
+			if address.RequiresSMTPUTF8() != tt.smtputf8 {
+				t.Errorf("RequiresSMTPUTF8() = %v, want %v", address.RequiresSMTPUTF8(), tt.smtputf8)
+			}
+		})
+	}
+}
+
+func TestParseAddressRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"jane",
+		"jane@",
+		"@example.com",
+		"jane@@example.com",
+		"Jane <jane@example.com",
+		"jane doe@example.com",
+		"jane@exa_mple.com",
+		"jane@example..com",
+		"jane@-example.com",
+		"jane@example-.com",
+		"jane@example.123",
+		"jane@" + strings.Repeat("a", 64) + ".com",
+		strings.Repeat("a", 65) + "@example.com",
+		"jane@" + strings.Repeat("abcdefghi.", 25) + "com",
+		"jane@exam♥ple.com",
+		"jane@[300.1.1.1]",
+		"jane@[2001:db8::1]",
+		"jane@[IPv6:192.0.2.1]",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if address, err := ParseAddress(input); err == nil {
+				t.Errorf("ParseAddress accepted %q as %q", input, address.Addr())
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Attachment is a file sent with an email. Small files carry their content
+// inline as base64; large ones reference a file in the worker's blob directory.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+
+	// Content is the base64-encoded file content
+	Content string `json:"content,omitempty"`
+	// BlobRef is a path relative to the blob directory, and Size its size in bytes
+	BlobRef string `json:"blob_ref,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+
+	// Disposition is "attachment" (default) or "inline"; inline parts can be
+	// referenced from the HTML body as cid:<content_id>
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// Attachment dispositions
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
+// AttachmentLimits bounds the attachments a single job may carry. Sizes are in
+// bytes of decoded content.
+type AttachmentLimits struct {
+	MaxCount      int
+	MaxInlineSize int64
+	MaxTotalSize  int64
+}
+
+// IsBlob returns true if the attachment content lives in the blob directory
+func (a *Attachment) IsBlob() bool {
+	return a.BlobRef != ""
+}
+
+// DecodedContent returns the inline content
+func (a *Attachment) DecodedContent() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(a.Content)
+}
+
+// validateAttachments checks that each attachment is well formed
+func validateAttachments(attachments []Attachment) error {
+	for i := range attachments {
+		if err := attachments[i].validate(); err != nil {
+			return &ValidationError{Message: fmt.Sprintf("attachments[%d]: %s", i, err.Error())}
+		}
+	}
+	return nil
+}
+
+// CheckAttachmentLimits checks the job's attachments against limits. It
+// expects a job that passed Validate.
+func (j *EmailJob) CheckAttachmentLimits(limits AttachmentLimits) error {
+	if len(j.Attachments) > limits.MaxCount {
+		return &ValidationError{Message: fmt.Sprintf("at most %d attachments are allowed", limits.MaxCount)}
+	}
+
+	var total int64
+	for i := range j.Attachments {
+		size, err := j.Attachments[i].size(limits.MaxInlineSize)
+		if err != nil {
+			return &ValidationError{Message: fmt.Sprintf("attachments[%d]: %s", i, err.Error())}
+		}
+		total += size
+	}
+
+	if total > limits.MaxTotalSize {
+		return &ValidationError{Message: fmt.Sprintf("attachments exceed the %d byte limit per job", limits.MaxTotalSize)}
+	}
+
+	return nil
+}
+
+// validate checks that a single attachment is well formed
+func (a *Attachment) validate() error {
+	if a.Filename == "" {
+		return fmt.Errorf("filename is required")
+	}
+	if strings.IndexFunc(a.Filename, unicode.IsControl) >= 0 {
+		return fmt.Errorf("filename contains control characters")
+	}
+
+	if a.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+			return fmt.Errorf("invalid content_type: %v", err)
+		}
+	}
+
+	switch a.Disposition {
+	case "", DispositionAttachment, DispositionInline:
+	default:
+		return fmt.Errorf("disposition must be attachment or inline")
+	}
+	if strings.ContainsAny(a.ContentID, "<>\r\n \t") {
+		return fmt.Errorf("content_id must not contain angle brackets or whitespace")
+	}
+
+	if (a.Content == "") == (a.BlobRef == "") {
+		return fmt.Errorf("exactly one of content or blob_ref is required")
+	}
+
+	if a.IsBlob() {
+		// Refs are resolved inside the blob directory and must not escape it
+		if !isLocalRef(a.BlobRef) {
+			return fmt.Errorf("blob_ref must be a clean relative path")
+		}
+		if a.Size <= 0 {
+			return fmt.Errorf("size is required for blob_ref attachments")
+		}
+	}
+
+	return nil
+}
+
+// size returns the attachment's content size, decoding inline content of at
+// most maxInline bytes to measure it
+func (a *Attachment) size(maxInline int64) (int64, error) {
+	if a.IsBlob() {
+		return a.Size, nil
+	}
+
+	// Check the encoded length first so oversized content is never decoded
+	if int64(base64.StdEncoding.DecodedLen(len(a.Content))) > maxInline+2 {
+		return 0, fmt.Errorf("inline content exceeds the %d byte limit", maxInline)
+	}
+	content, err := a.DecodedContent()
+	if err != nil {
+		return 0, fmt.Errorf("content is not valid base64")
+	}
+	if int64(len(content)) > maxInline {
+		return 0, fmt.Errorf("inline content exceeds the %d byte limit", maxInline)
+	}
+
+	return int64(len(content)), nil
+}
+
+// isLocalRef reports whether ref is a clean, slash-separated relative path that
+// stays within the directory it is resolved against
+func isLocalRef(ref string) bool {
+	if path.IsAbs(ref) || strings.Contains(ref, "\\") || path.Clean(ref) != ref {
+		return false
+	}
+	return ref != "." && ref != ".." && !strings.HasPrefix(ref, "../")
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// inlineAttachment returns an attachment carrying size bytes inline
+func inlineAttachment(name string, size int) Attachment {
+	content := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", size)))
+	return Attachment{Filename: name, Content: content}
+}
+
+func TestAttachmentValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		attachment Attachment
+		wantErr    string
+	}{
+		{name: "inline", attachment: inlineAttachment("a.txt", 10)},
+		{name: "blob", attachment: Attachment{Filename: "a.pdf", BlobRef: "jobs/1/a.pdf", Size: 100}},
+		{name: "inline disposition", attachment: Attachment{Filename: "logo.png", Content: "eA==", Disposition: DispositionInline, ContentID: "logo@example.com"}},
+		{name: "no filename", attachment: Attachment{Content: "eA=="}, wantErr: "filename is required"},
+		{name: "control character in filename", attachment: Attachment{Filename: "a\r\n.txt", Content: "eA=="}, wantErr: "control characters"},
+		{name: "bad content type", attachment: Attachment{Filename: "a", Content: "eA==", ContentType: "text/"}, wantErr: "invalid content_type"},
+		{name: "bad disposition", attachment: Attachment{Filename: "a", Content: "eA==", Disposition: "form-data"}, wantErr: "disposition"},
+		{name: "content ID with brackets", attachment: Attachment{Filename: "a", Content: "eA==", ContentID: "<logo>"}, wantErr: "content_id"},
+		{name: "content and blob", attachment: Attachment{Filename: "a", Content: "eA==", BlobRef: "a", Size: 1}, wantErr: "exactly one"},
+		{name: "neither content nor blob", attachment: Attachment{Filename: "a"}, wantErr: "exactly one"},
+		{name: "blob escaping the directory", attachment: Attachment{Filename: "a", BlobRef: "../etc/passwd", Size: 1}, wantErr: "clean relative path"},
+		{name: "absolute blob", attachment: Attachment{Filename: "a", BlobRef: "/etc/passwd", Size: 1}, wantErr: "clean relative path"},
+		{name: "blob without size", attachment: Attachment{Filename: "a", BlobRef: "a"}, wantErr: "size is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+			job.Attachments = []Attachment{tt.attachment}
+
+			err := job.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate returned %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate returned %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAttachmentLimits(t *testing.T) {
+	limits := AttachmentLimits{MaxCount: 3, MaxInlineSize: 100, MaxTotalSize: 250}
+
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		wantErr     string
+	}{
+		{name: "none"},
+		{name: "within limits", attachments: []Attachment{inlineAttachment("a", 100), inlineAttachment("b", 100), inlineAttachment("c", 50)}},
+		{name: "too many", attachments: []Attachment{inlineAttachment("a", 1), inlineAttachment("b", 1), inlineAttachment("c", 1), inlineAttachment("d", 1)}, wantErr: "at most 3 attachments"},
+		{name: "inline too large", attachments: []Attachment{inlineAttachment("a", 101)}, wantErr: "attachments[0]: inline content exceeds the 100 byte limit"},
+		{name: "inline far too large", attachments: []Attachment{inlineAttachment("a", 10000)}, wantErr: "inline content exceeds"},
+		{name: "invalid base64", attachments: []Attachment{{Filename: "a", Content: "not base64!"}}, wantErr: "not valid base64"},
+		{name: "total too large", attachments: []Attachment{inlineAttachment("a", 100), inlineAttachment("b", 100), inlineAttachment("c", 51)}, wantErr: "exceed the 250 byte limit"},
+		{name: "blobs count toward the total", attachments: []Attachment{inlineAttachment("a", 10), {Filename: "b", BlobRef: "b", Size: 241}}, wantErr: "exceed the 250 byte limit"},
+		{name: "blobs are not inline", attachments: []Attachment{{Filename: "b", BlobRef: "b", Size: 200}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+			job.Attachments = tt.attachments
+
+			err := job.CheckAttachmentLimits(limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckAttachmentLimits returned %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckAttachmentLimits returned %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Limits belong to the caller, so two configurations can coexist
+	job := NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+	job.Attachments = []Attachment{inlineAttachment("a", 150)}
+	if err := job.CheckAttachmentLimits(AttachmentLimits{MaxCount: 1, MaxInlineSize: 200, MaxTotalSize: 200}); err != nil {
+		t.Errorf("CheckAttachmentLimits returned %v under the larger limits", err)
+	}
+	if err := job.CheckAttachmentLimits(limits); err == nil {
+		t.Error("CheckAttachmentLimits accepted 150 bytes under a 100 byte inline limit")
+	}
+}
@@ -2,15 +2,39 @@ package models
 
 import (
 	"encoding/json"
+	"regexp"
 	"time"
 )
 
 // EmailJob represents an email processing job
 type EmailJob struct {
 	JobID       string            `json:"job_id"`
-	To          string            `json:"to"`
+	To          AddressList       `json:"to"`
+	Cc          AddressList       `json:"cc,omitempty"`
+	Bcc         AddressList       `json:"bcc,omitempty"`
+	ReplyTo     AddressList       `json:"reply_to,omitempty"`
 	Subject     string            `json:"subject"`
 	Body        string            `json:"body"`
+	HTMLBody    string            `json:"html_body,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+	// TemplateID names a worker-side template rendered with Variables into the
+	// subject and bodies, in place of submitting them directly
+	TemplateID  string            `json:"template_id,omitempty"`
+	Variables   map[string]any    `json:"variables,omitempty"`
+	// Locale selects the template translation, e.g. "pt-BR"
+	Locale      string            `json:"locale,omitempty"`
+	// SendAt delays sending until the given time; the job is parked until then
+	SendAt      *time.Time        `json:"send_at,omitempty"`
+	// ExpiresAt is the deadline after which the job must not be sent at all
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	// Priority picks the lane the job waits in; empty means normal
+	Priority    JobPriority       `json:"priority,omitempty"`
+	// IdempotencyKey makes jobs carrying the same key send at most once
+	// within the idempotency window
+	IdempotencyKey string         `json:"idempotency_key,omitempty"`
+	// PendingRecipients narrows delivery to the envelope recipients still owed
+	// the message after an attempt some of them deferred; empty means all
+	PendingRecipients []string    `json:"pending_recipients,omitempty"`
 	CreatedAt   time.Time         `json:"-"`
 	CreatedAtStr string           `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
@@ -26,42 +50,116 @@ type EmailJob struct {
 type JobStatus string
 
 const (
+	// JobStatusQueued is the status the API stores when it accepts a job
+	JobStatusQueued     JobStatus = "queued"
 	JobStatusPending    JobStatus = "pending"
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
 	JobStatusRetrying   JobStatus = "retrying"
+	// JobStatusPartiallyDelivered means the server accepted some recipients and rejected others
+	JobStatusPartiallyDelivered JobStatus = "partially_delivered"
+	// JobStatusScheduled means the job is parked until its send_at time
+	JobStatusScheduled JobStatus = "scheduled"
+	// JobStatusExpired means the deadline passed before the job could be sent
+	JobStatusExpired JobStatus = "expired"
+	// JobStatusDuplicate means a job with the same idempotency key was already delivered
+	JobStatusDuplicate JobStatus = "duplicate"
 )
 
+// MaxIdempotencyKeyLength bounds the idempotency keys jobs may carry
+const MaxIdempotencyKeyLength = 256
+
+// JobPriority selects the queue lane a job is consumed from. High-priority
+// lanes get a larger share of the worker's capacity, so transactional mail
+// is not held up behind bulk sends.
+type JobPriority string
+
+const (
+	JobPriorityHigh   JobPriority = "high"
+	JobPriorityNormal JobPriority = "normal"
+	JobPriorityLow    JobPriority = "low"
+)
+
+// JobPriorities lists every priority, highest first
+var JobPriorities = []JobPriority{JobPriorityHigh, JobPriorityNormal, JobPriorityLow}
+
+// IsValid returns true if the priority is known; empty counts as normal
+func (p JobPriority) IsValid() bool {
+	switch p {
+	case "", JobPriorityHigh, JobPriorityNormal, JobPriorityLow:
+		return true
+	default:
+		return false
+	}
+}
+
 // JobHistoryEntry represents a single entry in job history
 type JobHistoryEntry struct {
 	Status    JobStatus `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Error     string    `json:"error,omitempty"`
 	Message   string    `json:"message,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
 }
 
-// IsTerminal returns true if the job status is terminal (completed or failed)
+// StatusChange describes a status transition and the details recorded with it
+type StatusChange struct {
+	Status     JobStatus
+	Message    string
+	Error      string
+	RetryCount int
+	Details    map[string]string
+	// Metadata is merged into the job's metadata
+	Metadata   map[string]string
+}
+
+// IsTerminal returns true if the job status is terminal (completed, partially delivered, failed, expired or duplicate)
 func (s JobStatus) IsTerminal() bool {
-	return s == JobStatusCompleted || s == JobStatusFailed
+	return s == JobStatusCompleted || s == JobStatusPartiallyDelivered || s == JobStatusFailed ||
+		s == JobStatusExpired || s == JobStatusDuplicate
 }
 
 // IsValid returns true if the job status is valid
 func (s JobStatus) IsValid() bool {
 	switch s {
-	case JobStatusPending, JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusRetrying:
+	case JobStatusQueued, JobStatusPending, JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusRetrying,
+		JobStatusPartiallyDelivered, JobStatusScheduled, JobStatusExpired, JobStatusDuplicate:
 		return true
 	default:
 		return false
 	}
 }
 
+// jobTransitions lists the statuses each status may move to. A job is picked
+// up, parked, retried or given up on from any waiting status; only a job being
+// sent can be delivered. Redeliveries repeat a status, so some statuses follow
+// themselves. Terminal statuses have no entry.
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobStatusQueued:     {JobStatusPending, JobStatusScheduled, JobStatusProcessing, JobStatusRetrying, JobStatusFailed, JobStatusExpired, JobStatusDuplicate},
+	JobStatusPending:    {JobStatusScheduled, JobStatusProcessing, JobStatusRetrying, JobStatusFailed, JobStatusExpired, JobStatusDuplicate},
+	JobStatusScheduled:  {JobStatusScheduled, JobStatusPending, JobStatusProcessing, JobStatusFailed, JobStatusExpired, JobStatusDuplicate},
+	JobStatusRetrying:   {JobStatusScheduled, JobStatusProcessing, JobStatusRetrying, JobStatusFailed, JobStatusExpired, JobStatusDuplicate},
+	JobStatusProcessing: {JobStatusProcessing, JobStatusCompleted, JobStatusPartiallyDelivered, JobStatusRetrying, JobStatusFailed, JobStatusExpired, JobStatusDuplicate},
+}
+
+// CanTransitionTo returns true if a job in this status may move to next.
+// Terminal jobs never change again.
+func (s JobStatus) CanTransitionTo(next JobStatus) bool {
+	for _, allowed := range jobTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // NewEmailJob creates a new email job with default values
 func NewEmailJob(jobID, to, subject, body string, maxRetries int) *EmailJob {
 	now := time.Now()
 	return &EmailJob{
 		JobID:      jobID,
-		To:         to,
+		To:         AddressList{to},
 		Subject:    subject,
 		Body:       body,
 		CreatedAt:  now,
@@ -93,6 +191,21 @@ func (j *EmailJob) UpdateStatus(status JobStatus, message, errorMsg string) {
 	j.AddHistoryEntry(status, message, errorMsg)
 }
 
+// ApplyStatusChange updates the job status and records the change details in its history
+func (j *EmailJob) ApplyStatusChange(change *StatusChange) {
+	j.UpdateStatus(change.Status, change.Message, change.Error)
+	j.History[len(j.History)-1].Details = change.Details
+	if len(change.Metadata) > 0 && j.Metadata == nil {
+		j.Metadata = make(map[string]string, len(change.Metadata))
+	}
+	for key, value := range change.Metadata {
+		j.Metadata[key] = value
+	}
+	if change.RetryCount > 0 {
+		j.RetryCount = change.RetryCount
+	}
+}
+
 // IncrementRetry increments the retry count and updates the status
 func (j *EmailJob) IncrementRetry(errorMsg string) {
 	j.RetryCount++
@@ -110,19 +223,50 @@ func (j *EmailJob) CanProcess() bool {
 	return j.Status == JobStatusPending || j.Status == JobStatusRetrying
 }
 
+// IsDue returns true if the job has no send time or it has been reached
+func (j *EmailJob) IsDue(now time.Time) bool {
+	return j.SendAt == nil || !j.SendAt.After(now)
+}
+
+// Lane returns the job's priority, treating an unset or unknown priority as normal
+func (j *EmailJob) Lane() JobPriority {
+	if j.Priority == "" || !j.Priority.IsValid() {
+		return JobPriorityNormal
+	}
+	return j.Priority
+}
+
+// IsExpired returns true if the job has a deadline and it has been reached
+func (j *EmailJob) IsExpired(now time.Time) bool {
+	return j.ExpiresAt != nil && !now.Before(*j.ExpiresAt)
+}
+
 // Validate validates the email job
 func (j *EmailJob) Validate() error {
 	if j.JobID == "" {
 		return &ValidationError{Message: "job_id is required"}
 	}
-	if j.To == "" {
-		return &ValidationError{Message: "to is required"}
+	if err := j.validateRecipients(); err != nil {
+		return err
 	}
-	if j.Subject == "" {
+	// Templated jobs get their subject and bodies when rendered
+	if j.Subject == "" && j.TemplateID == "" {
 		return &ValidationError{Message: "subject is required"}
 	}
-	if j.Body == "" {
-		return &ValidationError{Message: "body is required"}
+	if j.Body == "" && j.HTMLBody == "" && j.TemplateID == "" {
+		return &ValidationError{Message: "body or html_body is required"}
+	}
+	if j.Locale != "" && !localeTag.MatchString(j.Locale) {
+		return &ValidationError{Message: "locale must be a language tag such as en or pt-BR"}
+	}
+	if err := validateAttachments(j.Attachments); err != nil {
+		return err
+	}
+	if len(j.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return &ValidationError{Message: "idempotency_key must be at most 256 characters"}
+	}
+	if !j.Priority.IsValid() {
+		return &ValidationError{Message: "priority must be one of: high, normal, low"}
 	}
 	if j.MaxRetries < 0 {
 		return &ValidationError{Message: "max_retries must be >= 0"}
@@ -133,6 +277,9 @@ func (j *EmailJob) Validate() error {
 	return nil
 }
 
+// localeTag matches BCP 47 style tags, allowing underscores as separators
+var localeTag = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
 // ValidationError represents a validation error
 type ValidationError struct {
 	Message string
@@ -0,0 +1,83 @@
+package models
+
+import "testing"
+
+// allStatuses lists every status IsValid accepts
+var allStatuses = []JobStatus{
+	JobStatusQueued, JobStatusPending, JobStatusScheduled, JobStatusProcessing, JobStatusRetrying,
+	JobStatusCompleted, JobStatusPartiallyDelivered, JobStatusFailed, JobStatusExpired, JobStatusDuplicate,
+}
+
+func TestJobStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to JobStatus
+		want     bool
+	}{
+		// The API stores queued; a worker picks the job up or parks it
+		{from: JobStatusQueued, to: JobStatusProcessing, want: true},
+		{from: JobStatusQueued, to: JobStatusScheduled, want: true},
+		{from: JobStatusPending, to: JobStatusProcessing, want: true},
+		{from: JobStatusScheduled, to: JobStatusPending, want: true},
+		{from: JobStatusScheduled, to: JobStatusScheduled, want: true},
+		{from: JobStatusRetrying, to: JobStatusProcessing, want: true},
+		{from: JobStatusRetrying, to: JobStatusRetrying, want: true},
+
+		// Only a job being sent is delivered
+		{from: JobStatusProcessing, to: JobStatusCompleted, want: true},
+		{from: JobStatusProcessing, to: JobStatusPartiallyDelivered, want: true},
+		{from: JobStatusProcessing, to: JobStatusProcessing, want: true},
+		{from: JobStatusQueued, to: JobStatusCompleted},
+		{from: JobStatusRetrying, to: JobStatusCompleted},
+		{from: JobStatusScheduled, to: JobStatusPartiallyDelivered},
+
+		// A job is given up on from any non-terminal status
+		{from: JobStatusQueued, to: JobStatusFailed, want: true},
+		{from: JobStatusProcessing, to: JobStatusFailed, want: true},
+		{from: JobStatusScheduled, to: JobStatusExpired, want: true},
+		{from: JobStatusPending, to: JobStatusDuplicate, want: true},
+
+		// Going back to waiting
+		{from: JobStatusProcessing, to: JobStatusQueued},
+		{from: JobStatusRetrying, to: JobStatusPending},
+		{from: JobStatusScheduled, to: JobStatusRetrying},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalStatusesNeverChange(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			if from.IsTerminal() && from.CanTransitionTo(to) {
+				t.Errorf("terminal %s may move to %s", from, to)
+			}
+		}
+	}
+}
+
+func TestJobTransitionsCoverEveryStatus(t *testing.T) {
+	for _, status := range allStatuses {
+		if !status.IsValid() {
+			t.Errorf("%s is not valid", status)
+		}
+		// Every waiting status leads somewhere, or jobs would get stuck
+		if _, ok := jobTransitions[status]; !ok && !status.IsTerminal() {
+			t.Errorf("non-terminal %s has no transitions", status)
+		}
+	}
+
+	for from, targets := range jobTransitions {
+		for _, to := range targets {
+			if !to.IsValid() {
+				t.Errorf("%s may move to unknown status %q", from, to)
+			}
+		}
+	}
+	if JobStatus("sent").IsValid() || JobStatus("").CanTransitionTo(JobStatusProcessing) {
+		t.Error("unknown statuses are accepted")
+	}
+}
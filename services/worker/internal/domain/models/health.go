@@ -8,8 +8,32 @@ type HealthResponse struct {
 	Service       string                 `json:"service"`
 	IsRunning     bool                   `json:"isRunning"`
 	JobsProcessed int                    `json:"jobsProcessed"`
+	Concurrency   int                    `json:"concurrency"`
+	ActiveJobs    int                    `json:"activeJobs"`
 	Timestamp     time.Time              `json:"timestamp"`
 	Dependencies  map[string]HealthCheck `json:"dependencies,omitempty"`
+	ConnectionPools map[string]ConnectionPoolStats `json:"connectionPools,omitempty"`
+	Lanes           map[string]LaneStats           `json:"lanes,omitempty"`
+}
+
+// LaneStats reports the queue behind one priority lane
+type LaneStats struct {
+	Queue     string `json:"queue"`
+	Depth     int    `json:"depth"`
+	Consumers int    `json:"consumers"`
+	Weight    int    `json:"weight"`
+}
+
+// ConnectionPoolStats reports the state of a connection pool
+type ConnectionPoolStats struct {
+	Open      int    `json:"open"`
+	Idle      int    `json:"idle"`
+	InUse     int    `json:"inUse"`
+	MaxActive int    `json:"maxActive"`
+	MaxIdle   int    `json:"maxIdle"`
+	Created   uint64 `json:"created"`
+	Reused    uint64 `json:"reused"`
+	Discarded uint64 `json:"discarded"`
 }
 
 // HealthCheck represents the health status of a dependency
@@ -27,6 +51,8 @@ const (
 	HealthStatusHealthy   HealthStatus = "healthy"
 	HealthStatusUnhealthy HealthStatus = "unhealthy"
 	HealthStatusDegraded  HealthStatus = "degraded"
+	// HealthStatusReconnecting marks a dependency that dropped and is being re-established
+	HealthStatusReconnecting HealthStatus = "reconnecting"
 )
 
 // NewHealthResponse creates a new health response
@@ -68,7 +94,7 @@ func (h *HealthResponse) updateOverallStatus() {
 		switch HealthStatus(dep.Status) {
 		case HealthStatusUnhealthy:
 			hasUnhealthy = true
-		case HealthStatusDegraded:
+		case HealthStatusDegraded, HealthStatusReconnecting:
 			hasDegraded = true
 		}
 	}
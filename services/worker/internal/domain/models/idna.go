@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Punycode parameters (RFC 3492 section 5)
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// acePrefix marks a label as punycode-encoded (RFC 5890)
+const acePrefix = "xn--"
+
+// domainToASCII converts a domain name to its ASCII form: labels are lowercased,
+// internationalized labels are punycode-encoded and every label is checked
+// against the hostname rules. Unicode normalization is not applied, so
+// domains should be submitted in NFC, as browsers and mail clients do.
+func domainToASCII(domain string) (string, error) {
+	if domain == "" {
+		return "", fmt.Errorf("domain is empty")
+	}
+
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, label := range labels {
+		ascii, err := labelToASCII(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = ascii
+	}
+
+	// The last label is never all digits, which would make it an IPv4 address
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("domain %q has a numeric top-level label", domain)
+	}
+
+	ascii := strings.Join(labels, ".")
+	if len(ascii) > 253 {
+		return "", fmt.Errorf("domain %q is longer than 253 characters", domain)
+	}
+	return ascii, nil
+}
+
+// labelToASCII converts a single domain label
+func labelToASCII(label string) (string, error) {
+	if label == "" {
+		return "", fmt.Errorf("domain has an empty label")
+	}
+
+	ascii := true
+	for _, r := range label {
+		if r >= utf8.RuneSelf {
+			ascii = false
+			if !unicode.In(r, unicode.L, unicode.M, unicode.N) {
+				return "", fmt.Errorf("domain label %q contains %q", label, r)
+			}
+		} else if !isLDH(byte(r)) {
+			return "", fmt.Errorf("domain label %q contains %q", label, r)
+		}
+	}
+
+	if !ascii {
+		label = acePrefix + punycodeEncode(label)
+	}
+
+	if len(label) > 63 {
+		return "", fmt.Errorf("domain label %q is longer than 63 characters", label)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return "", fmt.Errorf("domain label %q starts or ends with a hyphen", label)
+	}
+	return label, nil
+}
+
+// isLDH reports whether c is a letter, digit or hyphen
+func isLDH(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-'
+}
+
+// punycodeEncode implements the RFC 3492 encoding of a Unicode label
+func punycodeEncode(label string) string {
+	runes := []rune(label)
+
+	var out strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+	for handled < len(runes) {
+		// The next code point to insert is the smallest one not yet handled
+		next := rune(unicode.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < next {
+				next = r
+			}
+		}
+
+		delta += int(next-n) * (handled + 1)
+		n = next
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := k - bias
+				if t < punycodeTMin {
+					t = punycodeTMin
+				} else if t > punycodeTMax {
+					t = punycodeTMax
+				}
+				if q < t {
+					break
+				}
+				out.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			out.WriteByte(punycodeDigit(q))
+
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return out.String()
+}
+
+// punycodeAdapt is the RFC 3492 bias adaptation function
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+// punycodeDigit maps 0-25 to a-z and 26-35 to 0-9
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
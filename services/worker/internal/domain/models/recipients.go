@@ -0,0 +1,224 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxRecipients bounds the number of To, Cc and Bcc addresses on one job
+const MaxRecipients = 100
+
+// AddressList is a list of email addresses. In JSON it accepts a single string
+// or an array, and a single address is written back as a plain string so jobs
+// keep the shape the API stores them in.
+type AddressList []string
+
+// String joins the addresses for display and logging
+func (l AddressList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// MarshalJSON writes a single address as a string and several as an array
+func (l AddressList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
+
+// UnmarshalJSON accepts a string or an array of strings
+func (l *AddressList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*l = nil
+		} else {
+			*l = AddressList{single}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("address list must be a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
+
+// Job metadata keys recording how a message was sent
+const (
+	// MetadataMessageID holds the Message-ID of the sent message; a retried
+	// job reuses it
+	MetadataMessageID = "message_id"
+	// MetadataDeliveryBackend names the backend of a failover chain that
+	// delivered the message
+	MetadataDeliveryBackend = "delivery_backend"
+	// MetadataRejectedRecipients lists the recipients the server refused on
+	// the last attempt, with their reply codes
+	MetadataRejectedRecipients = "rejected_recipients"
+	// MetadataLocaleRequested holds the locale a templated job asked for
+	MetadataLocaleRequested = "locale_requested"
+	// MetadataLocaleUsed holds the locale of the translation that was
+	// rendered, which falls back from the requested one when it is missing
+	MetadataLocaleUsed = "locale_used"
+)
+
+// DeliveryResult reports how the server answered each recipient of a message
+type DeliveryResult struct {
+	// MessageID is the Message-ID header the message was sent with
+	MessageID string
+	// Backend names the transport that handled the message when there are several
+	Backend  string
+	Accepted []string
+	Rejected []RecipientRejection
+}
+
+// RecipientRejection is a recipient the server refused at RCPT time
+type RecipientRejection struct {
+	Address      string
+	Code         int
+	EnhancedCode string
+	Message      string
+	Permanent    bool
+}
+
+// IsPartial returns true if the message reached some recipients but not all
+func (r *DeliveryResult) IsPartial() bool {
+	return r != nil && len(r.Accepted) > 0 && len(r.Rejected) > 0
+}
+
+// Deferred returns the recipients the server turned away with a transient
+// reply, such as greylisting, which may accept the message on a later attempt
+func (r *DeliveryResult) Deferred() []string {
+	if r == nil {
+		return nil
+	}
+
+	var deferred []string
+	for _, rejection := range r.Rejected {
+		if !rejection.Permanent {
+			deferred = append(deferred, rejection.Address)
+		}
+	}
+	return deferred
+}
+
+// Details returns the accepted and rejected addresses for a history entry
+func (r *DeliveryResult) Details() map[string]string {
+	if r == nil {
+		return nil
+	}
+
+	details := map[string]string{
+		"accepted_recipients": strings.Join(r.Accepted, ", "),
+	}
+	if len(r.Rejected) > 0 {
+		rejected := make([]string, len(r.Rejected))
+		for i, rejection := range r.Rejected {
+			rejected[i] = fmt.Sprintf("%s (%d %s)", rejection.Address, rejection.Code, rejection.EnhancedCode)
+		}
+		details["rejected_recipients"] = strings.Join(rejected, ", ")
+	}
+	return details
+}
+
+// Recipients returns the envelope recipients of the job: the addr-specs of To,
+// Cc and Bcc with duplicates removed, limited to PendingRecipients when set.
+// Addresses that do not parse are passed through as given; Validate rejects
+// them before anything is sent.
+func (j *EmailJob) Recipients() []string {
+	var pending map[string]bool
+	if len(j.PendingRecipients) > 0 {
+		pending = make(map[string]bool, len(j.PendingRecipients))
+		for _, address := range j.PendingRecipients {
+			pending[recipientKey(address)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var recipients []string
+	for _, list := range []AddressList{j.To, j.Cc, j.Bcc} {
+		for _, address := range list {
+			if parsed, err := ParseAddress(address); err == nil {
+				address = parsed.Addr()
+			}
+			key := strings.ToLower(address)
+			if seen[key] || (pending != nil && !pending[key]) {
+				continue
+			}
+			seen[key] = true
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
+
+// recipientKey returns the form an address is compared in: its addr-spec,
+// lowercased
+func recipientKey(address string) string {
+	if parsed, err := ParseAddress(address); err == nil {
+		address = parsed.Addr()
+	}
+	return strings.ToLower(address)
+}
+
+// RequiresSMTPUTF8 reports whether any recipient or Reply-To address has a
+// non-ASCII local part
+func (j *EmailJob) RequiresSMTPUTF8() bool {
+	for _, list := range []AddressList{j.To, j.Cc, j.Bcc, j.ReplyTo} {
+		for _, address := range list {
+			if parsed, err := ParseAddress(address); err == nil && parsed.RequiresSMTPUTF8() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateRecipients checks the recipient and Reply-To lists
+func (j *EmailJob) validateRecipients() error {
+	total := len(j.To) + len(j.Cc) + len(j.Bcc)
+	if total == 0 {
+		return &ValidationError{Message: "to is required"}
+	}
+	if total > MaxRecipients {
+		return &ValidationError{Message: fmt.Sprintf("at most %d recipients are allowed", MaxRecipients)}
+	}
+
+	lists := []struct {
+		field     string
+		addresses AddressList
+	}{
+		{"to", j.To}, {"cc", j.Cc}, {"bcc", j.Bcc}, {"reply_to", j.ReplyTo},
+	}
+	for _, list := range lists {
+		for i, address := range list.addresses {
+			if strings.TrimSpace(address) == "" {
+				return &ValidationError{Message: fmt.Sprintf("%s[%d] is empty", list.field, i)}
+			}
+			if _, err := ParseAddress(address); err != nil {
+				return &ValidationError{Message: fmt.Sprintf("%s[%d]: %v", list.field, i, err)}
+			}
+		}
+	}
+
+	// Pending recipients narrow a partly delivered job, so each must be one
+	// of its own recipients
+	if len(j.PendingRecipients) > 0 {
+		known := make(map[string]bool, total)
+		for _, list := range []AddressList{j.To, j.Cc, j.Bcc} {
+			for _, address := range list {
+				known[recipientKey(address)] = true
+			}
+		}
+		for i, address := range j.PendingRecipients {
+			if !known[recipientKey(address)] {
+				return &ValidationError{Message: fmt.Sprintf("pending_recipients[%d] is not a recipient of the job", i)}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddressListJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  AddressList
+		json  string
+	}{
+		{input: `"jane@example.com"`, want: AddressList{"jane@example.com"}, json: `"jane@example.com"`},
+		{input: `["jane@example.com"]`, want: AddressList{"jane@example.com"}, json: `"jane@example.com"`},
+		{input: `["jane@example.com","John <john@example.com>"]`, want: AddressList{"jane@example.com", "John <john@example.com>"}, json: `["jane@example.com","John \u003cjohn@example.com\u003e"]`},
+		{input: `""`, want: nil, json: `null`},
+		{input: `[]`, want: AddressList{}, json: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var list AddressList
+			if err := json.Unmarshal([]byte(tt.input), &list); err != nil {
+				t.Fatalf("Unmarshal returned %v", err)
+			}
+			if !reflect.DeepEqual(list, tt.want) {
+				t.Fatalf("got %#v, want %#v", list, tt.want)
+			}
+
+			data, err := json.Marshal(list)
+			if err != nil {
+				t.Fatalf("Marshal returned %v", err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("marshalled to %s, want %s", data, tt.json)
+			}
+
+			var again AddressList
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("Unmarshal of %s returned %v", data, err)
+			}
+			if len(again) != len(list) || (len(list) > 0 && !reflect.DeepEqual(again, list)) {
+				t.Errorf("round trip gave %#v, want %#v", again, list)
+			}
+		})
+	}
+}
+
+func TestAddressListJSONRejectsOtherShapes(t *testing.T) {
+	for _, input := range []string{`42`, `{"to":"jane@example.com"}`, `[1,2]`, `true`} {
+		var list AddressList
+		if err := json.Unmarshal([]byte(input), &list); err == nil {
+			t.Errorf("Unmarshal(%s) gave %#v, want an error", input, list)
+		}
+	}
+}
+
+func TestJobKeepsToAsStoredByAPI(t *testing.T) {
+	var job EmailJob
+	if err := json.Unmarshal([]byte(`{"job_id":"job-1","to":"jane@example.com","cc":["a@example.com","b@example.com"]}`), &job); err != nil {
+		t.Fatalf("Unmarshal returned %v", err)
+	}
+
+	data, err := json.Marshal(&job)
+	if err != nil {
+		t.Fatalf("Marshal returned %v", err)
+	}
+	for _, want := range []string{`"to":"jane@example.com"`, `"cc":["a@example.com","b@example.com"]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("job marshalled to %s, want it to contain %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), `"bcc"`) {
+		t.Errorf("job marshalled to %s, want no empty bcc", data)
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	tests := []struct {
+		name    string
+		to      AddressList
+		cc      AddressList
+		bcc     AddressList
+		pending []string
+		want    []string
+	}{
+		{
+			name: "every list in order",
+			to:   AddressList{"Jane <jane@example.com>"},
+			cc:   AddressList{"cc@example.com"},
+			bcc:  AddressList{"bcc@example.com"},
+			want: []string{"jane@example.com", "cc@example.com", "bcc@example.com"},
+		},
+		{
+			name: "duplicates across lists and case",
+			to:   AddressList{"jane@example.com", "Jane Doe <JANE@example.com>"},
+			cc:   AddressList{"jane@EXAMPLE.com"},
+			bcc:  AddressList{"john@example.com", "jane@example.com"},
+			want: []string{"jane@example.com", "john@example.com"},
+		},
+		{
+			name: "international domain",
+			to:   AddressList{"info@münchen.de", "info@xn--mnchen-3ya.de"},
+			want: []string{"info@xn--mnchen-3ya.de"},
+		},
+		{
+			name:    "pending recipients",
+			to:      AddressList{"jane@example.com", "john@example.com"},
+			bcc:     AddressList{"audit@example.com"},
+			pending: []string{"Audit <AUDIT@example.com>", "john@example.com"},
+			want:    []string{"john@example.com", "audit@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &EmailJob{To: tt.to, Cc: tt.cc, Bcc: tt.bcc, PendingRecipients: tt.pending}
+			if got := job.Recipients(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recipients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRecipients(t *testing.T) {
+	many := make(AddressList, MaxRecipients+1)
+	for i := range many {
+		many[i] = "user@example.com"
+	}
+
+	tests := []struct {
+		name    string
+		job     EmailJob
+		wantErr string
+	}{
+		{name: "valid", job: EmailJob{To: AddressList{"jane@example.com"}, Bcc: AddressList{"audit@example.com"}}},
+		{name: "no recipients", job: EmailJob{ReplyTo: AddressList{"jane@example.com"}}, wantErr: "to is required"},
+		{name: "only bcc", job: EmailJob{Bcc: AddressList{"audit@example.com"}}},
+		{name: "too many", job: EmailJob{To: many}, wantErr: "at most"},
+		{name: "empty address", job: EmailJob{To: AddressList{"jane@example.com"}, Cc: AddressList{" "}}, wantErr: "cc[0] is empty"},
+		{name: "invalid reply-to", job: EmailJob{To: AddressList{"jane@example.com"}, ReplyTo: AddressList{"nobody"}}, wantErr: "reply_to[0]"},
+		{
+			name: "pending in another form",
+			job:  EmailJob{To: AddressList{"Jane <jane@example.com>", "john@example.com"}, PendingRecipients: []string{"JANE@example.com"}},
+		},
+		{
+			name:    "pending stranger",
+			job:     EmailJob{To: AddressList{"jane@example.com", "john@example.com"}, PendingRecipients: []string{"jane@example.com", "mallory@example.com"}},
+			wantErr: "pending_recipients[1] is not a recipient",
+		},
+		{
+			// As many pending addresses as recipients, but not the same ones
+			name:    "pending swapped for a stranger",
+			job:     EmailJob{To: AddressList{"jane@example.com", "jane@example.com", "john@example.com"}, PendingRecipients: []string{"mallory@example.com", "john@example.com"}},
+			wantErr: "pending_recipients[0] is not a recipient",
+		},
+		{
+			name: "pending repeated",
+			job:  EmailJob{To: AddressList{"jane@example.com"}, Cc: AddressList{"john@example.com"}, PendingRecipients: []string{"jane@example.com", "jane@example.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.job.validateRecipients()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateRecipients returned %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validateRecipients returned %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeliveryResult(t *testing.T) {
+	result := &DeliveryResult{
+		Accepted: []string{"jane@example.com"},
+		Rejected: []RecipientRejection{
+			{Address: "gone@example.com", Code: 550, EnhancedCode: "5.1.1", Permanent: true},
+			{Address: "later@example.com", Code: 451, EnhancedCode: "4.7.1"},
+		},
+	}
+
+	if !result.IsPartial() {
+		t.Error("IsPartial() = false with accepted and rejected recipients")
+	}
+	if got := result.Deferred(); !reflect.DeepEqual(got, []string{"later@example.com"}) {
+		t.Errorf("Deferred() = %v, want [later@example.com]", got)
+	}
+	want := map[string]string{
+		"accepted_recipients": "jane@example.com",
+		"rejected_recipients": "gone@example.com (550 5.1.1), later@example.com (451 4.7.1)",
+	}
+	if got := result.Details(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Details() = %v, want %v", got, want)
+	}
+
+	var none *DeliveryResult
+	if none.IsPartial() || none.Deferred() != nil || none.Details() != nil {
+		t.Error("a nil result reports recipients")
+	}
+}
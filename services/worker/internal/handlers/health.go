@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"task-scheduler-worker/internal/domain/models"
@@ -20,7 +21,9 @@ type HealthHandler struct {
 	emailService     email.EmailService
 	logger           *logger.Logger
 	isRunning        bool
-	jobsProcessed    int
+	jobsProcessed    atomic.Int64
+	activeJobs       atomic.Int64
+	concurrency      int
 }
 
 // NewHealthHandler creates a new health check handler
@@ -29,6 +32,7 @@ func NewHealthHandler(
 	messagingService messaging.MessageBroker,
 	emailService email.EmailService,
 	logger *logger.Logger,
+	concurrency int,
 ) *HealthHandler {
 	return &HealthHandler{
 		cacheService:     cacheService,
@@ -36,7 +40,7 @@ func NewHealthHandler(
 		emailService:     emailService,
 		logger:           logger,
 		isRunning:        true,
-		jobsProcessed:    0,
+		concurrency:      concurrency,
 	}
 }
 
@@ -47,19 +51,31 @@ func (h *HealthHandler) SetRunning(running bool) {
 
 // IncrementJobsProcessed increments the jobs processed counter
 func (h *HealthHandler) IncrementJobsProcessed() {
-	h.jobsProcessed++
+	h.jobsProcessed.Add(1)
 }
 
 // GetJobsProcessed returns the number of jobs processed
 func (h *HealthHandler) GetJobsProcessed() int {
-	return h.jobsProcessed
+	return int(h.jobsProcessed.Load())
+}
+
+// JobStarted marks a job as occupying one of the worker pool slots
+func (h *HealthHandler) JobStarted() {
+	h.activeJobs.Add(1)
+}
+
+// JobFinished releases the worker pool slot taken by JobStarted
+func (h *HealthHandler) JobFinished() {
+	h.activeJobs.Add(-1)
 }
 
 // HealthCheck performs a comprehensive health check
 func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	
-	response := models.NewHealthResponse("worker-go", h.isRunning, h.jobsProcessed)
+	response := models.NewHealthResponse("worker-go", h.isRunning, h.GetJobsProcessed())
+	response.Concurrency = h.concurrency
+	response.ActiveJobs = int(h.activeJobs.Load())
 	
 	// Check Redis connectivity
 	h.checkRedis(ctx, response)
@@ -67,8 +83,19 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Check RabbitMQ connectivity
 	h.checkRabbitMQ(ctx, response)
 	
-	// Check SMTP connectivity
-	h.checkSMTP(ctx, response)
+	// Check SMTP connectivity, per backend when there is a failover chain
+	if chain, ok := h.emailService.(email.BackendProvider); ok {
+		h.checkEmailBackends(ctx, response, chain.Backends())
+	} else {
+		h.checkSMTP(ctx, response)
+
+		// Report SMTP connection pool usage
+		if pooled, ok := h.emailService.(email.PoolStatsProvider); ok {
+			response.ConnectionPools = map[string]models.ConnectionPoolStats{
+				"smtp": pooled.PoolStats(),
+			}
+		}
+	}
 	
 	// Set HTTP status based on overall health
 	statusCode := http.StatusOK
@@ -130,12 +157,24 @@ func (h *HealthHandler) checkRabbitMQ(ctx context.Context, response *models.Heal
 	err := h.messagingService.Ping(pingCtx)
 	latency := time.Since(start)
 	
-	if err != nil {
+	if err == messaging.ErrReconnecting {
+		// Consumers resume on their own once the connection is back
+		response.AddDependencyCheck("rabbitmq", models.HealthStatusReconnecting, err.Error(), latency.String())
+		h.logger.LogHealthCheck("rabbitmq", false, latency.String(), err)
+	} else if err != nil {
 		response.AddDependencyCheck("rabbitmq", models.HealthStatusUnhealthy, err.Error(), latency.String())
 		h.logger.LogHealthCheck("rabbitmq", false, latency.String(), err)
 	} else {
 		response.AddDependencyCheck("rabbitmq", models.HealthStatusHealthy, "Connected", latency.String())
 		h.logger.LogHealthCheck("rabbitmq", true, latency.String(), nil)
+
+		// Report how far behind each priority lane is
+		lanes, err := h.messagingService.LaneStats(pingCtx)
+		if err != nil {
+			h.logger.WithComponent("health").Warn("Failed to read priority lane stats", "error", err)
+			return
+		}
+		response.Lanes = lanes
 	}
 }
 
@@ -157,4 +196,65 @@ func (h *HealthHandler) checkSMTP(ctx context.Context, response *models.HealthRe
 		response.AddDependencyCheck("smtp", models.HealthStatusHealthy, "Connected", latency.String())
 		h.logger.LogHealthCheck("smtp", true, latency.String(), nil)
 	}
-}
\ No newline at end of file
+}
+
+// checkEmailBackends checks each backend of a failover chain and reports it as
+// its own dependency, along with its circuit breaker state and pool usage. A
+// backend that fails its ping or whose circuit is not closed is degraded while
+// another backend can still send, and unhealthy once none can.
+func (h *HealthHandler) checkEmailBackends(ctx context.Context, response *models.HealthResponse, backends []email.BackendStatus) {
+	type backendCheck struct {
+		name    string
+		healthy bool
+		err     error
+		message string
+		latency string
+	}
+
+	checks := make([]backendCheck, 0, len(backends))
+	usable := 0
+	for _, backend := range backends {
+		start := time.Now()
+
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := backend.Service.Ping(pingCtx)
+		cancel()
+
+		check := backendCheck{name: "email:" + backend.Name, err: err, latency: time.Since(start).String()}
+		switch {
+		case err != nil:
+			check.message = "circuit " + backend.Circuit + ": " + err.Error()
+		case backend.Circuit != email.CircuitClosed:
+			check.message = "Connected, circuit " + backend.Circuit
+		default:
+			check.healthy = true
+			check.message = "Connected, circuit closed"
+		}
+		if err == nil && backend.Circuit != email.CircuitOpen {
+			usable++
+		}
+		checks = append(checks, check)
+
+		if pooled, ok := backend.Service.(email.PoolStatsProvider); ok {
+			if response.ConnectionPools == nil {
+				response.ConnectionPools = make(map[string]models.ConnectionPoolStats)
+			}
+			response.ConnectionPools[backend.Name] = pooled.PoolStats()
+		}
+	}
+
+	// The chain can still send while any backend answers with its circuit not open
+	failing := models.HealthStatusDegraded
+	if usable == 0 {
+		failing = models.HealthStatusUnhealthy
+	}
+	for _, check := range checks {
+		if check.healthy {
+			response.AddDependencyCheck(check.name, models.HealthStatusHealthy, check.message, check.latency)
+			h.logger.LogHealthCheck(check.name, true, check.latency, nil)
+			continue
+		}
+		response.AddDependencyCheck(check.name, failing, check.message, check.latency)
+		h.logger.LogHealthCheck(check.name, false, check.latency, check.err)
+	}
+}
@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-memory server for the subset of RESP2 commands that job
+// records use: strings with expiry, WATCH/MULTI/EXEC and PUBLISH. It lets the
+// optimistic transactions run without a real Redis.
+type fakeRedis struct {
+	// beforeExec runs ahead of every EXEC, e.g. to change a watched key
+	// behind the transaction's back
+	beforeExec func()
+
+	listener net.Listener
+
+	mu        sync.Mutex
+	values    map[string]string
+	expiries  map[string]time.Time
+	revisions map[string]int
+	execs     int
+	published []string
+}
+
+// startFakeRedis serves until the test ends and returns a client for it
+func startFakeRedis(t *testing.T, jobTTL time.Duration) (*fakeRedis, *RedisService) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	server := &fakeRedis{
+		listener:  listener,
+		values:    make(map[string]string),
+		expiries:  make(map[string]time.Time),
+		revisions: make(map[string]int),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	service, err := NewRedisService("redis://"+listener.Addr().String(), jobTTL)
+	if err != nil {
+		t.Fatalf("NewRedisService returned %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+	return server, service
+}
+
+// Set stores value under key with ttl, as another client would
+func (f *fakeRedis) Set(key, value string, ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(key, value, ttl)
+}
+
+// Get returns the value of key and its remaining time to live
+func (f *fakeRedis) Get(key string) (string, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[key], time.Until(f.expiries[key])
+}
+
+// Execs returns how many transactions were attempted
+func (f *fakeRedis) Execs() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.execs
+}
+
+// Published returns the messages published so far
+func (f *fakeRedis) Published() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.published...)
+}
+
+func (f *fakeRedis) set(key, value string, ttl time.Duration) {
+	f.values[key] = value
+	delete(f.expiries, key)
+	if ttl > 0 {
+		f.expiries[key] = time.Now().Add(ttl)
+	}
+	f.revisions[key]++
+}
+
+// fakeRedisConn is the transaction state of one client connection
+type fakeRedisConn struct {
+	watched map[string]int
+	queued  [][]string
+	multi   bool
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	state := &fakeRedisConn{}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.handle(state, args)); err != nil {
+			return
+		}
+	}
+}
+
+// handle runs a command on behalf of a connection and returns the reply
+func (f *fakeRedis) handle(state *fakeRedisConn, args []string) string {
+	name := strings.ToUpper(args[0])
+
+	if state.multi && name != "EXEC" && name != "DISCARD" {
+		state.queued = append(state.queued, args)
+		return "+QUEUED\r\n"
+	}
+
+	switch name {
+	case "HELLO":
+		// Makes the client fall back to RESP2
+		return "-ERR unknown command 'HELLO'\r\n"
+	case "WATCH":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if state.watched == nil {
+			state.watched = make(map[string]int)
+		}
+		for _, key := range args[1:] {
+			state.watched[key] = f.revisions[key]
+		}
+		return "+OK\r\n"
+	case "UNWATCH":
+		state.watched = nil
+		return "+OK\r\n"
+	case "MULTI":
+		state.multi = true
+		return "+OK\r\n"
+	case "DISCARD":
+		state.multi, state.queued, state.watched = false, nil, nil
+		return "+OK\r\n"
+	case "EXEC":
+		queued, watched := state.queued, state.watched
+		state.multi, state.queued, state.watched = false, nil, nil
+
+		if f.beforeExec != nil {
+			f.beforeExec()
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.execs++
+		for key, revision := range watched {
+			if f.revisions[key] != revision {
+				return "*-1\r\n"
+			}
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(queued))
+		for _, command := range queued {
+			reply += f.run(command)
+		}
+		return reply
+	default:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.run(args)
+	}
+}
+
+// run executes a data command; f.mu must be held
+func (f *fakeRedis) run(args []string) string {
+	name := strings.ToUpper(args[0])
+
+	// Expire lazily, as Redis does on access
+	if len(args) > 1 {
+		if expiry, ok := f.expiries[args[1]]; ok && !time.Now().Before(expiry) {
+			delete(f.values, args[1])
+			delete(f.expiries, args[1])
+			f.revisions[args[1]]++
+		}
+	}
+
+	switch {
+	case name == "CLIENT" || name == "SELECT" || name == "PING":
+		return "+OK\r\n"
+	case name == "GET" && len(args) == 2:
+		value, ok := f.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case name == "SET" && len(args) >= 3:
+		var ttl time.Duration
+		if len(args) == 5 && strings.EqualFold(args[3], "PX") {
+			ms, _ := strconv.Atoi(args[4])
+			ttl = time.Duration(ms) * time.Millisecond
+		}
+		f.set(args[1], args[2], ttl)
+		return "+OK\r\n"
+	case name == "SETEX" && len(args) == 4:
+		seconds, _ := strconv.Atoi(args[2])
+		f.set(args[1], args[3], time.Duration(seconds)*time.Second)
+		return "+OK\r\n"
+	case name == "PTTL" && len(args) == 2:
+		if _, ok := f.values[args[1]]; !ok {
+			return ":-2\r\n"
+		}
+		expiry, ok := f.expiries[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(expiry).Milliseconds())
+	case name == "PUBLISH" && len(args) == 3:
+		f.published = append(f.published, args[2])
+		return ":0\r\n"
+	default:
+		return fmt.Sprintf("-ERR unsupported command '%s'\r\n", args[0])
+	}
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("malformed command %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("malformed argument %q", line)
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:length])
+	}
+	return args, nil
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+// idempotencyClaimTTL bounds how long a claim survives a worker that died
+// while sending, so the key is not blocked for the whole window
+const idempotencyClaimTTL = 10 * time.Minute
+
+// An idempotency key is stored as "pending:<job ID>" while a job sends under
+// it and as "sent:<job ID>" once the job was delivered.
+
+// claimIdempotencyScript takes a free key for the job, or renews the job's own
+// claim after a redelivery. A job that delivered to some recipients keeps the
+// key for the retry of the others, without it ever leaving the sent state.
+// Otherwise it reports who holds or delivered the key.
+var claimIdempotencyScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	redis.call('SET', KEYS[1], 'pending:' .. ARGV[1], 'PX', ARGV[2])
+	return {'claimed', ARGV[1]}
+end
+local state, holder = string.match(current, '^(%a+):(.*)$')
+if state == 'sent' then
+	if holder == ARGV[1] then
+		return {'claimed', holder}
+	end
+	return {'delivered', holder}
+end
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return {'claimed', holder}
+end
+return {'in_flight', holder}
+`)
+
+// completeIdempotencyScript marks the key delivered for the window, keeping
+// the first delivery if another job's claim outlived its lease and also sent
+var completeIdempotencyScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and string.sub(current, 1, 5) == 'sent:' then
+	return 0
+end
+redis.call('SET', KEYS[1], 'sent:' .. ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// releaseIdempotencyScript frees the key if the job still holds its claim
+var releaseIdempotencyScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == 'pending:' .. ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// ClaimIdempotencyKey atomically claims key for jobID ahead of sending. Only
+// one job holds a key at a time, so concurrent workers cannot both send.
+func (r *RedisService) ClaimIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) (*IdempotencyClaim, error) {
+	if key == "" || jobID == "" {
+		return nil, errors.NewValidationError("idempotency key and job ID are required")
+	}
+
+	ttl := min(idempotencyClaimTTL, window)
+	reply, err := claimIdempotencyScript.Run(ctx, r.client, []string{idempotencyKey(key)}, jobID, ttl.Milliseconds()).StringSlice()
+	if err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to claim idempotency key", err)
+	}
+	if len(reply) != 2 {
+		return nil, errors.NewRedisError("unexpected reply claiming idempotency key")
+	}
+
+	return &IdempotencyClaim{State: IdempotencyState(reply[0]), JobID: reply[1]}, nil
+}
+
+// CompleteIdempotencyKey records that jobID delivered under key, suppressing
+// further sends with the key for window
+func (r *RedisService) CompleteIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) error {
+	if err := completeIdempotencyScript.Run(ctx, r.client, []string{idempotencyKey(key)}, jobID, window.Milliseconds()).Err(); err != nil {
+		return errors.NewRedisErrorWithCause("failed to complete idempotency key", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey gives up jobID's claim on key after a failed send, so
+// a retry or another job with the key can send
+func (r *RedisService) ReleaseIdempotencyKey(ctx context.Context, key, jobID string) error {
+	if err := releaseIdempotencyScript.Run(ctx, r.client, []string{idempotencyKey(key)}, jobID).Err(); err != nil {
+		return errors.NewRedisErrorWithCause("failed to release idempotency key", err)
+	}
+	return nil
+}
+
+func idempotencyKey(key string) string {
+	return fmt.Sprintf("idempotency:%s", key)
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyLifecycle(t *testing.T) {
+	service := newTestRedisService(t)
+	ctx := context.Background()
+	key := uniqueName(t)
+
+	claim := func(jobID string, want IdempotencyState, wantHolder string) {
+		t.Helper()
+		got, err := service.ClaimIdempotencyKey(ctx, key, jobID, time.Hour)
+		if err != nil {
+			t.Fatalf("ClaimIdempotencyKey(%s) returned %v", jobID, err)
+		}
+		if got.State != want || got.JobID != wantHolder {
+			t.Fatalf("ClaimIdempotencyKey(%s) = %s by %s, want %s by %s", jobID, got.State, got.JobID, want, wantHolder)
+		}
+	}
+	stored := func(want string) {
+		t.Helper()
+		got, _ := service.client.Get(ctx, idempotencyKey(key)).Result()
+		if got != want {
+			t.Fatalf("key holds %q, want %q", got, want)
+		}
+	}
+
+	claim("job-1", IdempotencyClaimed, "job-1")
+	stored("pending:job-1")
+	claim("job-2", IdempotencyInFlight, "job-1")
+	// A redelivery renews its own claim
+	claim("job-1", IdempotencyClaimed, "job-1")
+
+	// Releasing someone else's claim does nothing; the holder frees the key
+	if err := service.ReleaseIdempotencyKey(ctx, key, "job-2"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey returned %v", err)
+	}
+	stored("pending:job-1")
+	if err := service.ReleaseIdempotencyKey(ctx, key, "job-1"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey returned %v", err)
+	}
+	stored("")
+
+	claim("job-2", IdempotencyClaimed, "job-2")
+	if err := service.CompleteIdempotencyKey(ctx, key, "job-2", time.Hour); err != nil {
+		t.Fatalf("CompleteIdempotencyKey returned %v", err)
+	}
+	stored("sent:job-2")
+	claim("job-3", IdempotencyDelivered, "job-2")
+
+	// The job that delivered keeps the key for the retry of deferred
+	// recipients, and the key stays sent throughout
+	claim("job-2", IdempotencyClaimed, "job-2")
+	stored("sent:job-2")
+	if err := service.ReleaseIdempotencyKey(ctx, key, "job-2"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey returned %v", err)
+	}
+	stored("sent:job-2")
+
+	// A claim that outlived its lease cannot overwrite the first delivery
+	if err := service.CompleteIdempotencyKey(ctx, key, "job-3", time.Hour); err != nil {
+		t.Fatalf("CompleteIdempotencyKey returned %v", err)
+	}
+	stored("sent:job-2")
+}
+
+func TestIdempotencyClaimExpires(t *testing.T) {
+	service := newTestRedisService(t)
+	ctx := context.Background()
+	key := uniqueName(t)
+
+	// A claim lasts no longer than the window, nor than idempotencyClaimTTL
+	if _, err := service.ClaimIdempotencyKey(ctx, key, "job-1", time.Hour); err != nil {
+		t.Fatalf("ClaimIdempotencyKey returned %v", err)
+	}
+	ttl := service.client.PTTL(ctx, idempotencyKey(key)).Val()
+	if ttl <= 0 || ttl > idempotencyClaimTTL {
+		t.Errorf("claim expires in %v, want at most %v", ttl, idempotencyClaimTTL)
+	}
+
+	if err := service.CompleteIdempotencyKey(ctx, key, "job-1", time.Hour); err != nil {
+		t.Fatalf("CompleteIdempotencyKey returned %v", err)
+	}
+	ttl = service.client.PTTL(ctx, idempotencyKey(key)).Val()
+	if ttl <= idempotencyClaimTTL || ttl > time.Hour {
+		t.Errorf("delivered key expires in %v, want the one-hour window", ttl)
+	}
+}
@@ -13,8 +13,23 @@ type CacheService interface {
 	StoreJob(ctx context.Context, job *models.EmailJob, ttl time.Duration) error
 	GetJob(ctx context.Context, jobID string) (*models.EmailJob, error)
 	UpdateJobStatus(ctx context.Context, jobID string, status models.JobStatus, errorMsg string, retryCount int) error
+	ApplyStatusChange(ctx context.Context, jobID string, change *models.StatusChange) error
 	DeleteJob(ctx context.Context, jobID string) error
 
+	// Scheduled job operations
+	ScheduleJob(ctx context.Context, job *models.EmailJob, retention time.Duration) error
+	DueJobs(ctx context.Context, now time.Time, limit int) ([]*models.EmailJob, error)
+	UnscheduleJob(ctx context.Context, jobID string) error
+
+	// Idempotency keys
+	ClaimIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) (*IdempotencyClaim, error)
+	CompleteIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, key, jobID string) error
+
+	// Distributed locking
+	AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name, owner string) error
+
 	// Pub/Sub operations
 	PublishJobStatusUpdate(ctx context.Context, update *JobStatusUpdate) error
 	SubscribeToJobStatusUpdates(ctx context.Context, handler func(*JobStatusUpdate)) error
@@ -26,6 +41,25 @@ type CacheService interface {
 	Close() error
 }
 
+// IdempotencyState says who may send for an idempotency key
+type IdempotencyState string
+
+const (
+	// IdempotencyClaimed means the caller holds the key and may send
+	IdempotencyClaimed IdempotencyState = "claimed"
+	// IdempotencyInFlight means another job holds the key and is sending
+	IdempotencyInFlight IdempotencyState = "in_flight"
+	// IdempotencyDelivered means a job with the key was already delivered
+	IdempotencyDelivered IdempotencyState = "delivered"
+)
+
+// IdempotencyClaim is the outcome of claiming an idempotency key
+type IdempotencyClaim struct {
+	State IdempotencyState
+	// JobID is the job holding the key, or the one that delivered it
+	JobID string
+}
+
 // JobStatusUpdate represents a job status update for pub/sub
 type JobStatusUpdate struct {
 	JobID     string                    `json:"job_id"`
@@ -12,13 +12,21 @@ import (
 	"task-scheduler-worker/internal/domain/models"
 )
 
+// maxStatusUpdateAttempts bounds how often a status change is retried when
+// other writers keep changing the job in between
+const maxStatusUpdateAttempts = 10
+
 // RedisService implements CacheService using Redis
 type RedisService struct {
 	client *redis.Client
+
+	// jobTTL is how long a job record is kept after its last status change
+	jobTTL time.Duration
 }
 
-// NewRedisService creates a new Redis cache service
-func NewRedisService(redisURL string) (*RedisService, error) {
+// NewRedisService creates a new Redis cache service keeping job records for
+// jobTTL after their last status change
+func NewRedisService(redisURL string, jobTTL time.Duration) (*RedisService, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, errors.NewRedisErrorWithCause("failed to parse Redis URL", err)
@@ -28,6 +36,7 @@ func NewRedisService(redisURL string) (*RedisService, error) {
 	
 	return &RedisService{
 		client: client,
+		jobTTL: jobTTL,
 	}, nil
 }
 
@@ -76,54 +85,115 @@ func (r *RedisService) GetJob(ctx context.Context, jobID string) (*models.EmailJ
 
 // UpdateJobStatus updates the job status and publishes to pub/sub
 func (r *RedisService) UpdateJobStatus(ctx context.Context, jobID string, status models.JobStatus, errorMsg string, retryCount int) error {
+	return r.ApplyStatusChange(ctx, jobID, &models.StatusChange{
+		Status:     status,
+		Error:      errorMsg,
+		RetryCount: retryCount,
+	})
+}
+
+// ApplyStatusChange records a status change with its details and publishes to
+// pub/sub. The job is read and written in one optimistic transaction: if
+// another writer changes the job in between, the change is applied again on
+// top of theirs, so no history entry is lost. Changes the job's current status
+// does not allow are rejected with an InvalidTransitionError.
+func (r *RedisService) ApplyStatusChange(ctx context.Context, jobID string, change *models.StatusChange) error {
 	if jobID == "" {
 		return errors.NewValidationError("job ID is required")
 	}
 
-	if !status.IsValid() {
+	if !change.Status.IsValid() {
 		return errors.NewValidationError("invalid status")
 	}
 
-	// Get existing job
-	job, err := r.GetJob(ctx, jobID)
-	if err != nil {
+	key := fmt.Sprintf("job:%s", jobID)
+	var job *models.EmailJob
+	update := func(tx *redis.Tx) error {
+		var err error
+		job, err = r.applyStatusChange(ctx, tx, key, jobID, change)
 		return err
 	}
 
-	// Update job status
-	job.UpdateStatus(status, "", errorMsg)
-	if retryCount > 0 {
-		job.RetryCount = retryCount
+	var err error
+	for attempt := 0; attempt < maxStatusUpdateAttempts; attempt++ {
+		err = r.client.Watch(ctx, update, key)
+		if err != redis.TxFailedErr {
+			break
+		}
 	}
-
-	// Store updated job
-	jobData, err := json.Marshal(job)
-	if err != nil {
-		return errors.NewRedisErrorWithCause("failed to marshal updated job", err)
+	if err == redis.TxFailedErr {
+		return errors.NewRedisError(fmt.Sprintf("job %s kept changing during status update", jobID))
 	}
-
-	key := fmt.Sprintf("job:%s", jobID)
-	err = r.client.SetEx(ctx, key, string(jobData), 24*time.Hour).Err()
 	if err != nil {
-		return errors.NewRedisErrorWithCause("failed to update job", err)
+		return err
 	}
 
 	// Publish status update
 	statusUpdate := &JobStatusUpdate{
 		JobID:      jobID,
-		Status:     status,
+		Status:     change.Status,
 		Timestamp:  time.Now(),
 		History:    job.History,
-		To:         job.To,
+		To:         job.To.String(),
 		Subject:    job.Subject,
 		UpdatedAt:  job.UpdatedAt,
-		LastError:  errorMsg,
+		LastError:  change.Error,
 		RetryCount: job.RetryCount,
 	}
 
 	return r.PublishJobStatusUpdate(ctx, statusUpdate)
 }
 
+// applyStatusChange applies a status change to the watched job and queues the
+// write, which fails with redis.TxFailedErr if the job changed since it was read
+func (r *RedisService) applyStatusChange(ctx context.Context, tx *redis.Tx, key, jobID string, change *models.StatusChange) (*models.EmailJob, error) {
+	jobData, err := tx.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, errors.NewRedisError(fmt.Sprintf("job %s not found", jobID))
+		}
+		return nil, errors.NewRedisErrorWithCause("failed to get job", err)
+	}
+
+	var job models.EmailJob
+	if err := json.Unmarshal([]byte(jobData), &job); err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to unmarshal job", err)
+	}
+
+	if !job.Status.CanTransitionTo(change.Status) {
+		return nil, errors.NewInvalidTransitionError(jobID, string(job.Status), string(change.Status))
+	}
+
+	// Keep the record for the configured TTL, without cutting short a longer
+	// retention such as that of a job parked until a later send time
+	ttl := r.jobTTL
+	if remaining, err := tx.PTTL(ctx, key).Result(); err == nil && remaining > ttl {
+		ttl = remaining
+	}
+
+	// Update job status
+	job.ApplyStatusChange(change)
+
+	// Store updated job
+	updated, err := json.Marshal(&job)
+	if err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to marshal updated job", err)
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetEx(ctx, key, string(updated), ttl)
+		return nil
+	})
+	if err == redis.TxFailedErr {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to update job", err)
+	}
+
+	return &job, nil
+}
+
 // DeleteJob removes a job from Redis
 func (r *RedisService) DeleteJob(ctx context.Context, jobID string) error {
 	if jobID == "" {
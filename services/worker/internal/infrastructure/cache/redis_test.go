@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// newTestRedisService connects to the Redis server named by REDIS_TEST_URL,
+// skipping the test when it is unset or the server is unreachable
+func newTestRedisService(t *testing.T) *RedisService {
+	t.Helper()
+
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	service, err := NewRedisService(url, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRedisService returned %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+
+	if err := service.Ping(context.Background()); err != nil {
+		t.Skipf("Redis at %s unreachable: %v", url, err)
+	}
+	return service
+}
+
+// uniqueName returns a key name that no other test run uses
+func uniqueName(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+// storeTestJob stores a job in status with ttl and returns it
+func storeTestJob(t *testing.T, service *RedisService, status models.JobStatus, ttl time.Duration) *models.EmailJob {
+	t.Helper()
+
+	job := models.NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+	job.Status = status
+	if err := service.StoreJob(context.Background(), job, ttl); err != nil {
+		t.Fatalf("StoreJob returned %v", err)
+	}
+	return job
+}
+
+// storedJob reads the job record as stored by the fake server
+func storedJob(t *testing.T, server *fakeRedis) *models.EmailJob {
+	t.Helper()
+
+	data, _ := server.Get("job:job-1")
+	var job models.EmailJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		t.Fatalf("stored job does not decode: %v", err)
+	}
+	return &job
+}
+
+func TestApplyStatusChange(t *testing.T) {
+	server, service := startFakeRedis(t, time.Hour)
+	storeTestJob(t, service, models.JobStatusQueued, time.Hour)
+
+	change := &models.StatusChange{
+		Status:     models.JobStatusRetrying,
+		Message:    "Job requeued for retry",
+		Error:      "connection refused",
+		RetryCount: 1,
+		Details:    map[string]string{"retry_policy": "fixed"},
+	}
+	if err := service.ApplyStatusChange(context.Background(), "job-1", change); err != nil {
+		t.Fatalf("ApplyStatusChange returned %v", err)
+	}
+
+	job := storedJob(t, server)
+	if job.Status != models.JobStatusRetrying || job.RetryCount != 1 || job.LastError != "connection refused" {
+		t.Errorf("stored job is %s after %d retries with error %q", job.Status, job.RetryCount, job.LastError)
+	}
+	if len(job.History) != 1 || job.History[0].Details["retry_policy"] != "fixed" {
+		t.Errorf("history is %+v, want the retry with its details", job.History)
+	}
+
+	published := server.Published()
+	if len(published) != 1 {
+		t.Fatalf("%d updates published, want 1", len(published))
+	}
+	var update JobStatusUpdate
+	if err := json.Unmarshal([]byte(published[0]), &update); err != nil {
+		t.Fatalf("published update does not decode: %v", err)
+	}
+	if update.JobID != "job-1" || update.Status != models.JobStatusRetrying || update.RetryCount != 1 {
+		t.Errorf("published %+v", update)
+	}
+}
+
+func TestApplyStatusChangeRejectsTransition(t *testing.T) {
+	server, service := startFakeRedis(t, time.Hour)
+	storeTestJob(t, service, models.JobStatusCompleted, time.Hour)
+
+	err := service.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{Status: models.JobStatusProcessing})
+	if !errors.IsInvalidTransitionError(err) {
+		t.Fatalf("ApplyStatusChange returned %v, want an invalid transition error", err)
+	}
+	if job := storedJob(t, server); job.Status != models.JobStatusCompleted || len(job.History) != 0 {
+		t.Errorf("rejected change was stored: %s with history %+v", job.Status, job.History)
+	}
+	if published := server.Published(); len(published) != 0 {
+		t.Errorf("rejected change was published: %v", published)
+	}
+
+	err = service.ApplyStatusChange(context.Background(), "job-2", &models.StatusChange{Status: models.JobStatusProcessing})
+	if errors.ErrorCode(err) != errors.RedisErrorCode {
+		t.Errorf("ApplyStatusChange returned %v for a missing job, want a Redis error", err)
+	}
+}
+
+func TestApplyStatusChangeKeepsLongerTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored time.Duration
+		want   time.Duration
+	}{
+		{name: "shorter than the job TTL", stored: 10 * time.Minute, want: time.Hour},
+		{name: "longer than the job TTL", stored: 48 * time.Hour, want: 48 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, service := startFakeRedis(t, time.Hour)
+			storeTestJob(t, service, models.JobStatusScheduled, tt.stored)
+
+			if err := service.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{Status: models.JobStatusPending}); err != nil {
+				t.Fatalf("ApplyStatusChange returned %v", err)
+			}
+			if _, ttl := server.Get("job:job-1"); ttl > tt.want || ttl < tt.want-time.Minute {
+				t.Errorf("record expires in %v, want %v", ttl, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyStatusChangeRetriesAfterConflict(t *testing.T) {
+	server, service := startFakeRedis(t, time.Hour)
+	job := storeTestJob(t, service, models.JobStatusProcessing, time.Hour)
+
+	// Another worker records a status between our read and our write, once
+	var once sync.Once
+	server.beforeExec = func() {
+		once.Do(func() {
+			job.ApplyStatusChange(&models.StatusChange{Status: models.JobStatusProcessing, Message: "redelivered"})
+			data, _ := json.Marshal(job)
+			server.Set("job:job-1", string(data), time.Hour)
+		})
+	}
+
+	if err := service.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{Status: models.JobStatusCompleted}); err != nil {
+		t.Fatalf("ApplyStatusChange returned %v", err)
+	}
+	if got := server.Execs(); got != 2 {
+		t.Errorf("%d transactions, want the conflicting one and its retry", got)
+	}
+
+	stored := storedJob(t, server)
+	if stored.Status != models.JobStatusCompleted || len(stored.History) != 2 || stored.History[0].Message != "redelivered" {
+		t.Errorf("stored %s with history %+v, want the other write kept before completed", stored.Status, stored.History)
+	}
+}
+
+func TestApplyStatusChangeGivesUpUnderConstantConflict(t *testing.T) {
+	server, service := startFakeRedis(t, time.Hour)
+	storeTestJob(t, service, models.JobStatusProcessing, time.Hour)
+
+	server.beforeExec = func() {
+		data, _ := server.Get("job:job-1")
+		server.Set("job:job-1", data, time.Hour)
+	}
+
+	err := service.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{Status: models.JobStatusCompleted})
+	if err == nil || !strings.Contains(err.Error(), "kept changing") {
+		t.Fatalf("ApplyStatusChange returned %v, want it to give up", err)
+	}
+	if got := server.Execs(); got != maxStatusUpdateAttempts {
+		t.Errorf("%d transactions, want %d", got, maxStatusUpdateAttempts)
+	}
+	if job := storedJob(t, server); job.Status != models.JobStatusProcessing {
+		t.Errorf("job ended %s, want it untouched", job.Status)
+	}
+}
+
+func TestApplyStatusChangeConcurrentWriters(t *testing.T) {
+	server, service := startFakeRedis(t, time.Hour)
+	storeTestJob(t, service, models.JobStatusProcessing, time.Hour)
+
+	// Every writer wins a round eventually, so none of their entries is lost
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- service.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{
+				Status:  models.JobStatusProcessing,
+				Message: fmt.Sprintf("writer %d", i),
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ApplyStatusChange returned %v", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range storedJob(t, server).History {
+		seen[entry.Message] = true
+	}
+	if len(seen) != writers {
+		t.Errorf("history holds %d of %d writers' entries: %v", len(seen), writers, seen)
+	}
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+const (
+	// scheduledJobsKey is a sorted set of parked job IDs scored by send time in
+	// milliseconds; scheduledPayloadsKey holds each parked job as JSON
+	scheduledJobsKey     = "scheduled_jobs"
+	scheduledPayloadsKey = "scheduled_jobs:payloads"
+)
+
+// acquireLockScript takes a lock that is free, or extends it when the caller
+// already holds it
+var acquireLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// releaseLockScript deletes a lock only if the caller still holds it
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// ScheduleJob parks a job until its send time. The job record is kept for
+// retention past the send time, so its status outlives a long wait.
+func (r *RedisService) ScheduleJob(ctx context.Context, job *models.EmailJob, retention time.Duration) error {
+	if err := job.Validate(); err != nil {
+		return errors.NewValidationErrorWithCause("invalid job", err)
+	}
+	if job.SendAt == nil {
+		return errors.NewValidationError("job has no send time")
+	}
+
+	jobData, err := json.Marshal(job)
+	if err != nil {
+		return errors.NewRedisErrorWithCause("failed to marshal job", err)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, scheduledPayloadsKey, job.JobID, jobData)
+		pipe.ZAdd(ctx, scheduledJobsKey, redis.Z{
+			Score:  float64(job.SendAt.UnixMilli()),
+			Member: job.JobID,
+		})
+		pipe.Expire(ctx, fmt.Sprintf("job:%s", job.JobID), time.Until(*job.SendAt)+retention)
+		return nil
+	})
+	if err != nil {
+		return errors.NewRedisErrorWithCause("failed to schedule job", err)
+	}
+
+	return nil
+}
+
+// DueJobs returns up to limit parked jobs whose send time is not after now,
+// earliest first. Jobs stay parked until UnscheduleJob removes them.
+func (r *RedisService) DueJobs(ctx context.Context, now time.Time, limit int) ([]*models.EmailJob, error) {
+	ids, err := r.client.ZRangeByScore(ctx, scheduledJobsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to list due jobs", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	payloads, err := r.client.HMGet(ctx, scheduledPayloadsKey, ids...).Result()
+	if err != nil {
+		return nil, errors.NewRedisErrorWithCause("failed to get due jobs", err)
+	}
+
+	jobs := make([]*models.EmailJob, 0, len(ids))
+	for i, payload := range payloads {
+		data, ok := payload.(string)
+		var job models.EmailJob
+		if !ok || json.Unmarshal([]byte(data), &job) != nil {
+			// Nothing left to send, drop the entry so it is not listed forever
+			if err := r.UnscheduleJob(ctx, ids[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
+// UnscheduleJob removes a parked job
+func (r *RedisService) UnscheduleJob(ctx context.Context, jobID string) error {
+	if jobID == "" {
+		return errors.NewValidationError("job ID is required")
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, scheduledJobsKey, jobID)
+		pipe.HDel(ctx, scheduledPayloadsKey, jobID)
+		return nil
+	})
+	if err != nil {
+		return errors.NewRedisErrorWithCause("failed to unschedule job", err)
+	}
+
+	return nil
+}
+
+// AcquireLock takes the named lock for owner, or extends it if owner already
+// holds it. The lock expires after ttl unless extended again.
+func (r *RedisService) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	held, err := acquireLockScript.Run(ctx, r.client, []string{lockKey(name)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.NewRedisErrorWithCause("failed to acquire lock", err)
+	}
+	return held == 1, nil
+}
+
+// ReleaseLock gives up the named lock if owner holds it
+func (r *RedisService) ReleaseLock(ctx context.Context, name, owner string) error {
+	if err := releaseLockScript.Run(ctx, r.client, []string{lockKey(name)}, owner).Err(); err != nil {
+		return errors.NewRedisErrorWithCause("failed to release lock", err)
+	}
+	return nil
+}
+
+func lockKey(name string) string {
+	return fmt.Sprintf("lock:%s", name)
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// dueIDs returns the IDs among ids that DueJobs lists at now, in its order.
+// The scheduled set is shared, so jobs of other tests are left out.
+func dueIDs(t *testing.T, service *RedisService, now time.Time, ids ...string) []string {
+	t.Helper()
+
+	jobs, err := service.DueJobs(context.Background(), now, 1000)
+	if err != nil {
+		t.Fatalf("DueJobs returned %v", err)
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var due []string
+	for _, job := range jobs {
+		if wanted[job.JobID] {
+			due = append(due, job.JobID)
+		}
+	}
+	return due
+}
+
+func TestScheduledJobs(t *testing.T) {
+	service := newTestRedisService(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Far enough ahead that no other parked job is due before them
+	base := now.Add(50 * 365 * 24 * time.Hour)
+	first, second := uniqueName(t)+"-1", uniqueName(t)+"-2"
+	for _, id := range []string{first, second} {
+		t.Cleanup(func() { service.UnscheduleJob(ctx, id) })
+	}
+
+	for i, id := range []string{second, first} {
+		job := models.NewEmailJob(id, "jane@example.com", "Hello", "Body text", 3)
+		sendAt := base.Add(time.Duration(2-i) * time.Hour)
+		job.SendAt = &sendAt
+		if err := service.StoreJob(ctx, job, time.Hour); err != nil {
+			t.Fatalf("StoreJob returned %v", err)
+		}
+		if err := service.ScheduleJob(ctx, job, time.Hour); err != nil {
+			t.Fatalf("ScheduleJob returned %v", err)
+		}
+	}
+
+	// The job record lives until the retention after the send time
+	ttl, err := service.client.TTL(ctx, "job:"+first).Result()
+	if err != nil || ttl < time.Until(base) {
+		t.Errorf("job record expires in %v (%v), want past its send time", ttl, err)
+	}
+
+	if got := dueIDs(t, service, now, first, second); len(got) != 0 {
+		t.Errorf("due now: %v, want none", got)
+	}
+	if got := dueIDs(t, service, base.Add(time.Hour), first, second); len(got) != 1 || got[0] != first {
+		t.Errorf("due after the first send time: %v, want %s", got, first)
+	}
+	if got := dueIDs(t, service, base.Add(3*time.Hour), first, second); len(got) != 2 || got[0] != first {
+		t.Errorf("due after both send times: %v, want %s first", got, first)
+	}
+
+	// Listing does not unpark; UnscheduleJob does
+	if err := service.UnscheduleJob(ctx, first); err != nil {
+		t.Fatalf("UnscheduleJob returned %v", err)
+	}
+	if got := dueIDs(t, service, base.Add(3*time.Hour), first, second); len(got) != 1 || got[0] != second {
+		t.Errorf("due after unscheduling %s: %v, want %s", first, got, second)
+	}
+}
+
+func TestDueJobsDropsUnreadablePayloads(t *testing.T) {
+	service := newTestRedisService(t)
+	ctx := context.Background()
+
+	id := uniqueName(t)
+	t.Cleanup(func() { service.UnscheduleJob(ctx, id) })
+	sendAt := time.Now().Add(60 * 365 * 24 * time.Hour)
+	service.client.ZAdd(ctx, scheduledJobsKey, redis.Z{Score: float64(sendAt.UnixMilli()), Member: id})
+	service.client.HSet(ctx, scheduledPayloadsKey, id, "not json")
+
+	if got := dueIDs(t, service, sendAt, id); len(got) != 0 {
+		t.Errorf("listed unreadable job %v", got)
+	}
+	if score, err := service.client.ZScore(ctx, scheduledJobsKey, id).Result(); err == nil {
+		t.Errorf("unreadable job still parked with score %v", score)
+	}
+}
+
+func TestScheduleJobValidation(t *testing.T) {
+	// Invalid jobs are turned away before anything reaches the server
+	_, service := startFakeRedis(t, time.Hour)
+
+	unscheduled := models.NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+	if err := service.ScheduleJob(context.Background(), unscheduled, time.Hour); !errors.IsValidationError(err) {
+		t.Errorf("ScheduleJob returned %v without a send time, want a validation error", err)
+	}
+
+	sendAt := time.Now().Add(time.Hour)
+	invalid := models.NewEmailJob("job-1", "not an address", "Hello", "Body text", 3)
+	invalid.SendAt = &sendAt
+	if err := service.ScheduleJob(context.Background(), invalid, time.Hour); !errors.IsValidationError(err) {
+		t.Errorf("ScheduleJob returned %v for an invalid job, want a validation error", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	service := newTestRedisService(t)
+	ctx := context.Background()
+	name := uniqueName(t)
+	t.Cleanup(func() { service.client.Del(ctx, lockKey(name)) })
+
+	acquire := func(owner string, ttl time.Duration, want bool) {
+		t.Helper()
+		held, err := service.AcquireLock(ctx, name, owner, ttl)
+		if err != nil {
+			t.Fatalf("AcquireLock(%s) returned %v", owner, err)
+		}
+		if held != want {
+			t.Fatalf("AcquireLock(%s) = %v, want %v", owner, held, want)
+		}
+	}
+
+	acquire("a", time.Second, true)
+	acquire("b", time.Second, false)
+
+	// The holder extends its lease
+	acquire("a", time.Minute, true)
+	if ttl, _ := service.client.PTTL(ctx, lockKey(name)).Result(); ttl <= time.Second {
+		t.Errorf("lock expires in %v after renewal, want about a minute", ttl)
+	}
+
+	// Only the holder can release it
+	if err := service.ReleaseLock(ctx, name, "b"); err != nil {
+		t.Fatalf("ReleaseLock returned %v", err)
+	}
+	acquire("b", time.Second, false)
+	if err := service.ReleaseLock(ctx, name, "a"); err != nil {
+		t.Fatalf("ReleaseLock returned %v", err)
+	}
+	acquire("b", 50*time.Millisecond, true)
+
+	// A holder that stops renewing loses the lock
+	time.Sleep(100 * time.Millisecond)
+	acquire("a", time.Second, true)
+}
@@ -0,0 +1,199 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// sniffLength is the number of bytes http.DetectContentType looks at
+const sniffLength = 512
+
+// loadAttachment reads an attachment's content, inline or from the blob directory
+func loadAttachment(blobDir string, attachment *models.Attachment) ([]byte, error) {
+	if !attachment.IsBlob() {
+		content, err := attachment.DecodedContent()
+		if err != nil {
+			return nil, errors.NewValidationErrorWithCause("invalid attachment content", err)
+		}
+		return content, nil
+	}
+
+	if blobDir == "" {
+		return nil, errors.NewConfigError("attachment blob directory is not configured")
+	}
+
+	// Validate already rejects refs that escape the directory; check again
+	// before touching the filesystem
+	if !filepath.IsLocal(filepath.FromSlash(attachment.BlobRef)) {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid attachment blob_ref %q", attachment.BlobRef))
+	}
+
+	file, err := os.Open(filepath.Join(blobDir, filepath.FromSlash(attachment.BlobRef)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NewValidationErrorWithCause(fmt.Sprintf("attachment blob %s not found", attachment.BlobRef), err)
+		}
+		return nil, errors.NewJobProcessingErrorWithCause(fmt.Sprintf("failed to open attachment blob %s", attachment.BlobRef), err)
+	}
+	defer file.Close()
+
+	// Never read past the declared size the job was validated against
+	content, err := io.ReadAll(io.LimitReader(file, attachment.Size+1))
+	if err != nil {
+		return nil, errors.NewJobProcessingErrorWithCause(fmt.Sprintf("failed to read attachment blob %s", attachment.BlobRef), err)
+	}
+	if int64(len(content)) != attachment.Size {
+		return nil, errors.NewValidationError(fmt.Sprintf("attachment blob %s does not match its declared size of %d bytes", attachment.BlobRef, attachment.Size))
+	}
+
+	return content, nil
+}
+
+// newAttachmentEntity creates a base64-encoded part for an attachment
+func newAttachmentEntity(attachment *models.Attachment, content []byte) *mimeEntity {
+	filename := sanitizeFilename(attachment.Filename)
+
+	mediaType := attachment.ContentType
+	if mediaType == "" {
+		mediaType = detectContentType(filename, content)
+	}
+
+	disposition := attachment.Disposition
+	if disposition == "" {
+		disposition = models.DispositionAttachment
+	}
+
+	header := []headerField{
+		{"Content-Type", formatParameterizedValue(mediaType, "name", filename)},
+		{"Content-Transfer-Encoding", "base64"},
+		{"Content-Disposition", formatParameterizedValue(disposition, "filename", filename)},
+	}
+	if attachment.ContentID != "" {
+		header = append(header, headerField{"Content-ID", "<" + attachment.ContentID + ">"})
+	}
+
+	return &mimeEntity{mediaType: mediaType, header: header, body: encodeBase64Lines(content)}
+}
+
+// detectContentType picks a media type from the file extension, falling back
+// to sniffing the content
+func detectContentType(filename string, content []byte) string {
+	if mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(filename))); mediaType != "" {
+		return mediaType
+	}
+	return http.DetectContentType(content[:min(len(content), sniffLength)])
+}
+
+// sanitizeFilename keeps only the final path element of a filename and drops
+// characters that are unsafe in a header
+func sanitizeFilename(filename string) string {
+	filename = filename[strings.LastIndexAny(filename, `/\`)+1:]
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+
+	if filename == "" || filename == "." || filename == ".." {
+		return "attachment"
+	}
+	return filename
+}
+
+// formatParameterizedValue formats a header value with a single parameter such
+// as a filename. Plain short values are quoted; non-ASCII or long values use
+// RFC 2231 percent-encoding split into continuations. Every parameter goes on
+// its own folded line.
+func formatParameterizedValue(value, param, paramValue string) string {
+	var b strings.Builder
+	b.WriteString(value)
+
+	for _, segment := range encodeParameter(param, paramValue) {
+		b.WriteString(";\r\n " + segment)
+	}
+	return b.String()
+}
+
+// encodeParameter renders name=value as one quoted parameter, or as UTF-8
+// percent-encoded RFC 2231 continuations (name*0*, name*1*, ...) when the
+// value needs encoding or is too long for one line. Every segment fits a folded
+// line of recommendedLineLength, counting its leading space and trailing ';'.
+func encodeParameter(name, value string) []string {
+	fits := func(segment string) bool { return len(segment)+2 <= recommendedLineLength }
+
+	if quoted := name + `="` + value + `"`; isPlainParameterValue(value) && fits(quoted) {
+		return []string{quoted}
+	}
+
+	encoded := percentEncode(value)
+	if single := name + "*=UTF-8''" + encoded; fits(single) {
+		return []string{single}
+	}
+
+	var segments []string
+	for i := 0; len(encoded) > 0; i++ {
+		prefix := fmt.Sprintf("%s*%d*=", name, i)
+		if i == 0 {
+			prefix += "UTF-8''"
+		}
+
+		n := min(len(encoded), recommendedLineLength-2-len(prefix))
+		// Never split a %XX escape across segments
+		if idx := strings.LastIndexByte(encoded[max(n-2, 0):n], '%'); idx >= 0 && n < len(encoded) {
+			n = max(n-2, 0) + idx
+		}
+
+		segments = append(segments, prefix+encoded[:n])
+		encoded = encoded[n:]
+	}
+	return segments
+}
+
+// isPlainParameterValue reports whether value can be sent as a quoted string
+// without encoding
+func isPlainParameterValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// percentEncode escapes everything outside the RFC 2231 attribute-char set
+func percentEncode(value string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isAttributeChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isAttributeChar reports whether c may appear unescaped in an RFC 2231 value
+func isAttributeChar(c byte) bool {
+	if c <= ' ' || c >= 0x7f {
+		return false
+	}
+	return !strings.ContainsRune(`*'%()<>@,;:\"/[]?=`, rune(c))
+}
@@ -0,0 +1,316 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// mimePart is an entity of a parsed message, with its transfer encoding undone
+type mimePart struct {
+	header    textproto.MIMEHeader
+	mediaType string
+	params    map[string]string
+	raw       []byte
+	body      []byte
+	parts     []*mimePart
+}
+
+// parseMessage parses a rendered message with net/mail and mime/multipart
+func parseMessage(t *testing.T, message []byte) (mail.Header, *mimePart) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatalf("message does not parse: %v\n%s", err, message)
+	}
+	return msg.Header, parseEntity(t, textproto.MIMEHeader(msg.Header), msg.Body)
+}
+
+// parseEntity parses an entity and, for multiparts, each of its parts
+func parseEntity(t *testing.T, header textproto.MIMEHeader, body io.Reader) *mimePart {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid Content-Type %q: %v", header.Get("Content-Type"), err)
+	}
+	part := &mimePart{header: header, mediaType: mediaType, params: params}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			// Raw parts keep their transfer encoding, so it can be checked
+			child, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid %s part: %v", mediaType, err)
+			}
+			part.parts = append(part.parts, parseEntity(t, child.Header, child))
+		}
+		return part
+	}
+
+	if part.raw, err = io.ReadAll(body); err != nil {
+		t.Fatalf("failed to read %s part: %v", mediaType, err)
+	}
+	switch encoding := header.Get("Content-Transfer-Encoding"); encoding {
+	case "base64":
+		part.body, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(string(part.raw), "\r\n", ""))
+	case "quoted-printable":
+		part.body, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(part.raw)))
+	case "7bit", "8bit", "":
+		part.body = part.raw
+	default:
+		t.Fatalf("unexpected transfer encoding %q", encoding)
+	}
+	if err != nil {
+		t.Fatalf("failed to decode %s part: %v", mediaType, err)
+	}
+	return part
+}
+
+// structure describes the tree of media types below part, e.g.
+// "multipart/mixed[text/plain application/pdf]"
+func (p *mimePart) structure() string {
+	if len(p.parts) == 0 {
+		return p.mediaType
+	}
+	children := make([]string, len(p.parts))
+	for i, child := range p.parts {
+		children[i] = child.structure()
+	}
+	return p.mediaType + "[" + strings.Join(children, " ") + "]"
+}
+
+// newTestBuilder returns a builder for messages from sender@example.com
+func newTestBuilder(blobDir string) *messageBuilder {
+	return newMessageBuilder(&EmailConfig{From: "sender@example.com", BlobDir: blobDir})
+}
+
+func TestBuildWithAttachments(t *testing.T) {
+	blobDir := t.TempDir()
+	pdf := []byte("%PDF-1.7\n" + strings.Repeat("report ", 100))
+	if err := os.MkdirAll(filepath.Join(blobDir, "jobs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(blobDir, "jobs", "report.pdf"), pdf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logo := []byte("\x89PNG\r\n\x1a\n logo")
+
+	job := newTestJob("jane@example.org")
+	job.HTMLBody = `<p>See <img src="cid:logo@example.com"></p>`
+	job.Attachments = []models.Attachment{
+		{Filename: "logo.png", Content: base64.StdEncoding.EncodeToString(logo), Disposition: models.DispositionInline, ContentID: "logo@example.com"},
+		{Filename: "report.pdf", BlobRef: "jobs/report.pdf", Size: int64(len(pdf))},
+	}
+
+	message, _, err := newTestBuilder(blobDir).build(job, time.Now())
+	if err != nil {
+		t.Fatalf("build returned %v", err)
+	}
+	_, root := parseMessage(t, message)
+
+	want := "multipart/mixed[multipart/related[multipart/alternative[text/plain text/html] image/png] application/pdf]"
+	if got := root.structure(); got != want {
+		t.Fatalf("message structure is %s, want %s", got, want)
+	}
+
+	related := root.parts[0]
+	if related.params["type"] != "multipart/alternative" {
+		t.Errorf("multipart/related names root type %q, want multipart/alternative", related.params["type"])
+	}
+	inline := related.parts[1]
+	if !bytes.Equal(inline.body, logo) {
+		t.Errorf("inline part decodes to %q, want the logo", inline.body)
+	}
+	if got := inline.header.Get("Content-ID"); got != "<logo@example.com>" {
+		t.Errorf("inline part Content-ID %q, want <logo@example.com>", got)
+	}
+	if disposition, _, _ := mime.ParseMediaType(inline.header.Get("Content-Disposition")); disposition != "inline" {
+		t.Errorf("inline part disposition %q", disposition)
+	}
+
+	attached := root.parts[1]
+	if !bytes.Equal(attached.body, pdf) {
+		t.Error("PDF attachment does not decode to the blob content")
+	}
+	disposition, params, err := mime.ParseMediaType(attached.header.Get("Content-Disposition"))
+	if err != nil || disposition != "attachment" || params["filename"] != "report.pdf" {
+		t.Errorf("attachment disposition %q %v (%v), want attachment with filename report.pdf", disposition, params, err)
+	}
+	if attached.params["name"] != "report.pdf" {
+		t.Errorf("attachment Content-Type name %q, want report.pdf", attached.params["name"])
+	}
+	for _, line := range strings.Split(string(attached.raw), "\r\n") {
+		if len(line) > encodedLineLength {
+			t.Fatalf("base64 line of %d characters, want at most %d", len(line), encodedLineLength)
+		}
+	}
+}
+
+func TestBuildInlineWithoutHTMLIsAttached(t *testing.T) {
+	job := newTestJob("jane@example.org")
+	job.Attachments = []models.Attachment{
+		{Filename: "logo.png", Content: base64.StdEncoding.EncodeToString([]byte("logo")), Disposition: models.DispositionInline, ContentID: "logo@example.com"},
+	}
+
+	message, _, err := newTestBuilder("").build(job, time.Now())
+	if err != nil {
+		t.Fatalf("build returned %v", err)
+	}
+	_, root := parseMessage(t, message)
+
+	// Nothing could reference the image, so it is not related to the body
+	if got := root.structure(); got != "multipart/mixed[text/plain image/png]" {
+		t.Errorf("message structure is %s, want multipart/mixed[text/plain image/png]", got)
+	}
+}
+
+func TestAttachmentFilenameParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{name: "plain", filename: "report.pdf", want: `filename="report.pdf"`},
+		{name: "quote", filename: `say "hi".txt`, want: `filename*=UTF-8''say%20%22hi%22.txt`},
+		{name: "non-ASCII", filename: "résumé.pdf", want: `filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{name: "long ASCII", filename: strings.Repeat("quarterly-report-", 5) + ".pdf", want: "filename*0*=UTF-8''quarterly-report-"},
+		{name: "long non-ASCII", filename: strings.Repeat("rapport-trimestriel-é", 4) + ".pdf", want: "filename*1*="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := formatParameterizedValue("attachment", "filename", tt.filename)
+			if !strings.Contains(value, tt.want) {
+				t.Errorf("header value %q lacks %q", value, tt.want)
+			}
+
+			for _, line := range strings.Split(value, "\r\n") {
+				if len(line) > recommendedLineLength {
+					t.Errorf("line of %d characters: %q", len(line), line)
+				}
+				// A %XX escape split over two continuations cannot be decoded
+				if i := strings.LastIndexByte(line, '%'); i >= 0 && i > len(line)-3 {
+					t.Errorf("continuation ends inside an escape: %q", line)
+				}
+			}
+
+			// mime.ParseMediaType joins and decodes RFC 2231 continuations
+			unfolded := strings.ReplaceAll(value, "\r\n", "")
+			disposition, params, err := mime.ParseMediaType(unfolded)
+			if err != nil {
+				t.Fatalf("ParseMediaType(%q) returned %v", unfolded, err)
+			}
+			if disposition != "attachment" || params["filename"] != tt.filename {
+				t.Errorf("parsed %q with filename %q, want attachment with %q", disposition, params["filename"], tt.filename)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		filename string
+		content  []byte
+		want     string
+	}{
+		{filename: "report.pdf", content: []byte("anything"), want: "application/pdf"},
+		{filename: "PHOTO.PNG", content: []byte("anything"), want: "image/png"},
+		{filename: "image", content: png, want: "image/png"},
+		{filename: "document", content: []byte("%PDF-1.7\n"), want: "application/pdf"},
+		{filename: "notes", content: []byte("plain words"), want: "text/plain; charset=utf-8"},
+		{filename: "data", content: []byte{0x00, 0x01, 0x02, 0xfe}, want: "application/octet-stream"},
+		{filename: "empty", content: nil, want: "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		if got := detectContentType(tt.filename, tt.content); got != tt.want {
+			t.Errorf("detectContentType(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+
+	// Only the first bytes are sniffed; a PNG signature further in is ignored
+	late := append(bytes.Repeat([]byte{0}, sniffLength), png...)
+	if got := detectContentType("image", late); got == "image/png" {
+		t.Errorf("detectContentType sniffed past %d bytes", sniffLength)
+	}
+
+	// An explicit content type wins over detection
+	entity := newAttachmentEntity(&models.Attachment{Filename: "image", ContentType: "image/x-custom"}, png)
+	if entity.mediaType != "image/x-custom" {
+		t.Errorf("attachment with a content type sent as %q", entity.mediaType)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":           "report.pdf",
+		"../../etc/passwd":     "passwd",
+		`C:\Users\jane\a.docx`: "a.docx",
+		"evil\r\nBcc: x.txt":   "evilBcc: x.txt",
+		"dir/":                 "attachment",
+		"..":                   "attachment",
+		"":                     "attachment",
+	}
+
+	for input, want := range tests {
+		if got := sanitizeFilename(input); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestLoadAttachmentBlob(t *testing.T) {
+	blobDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(blobDir, "a.bin"), []byte("12345"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		blobDir    string
+		attachment models.Attachment
+		wantCode   string
+	}{
+		{name: "declared size", blobDir: blobDir, attachment: models.Attachment{BlobRef: "a.bin", Size: 5}},
+		{name: "size mismatch", blobDir: blobDir, attachment: models.Attachment{BlobRef: "a.bin", Size: 4}, wantCode: errors.ValidationErrorCode},
+		{name: "missing blob", blobDir: blobDir, attachment: models.Attachment{BlobRef: "b.bin", Size: 5}, wantCode: errors.ValidationErrorCode},
+		{name: "escaping ref", blobDir: blobDir, attachment: models.Attachment{BlobRef: "../a.bin", Size: 5}, wantCode: errors.ValidationErrorCode},
+		{name: "no blob directory", attachment: models.Attachment{BlobRef: "a.bin", Size: 5}, wantCode: errors.ConfigErrorCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := loadAttachment(tt.blobDir, &tt.attachment)
+			if tt.wantCode == "" {
+				if err != nil || string(content) != "12345" {
+					t.Errorf("loadAttachment returned %q, %v", content, err)
+				}
+				return
+			}
+			if errors.ErrorCode(err) != tt.wantCode {
+				t.Errorf("loadAttachment returned %v, want a %s", err, tt.wantCode)
+			}
+		})
+	}
+}
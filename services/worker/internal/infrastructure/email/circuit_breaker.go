@@ -0,0 +1,107 @@
+package email
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreakerConfig controls when a backend is taken out of rotation: after
+// FailureThreshold consecutive failures it is skipped for OpenTimeout, then a
+// single trial send decides whether it is put back
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// circuitBreaker tracks consecutive failures of one backend
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// trial is set while the single half-open trial send is in flight
+	trial bool
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{config: config, now: time.Now, state: CircuitClosed}
+}
+
+// allow reports whether a send may go to the backend now. Once the open
+// timeout has passed, one caller is let through as a trial.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+		return true
+	case CircuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// success closes the circuit and clears the failure count
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// failure counts a failed send, opening the circuit at the threshold or when
+// a half-open trial fails
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == CircuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release gives up a half-open trial whose outcome says nothing about the
+// backend, such as a message the backend rightly refused
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// currentState returns the breaker's state for health reporting
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
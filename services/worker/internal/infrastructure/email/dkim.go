@@ -0,0 +1,474 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DKIM canonicalization algorithms (RFC 6376 section 3.4)
+const (
+	DKIMCanonicalizationSimple  = "simple"
+	DKIMCanonicalizationRelaxed = "relaxed"
+)
+
+// DefaultDKIMHeaders are the header fields signed when none are configured.
+// Fields missing from a message are skipped.
+var DefaultDKIMHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// DKIMOptions configures a DKIMSigner. The key is taken from PrivateKey when
+// set, otherwise it is loaded from the PEM file at PrivateKeyFile.
+type DKIMOptions struct {
+	Domain   string
+	Selector string
+
+	PrivateKeyFile string
+	PrivateKey     crypto.Signer
+
+	// Canonicalization is "header/body", e.g. "relaxed/simple"; a single
+	// algorithm applies to both. Defaults to relaxed/relaxed.
+	Canonicalization string
+
+	// Headers lists the header fields to sign; defaults to DefaultDKIMHeaders
+	Headers []string
+}
+
+// DKIMSigner adds an RFC 6376 DKIM-Signature header to outgoing messages,
+// using RSA-SHA256 or Ed25519-SHA256 (RFC 8463) depending on the key
+type DKIMSigner struct {
+	domain      string
+	selector    string
+	key         crypto.Signer
+	algorithm   string
+	headerCanon string
+	bodyCanon   string
+	headers     []string
+	now         func() time.Time
+}
+
+// NewDKIMSigner validates the options and loads the signing key
+func NewDKIMSigner(options DKIMOptions) (*DKIMSigner, error) {
+	if options.Domain == "" || options.Selector == "" {
+		return nil, fmt.Errorf("DKIM domain and selector are required")
+	}
+
+	headerCanon, bodyCanon, err := parseCanonicalization(options.Canonicalization)
+	if err != nil {
+		return nil, err
+	}
+
+	key := options.PrivateKey
+	if key == nil {
+		if key, err = LoadDKIMPrivateKey(options.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var algorithm string
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 1024 {
+			return nil, fmt.Errorf("DKIM RSA keys must be at least 1024 bits")
+		}
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+
+	headers := options.Headers
+	if len(headers) == 0 {
+		headers = DefaultDKIMHeaders
+	}
+	signsFrom := false
+	for _, header := range headers {
+		if strings.EqualFold(header, "From") {
+			signsFrom = true
+		}
+	}
+	if !signsFrom {
+		return nil, fmt.Errorf("DKIM signed headers must include From")
+	}
+
+	return &DKIMSigner{
+		domain:      options.Domain,
+		selector:    options.Selector,
+		key:         key,
+		algorithm:   algorithm,
+		headerCanon: headerCanon,
+		bodyCanon:   bodyCanon,
+		headers:     headers,
+		now:         time.Now,
+	}, nil
+}
+
+// LoadDKIMPrivateKey reads an RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8)
+// private key from a PEM file
+func LoadDKIMPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("DKIM private key file %s contains no PEM block", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+	return signer, nil
+}
+
+// DKIMPublicKeyRecord returns the DNS TXT record value publishing a public key
+// at <selector>._domainkey.<domain>
+func DKIMPublicKeyRecord(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(key), nil
+	default:
+		return "", fmt.Errorf("unsupported DKIM key type %T", publicKey)
+	}
+}
+
+// Sign returns the message with a DKIM-Signature header prepended
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	headers, body := splitMessage(message)
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body, s.bodyCanon))
+
+	signed := selectSignedHeaders(headers, s.headers)
+	names := make([]string, len(signed))
+	for i, field := range signed {
+		names[i] = field.name
+	}
+
+	// The signature covers this header with an empty b= tag, so b= comes last
+	// and the signature value is appended after signing
+	var signature strings.Builder
+	signature.WriteString("DKIM-Signature: v=1; a=" + s.algorithm)
+	signature.WriteString("; c=" + s.headerCanon + "/" + s.bodyCanon)
+	signature.WriteString(";\r\n d=" + s.domain + "; s=" + s.selector)
+	signature.WriteString("; t=" + strconv.FormatInt(s.now().Unix(), 10))
+	signature.WriteString(";\r\n h=" + foldTagValue(strings.Join(names, ":"), ":"))
+	signature.WriteString(";\r\n bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]))
+	signature.WriteString(";\r\n b=")
+
+	digest := signatureDigest(signed, signature.String(), s.headerCanon)
+
+	var sig []byte
+	var err error
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the SHA-256 hash with PureEdDSA
+		sig = ed25519.Sign(key, digest)
+	default:
+		sig, err = s.key.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	signature.WriteString(foldBase64(base64.StdEncoding.EncodeToString(sig)))
+	signature.WriteString("\r\n")
+
+	return append([]byte(signature.String()), message...), nil
+}
+
+// VerifyDKIM checks the first DKIM-Signature of a message against a public key
+// instead of a DNS lookup, so signed output can be verified locally
+func VerifyDKIM(message []byte, publicKey crypto.PublicKey) error {
+	headers, body := splitMessage(message)
+
+	var signatureField *rawHeader
+	for i := range headers {
+		if strings.EqualFold(headers[i].name, "DKIM-Signature") {
+			signatureField = &headers[i]
+			break
+		}
+	}
+	if signatureField == nil {
+		return fmt.Errorf("message has no DKIM-Signature header")
+	}
+
+	tags, err := parseDKIMTags(signatureField.value())
+	if err != nil {
+		return err
+	}
+	for _, tag := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[tag]; !ok {
+			return fmt.Errorf("DKIM-Signature is missing the %s= tag", tag)
+		}
+	}
+	if tags["v"] != "1" {
+		return fmt.Errorf("unsupported DKIM version %s", tags["v"])
+	}
+
+	headerCanon, bodyCanon, err := parseCanonicalization(tags["c"])
+	if err != nil {
+		return err
+	}
+	if _, ok := tags["c"]; !ok {
+		// RFC 6376 defaults to simple/simple when c= is absent
+		headerCanon, bodyCanon = DKIMCanonicalizationSimple, DKIMCanonicalizationSimple
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body, bodyCanon))
+	expectedBodyHash, err := base64.StdEncoding.DecodeString(stripWhitespace(tags["bh"]))
+	if err != nil || !bytes.Equal(bodyHash[:], expectedBodyHash) {
+		return fmt.Errorf("DKIM body hash does not match")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(stripWhitespace(tags["b"]))
+	if err != nil {
+		return fmt.Errorf("DKIM signature is not valid base64")
+	}
+
+	// The signature header itself is verified with its b= value removed
+	unsigned := dkimSignatureValue.ReplaceAllString(signatureField.raw, "${1}")
+	signed := selectSignedHeaders(headers, strings.Split(tags["h"], ":"))
+	digest := signatureDigest(signed, unsigned, headerCanon)
+
+	switch tags["a"] {
+	case "rsa-sha256":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("rsa-sha256 signature needs an RSA public key")
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig); err != nil {
+			return fmt.Errorf("DKIM signature does not verify: %w", err)
+		}
+	case "ed25519-sha256":
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("ed25519-sha256 signature needs an Ed25519 public key")
+		}
+		if !ed25519.Verify(key, digest, sig) {
+			return fmt.Errorf("DKIM signature does not verify")
+		}
+	default:
+		return fmt.Errorf("unsupported DKIM algorithm %s", tags["a"])
+	}
+
+	return nil
+}
+
+// dkimSignatureValue matches the value of the b= tag, keeping everything before it
+var dkimSignatureValue = regexp.MustCompile(`((?:^|[;\s])b\s*=)[^;]*`)
+
+// rawHeader is a header field exactly as it appears in a message, including
+// any folded continuation lines and the trailing CRLF
+type rawHeader struct {
+	name string
+	raw  string
+}
+
+// value returns everything after the colon, still folded
+func (h rawHeader) value() string {
+	_, value, _ := strings.Cut(h.raw, ":")
+	return value
+}
+
+// splitMessage separates a CRLF message into its header fields and body
+func splitMessage(message []byte) ([]rawHeader, []byte) {
+	headerBlock, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		headerBlock, body = message, nil
+	}
+
+	var headers []rawHeader
+	for _, line := range strings.SplitAfter(string(headerBlock)+"\r\n", "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1].raw += line
+			continue
+		}
+		name, _, _ := strings.Cut(line, ":")
+		headers = append(headers, rawHeader{name: strings.TrimSpace(name), raw: line})
+	}
+
+	return headers, body
+}
+
+// selectSignedHeaders picks the fields named in order. Repeated names take
+// instances from the bottom of the header up (RFC 6376 section 5.4.2), and
+// names with no instance left are skipped.
+func selectSignedHeaders(headers []rawHeader, names []string) []rawHeader {
+	used := make(map[int]bool)
+	var selected []rawHeader
+
+	for _, name := range names {
+		for i := len(headers) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(headers[i].name, strings.TrimSpace(name)) {
+				used[i] = true
+				selected = append(selected, headers[i])
+				break
+			}
+		}
+	}
+
+	return selected
+}
+
+// signatureDigest hashes the canonicalized signed headers followed by the
+// DKIM-Signature header without its trailing CRLF
+func signatureDigest(signed []rawHeader, signatureHeader, canon string) []byte {
+	hash := sha256.New()
+	for _, field := range signed {
+		hash.Write([]byte(canonicalizeHeader(field.raw, canon)))
+	}
+
+	signature := canonicalizeHeader(strings.TrimSuffix(signatureHeader, "\r\n")+"\r\n", canon)
+	hash.Write([]byte(strings.TrimSuffix(signature, "\r\n")))
+
+	return hash.Sum(nil)
+}
+
+var (
+	foldingWhitespace = regexp.MustCompile(`\r\n([ \t])`)
+	whitespaceRun     = regexp.MustCompile(`[ \t]+`)
+)
+
+// canonicalizeHeader applies the header canonicalization to one raw field
+func canonicalizeHeader(raw, canon string) string {
+	if canon == DKIMCanonicalizationSimple {
+		return raw
+	}
+
+	name, value, _ := strings.Cut(raw, ":")
+	value = foldingWhitespace.ReplaceAllString(strings.TrimSuffix(value, "\r\n"), "$1")
+	value = strings.TrimSpace(whitespaceRun.ReplaceAllString(value, " "))
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalizeBody applies the body canonicalization
+func canonicalizeBody(body []byte, canon string) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	if canon == DKIMCanonicalizationRelaxed {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(whitespaceRun.ReplaceAllString(line, " "), " ")
+		}
+	}
+
+	// Both algorithms ignore empty lines at the end of the body
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		// An empty body is a single CRLF for simple and nothing for relaxed
+		if canon == DKIMCanonicalizationSimple {
+			return []byte("\r\n")
+		}
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// parseCanonicalization splits "header/body"; a missing body algorithm is simple
+func parseCanonicalization(value string) (string, string, error) {
+	if value == "" {
+		return DKIMCanonicalizationRelaxed, DKIMCanonicalizationRelaxed, nil
+	}
+
+	header, body, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		body = DKIMCanonicalizationSimple
+	}
+
+	for _, canon := range []string{header, body} {
+		if canon != DKIMCanonicalizationSimple && canon != DKIMCanonicalizationRelaxed {
+			return "", "", fmt.Errorf("DKIM canonicalization must be simple or relaxed, got %q", value)
+		}
+	}
+
+	return header, body, nil
+}
+
+// parseDKIMTags parses a tag=value list
+func parseDKIMTags(value string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, tagValue, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed DKIM tag %q", strings.TrimSpace(pair))
+		}
+		tags[strings.TrimSpace(name)] = strings.TrimSpace(foldingWhitespace.ReplaceAllString(tagValue, "$1"))
+	}
+	return tags, nil
+}
+
+// stripWhitespace removes all whitespace, which base64 tag values may contain
+func stripWhitespace(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
+
+// foldTagValue breaks a long tag value after separators so header lines stay short
+func foldTagValue(value, separator string) string {
+	var b strings.Builder
+	lineLength := 0
+	for i, part := range strings.Split(value, separator) {
+		if i > 0 {
+			b.WriteString(separator)
+			lineLength++
+			if lineLength+len(part) > 60 {
+				b.WriteString("\r\n ")
+				lineLength = 0
+			}
+		}
+		b.WriteString(part)
+		lineLength += len(part)
+	}
+	return b.String()
+}
+
+// foldBase64 breaks a base64 value into folded lines
+func foldBase64(value string) string {
+	var b strings.Builder
+	for len(value) > encodedLineLength-4 {
+		b.WriteString(value[:encodedLineLength-4] + "\r\n ")
+		value = value[encodedLineLength-4:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
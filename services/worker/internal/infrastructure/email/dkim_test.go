@@ -0,0 +1,148 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+const dkimTestMessage = "From: Sender <sender@example.com>\r\n" +
+	"To: jane@example.org\r\n" +
+	"Subject:  Quarterly   report\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hello Jane,\r\n" +
+	"\r\n" +
+	"the report is attached.  \r\n" +
+	"\r\n" +
+	"\r\n"
+
+func dkimTestKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	return map[string]crypto.Signer{"rsa": rsaKey, "ed25519": edKey}
+}
+
+func TestDKIMSignVerify(t *testing.T) {
+	keys := dkimTestKeys(t)
+	canonicalizations := []string{"relaxed/relaxed", "relaxed/simple", "simple/relaxed", "simple/simple"}
+
+	for keyName, key := range keys {
+		for _, canon := range canonicalizations {
+			t.Run(keyName+"/"+canon, func(t *testing.T) {
+				signer, err := NewDKIMSigner(DKIMOptions{
+					Domain:           "example.com",
+					Selector:         "mail",
+					PrivateKey:       key,
+					Canonicalization: canon,
+				})
+				if err != nil {
+					t.Fatalf("NewDKIMSigner returned %v", err)
+				}
+
+				signed, err := signer.Sign([]byte(dkimTestMessage))
+				if err != nil {
+					t.Fatalf("Sign returned %v", err)
+				}
+				if !bytes.HasPrefix(signed, []byte("DKIM-Signature: ")) {
+					t.Fatalf("signed message does not start with the signature:\n%s", signed)
+				}
+				if err := VerifyDKIM(signed, key.Public()); err != nil {
+					t.Fatalf("VerifyDKIM returned %v", err)
+				}
+
+				tamperedBody := bytes.Replace(signed, []byte("attached"), []byte("missing"), 1)
+				if err := VerifyDKIM(tamperedBody, key.Public()); err == nil {
+					t.Error("VerifyDKIM accepted a tampered body")
+				}
+
+				tamperedHeader := bytes.Replace(signed, []byte("Quarterly"), []byte("Annual"), 1)
+				if err := VerifyDKIM(tamperedHeader, key.Public()); err == nil {
+					t.Error("VerifyDKIM accepted a tampered signed header")
+				}
+
+				other := dkimTestKeys(t)[keyName]
+				if err := VerifyDKIM(signed, other.Public()); err == nil {
+					t.Error("VerifyDKIM accepted the signature with another key")
+				}
+			})
+		}
+	}
+}
+
+func TestDKIMRelaxedToleratesWhitespace(t *testing.T) {
+	key := dkimTestKeys(t)["ed25519"]
+
+	tests := []struct {
+		canon  string
+		change func(string) string
+		valid  bool
+	}{
+		{
+			canon: "relaxed/relaxed",
+			change: func(m string) string {
+				return strings.Replace(m, "Subject:  Quarterly   report", "subject: Quarterly report", 1)
+			},
+			valid: true,
+		},
+		{
+			canon: "simple/relaxed",
+			change: func(m string) string {
+				return strings.Replace(m, "Subject:  Quarterly   report", "subject: Quarterly report", 1)
+			},
+		},
+		{
+			canon:  "relaxed/relaxed",
+			change: func(m string) string { return strings.Replace(m, "attached.  \r\n", "attached.\r\n", 1) },
+			valid:  true,
+		},
+		{
+			canon:  "relaxed/simple",
+			change: func(m string) string { return strings.Replace(m, "attached.  \r\n", "attached.\r\n", 1) },
+		},
+		{
+			canon:  "relaxed/simple",
+			change: func(m string) string { return strings.TrimSuffix(m, "\r\n\r\n") + "\r\n" },
+			valid:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		signer, err := NewDKIMSigner(DKIMOptions{
+			Domain:           "example.com",
+			Selector:         "mail",
+			PrivateKey:       key,
+			Canonicalization: tt.canon,
+		})
+		if err != nil {
+			t.Fatalf("NewDKIMSigner returned %v", err)
+		}
+		signed, err := signer.Sign([]byte(dkimTestMessage))
+		if err != nil {
+			t.Fatalf("Sign returned %v", err)
+		}
+
+		err = VerifyDKIM([]byte(tt.change(string(signed))), key.Public())
+		if tt.valid && err != nil {
+			t.Errorf("%s: VerifyDKIM returned %v, want the change tolerated", tt.canon, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: VerifyDKIM accepted the change", tt.canon)
+		}
+	}
+}
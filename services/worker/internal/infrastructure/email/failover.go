@@ -0,0 +1,148 @@
+package email
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// Backend is a named transport in a failover chain
+type Backend struct {
+	Name    string
+	Service EmailService
+}
+
+// BackendStatus describes a backend of a failover chain for health reporting
+type BackendStatus struct {
+	Name    string
+	Service EmailService
+	Circuit string
+}
+
+// FailoverService implements EmailService over an ordered list of backends.
+// Each message goes to the first backend whose circuit is closed; transient
+// failures and misconfigured backends move it on to the next one, while
+// permanent failures are returned as they are, since another backend would
+// refuse the message too.
+type FailoverService struct {
+	backends []*failoverBackend
+}
+
+type failoverBackend struct {
+	Backend
+	breaker *circuitBreaker
+}
+
+// NewFailoverService creates a failover chain trying backends in the given order
+func NewFailoverService(backends []Backend, breakerConfig CircuitBreakerConfig) *FailoverService {
+	s := &FailoverService{}
+	for _, backend := range backends {
+		s.backends = append(s.backends, &failoverBackend{
+			Backend: backend,
+			breaker: newCircuitBreaker(breakerConfig),
+		})
+	}
+	return s
+}
+
+// SendEmail sends through the first available backend that takes the message
+// and records its name in the result
+func (s *FailoverService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	var lastResult *models.DeliveryResult
+	var lastErr error
+
+	for _, backend := range s.backends {
+		if !backend.breaker.allow() {
+			continue
+		}
+
+		result, err := backend.Service.SendEmail(ctx, job)
+		if result != nil {
+			result.Backend = backend.Name
+		}
+		if err == nil {
+			backend.breaker.success()
+			return result, nil
+		}
+
+		if !shouldFailOver(err) {
+			// Another backend would fail the same way
+			backend.breaker.release()
+			return result, err
+		}
+
+		backend.breaker.failure()
+		lastResult, lastErr = result, err
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if lastErr == nil {
+		return nil, errors.NewSMTPError("all email backends are unavailable")
+	}
+	return lastResult, lastErr
+}
+
+// shouldFailOver reports whether another backend might succeed where this one
+// failed: transient errors and backend configuration problems
+func shouldFailOver(err error) bool {
+	if stderrors.Is(err, context.Canceled) {
+		return false
+	}
+	return errors.IsRetryableError(err) || errors.IsConfigError(err)
+}
+
+// Ping succeeds when any backend is reachable
+func (s *FailoverService) Ping(ctx context.Context) error {
+	var failures []error
+	for _, backend := range s.backends {
+		err := backend.Service.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", backend.Name, err))
+	}
+	return errors.NewSMTPErrorWithCause("no email backend is reachable", stderrors.Join(failures...))
+}
+
+// ValidateConfig validates every backend
+func (s *FailoverService) ValidateConfig() error {
+	if len(s.backends) == 0 {
+		return errors.NewConfigError("at least one email backend is required")
+	}
+	for _, backend := range s.backends {
+		if err := backend.Service.ValidateConfig(); err != nil {
+			return errors.NewConfigErrorWithCause(fmt.Sprintf("email backend %s is misconfigured", backend.Name), err)
+		}
+	}
+	return nil
+}
+
+// Close closes every backend
+func (s *FailoverService) Close() error {
+	var failures []error
+	for _, backend := range s.backends {
+		if err := backend.Service.Close(); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", backend.Name, err))
+		}
+	}
+	return stderrors.Join(failures...)
+}
+
+// Backends reports each backend with the state of its circuit breaker
+func (s *FailoverService) Backends() []BackendStatus {
+	statuses := make([]BackendStatus, len(s.backends))
+	for i, backend := range s.backends {
+		statuses[i] = BackendStatus{
+			Name:    backend.Name,
+			Service: backend.Service,
+			Circuit: backend.breaker.currentState(),
+		}
+	}
+	return statuses
+}
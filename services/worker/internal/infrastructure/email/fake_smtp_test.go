@@ -0,0 +1,315 @@
+package email
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal SMTP server on a loopback address. It accepts
+// every recipient unless rcpt answers with a reply of its own, and records the
+// messages it receives.
+type fakeSMTPServer struct {
+	// rcpt returns the reply to RCPT for a recipient, or "" to accept it
+	rcpt func(recipient string) string
+	// delay holds each message before it is acknowledged
+	delay time.Duration
+
+	// tlsConfig enables STARTTLS, or TLS from the first byte with implicitTLS
+	tlsConfig   *tls.Config
+	implicitTLS bool
+
+	// authMechanisms are offered in EHLO when set; clients must log in as
+	// username with password
+	authMechanisms string
+	username       string
+	password       string
+
+	listener net.Listener
+
+	mu          sync.Mutex
+	messages    []fakeSMTPMessage
+	logins      []string
+	commands    []string
+	sessions    int
+	maxSessions int
+	connections int
+}
+
+// fakeSMTPMessage is a message the fake server accepted
+type fakeSMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// start listens on address and serves until the test ends
+func (s *fakeSMTPServer) start(t *testing.T, address string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", address, err)
+	}
+	s.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+// port returns the port the server listens on
+func (s *fakeSMTPServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// Messages returns the messages received so far
+func (s *fakeSMTPServer) Messages() []fakeSMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSMTPMessage(nil), s.messages...)
+}
+
+// Logins returns the mechanisms of the successful AUTH exchanges so far
+func (s *fakeSMTPServer) Logins() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logins...)
+}
+
+// MaxSessions returns the most sessions that were open at once
+func (s *fakeSMTPServer) MaxSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxSessions
+}
+
+// Commands returns the verbs of every command received, in order
+func (s *fakeSMTPServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Connections returns how many connections the server has accepted
+func (s *fakeSMTPServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.connections++
+	s.sessions++
+	s.maxSessions = max(s.maxSessions, s.sessions)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.sessions--
+		s.mu.Unlock()
+	}()
+
+	secure := s.implicitTLS
+	if secure {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	readLine := func() string {
+		line, _ := reader.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	reply("220 fake ESMTP")
+	var message fakeSMTPMessage
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.ToUpper(verb))
+		s.mu.Unlock()
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fake")
+			if s.tlsConfig != nil && !secure {
+				reply("250-STARTTLS")
+			}
+			if s.authMechanisms != "" {
+				reply("250-AUTH " + s.authMechanisms)
+			}
+			reply("250-8BITMIME")
+			reply("250 SMTPUTF8")
+		case "STARTTLS":
+			if s.tlsConfig == nil || secure {
+				reply("502 command not implemented")
+				continue
+			}
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			reader = bufio.NewReader(conn)
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			mechanism = strings.ToUpper(mechanism)
+			if s.login(mechanism, initial, reply, readLine) {
+				s.mu.Lock()
+				s.logins = append(s.logins, mechanism)
+				s.mu.Unlock()
+				reply("235 2.7.0 authentication successful")
+			} else {
+				reply("535 5.7.8 authentication credentials invalid")
+			}
+		case "HELO", "NOOP", "RSET":
+			message = fakeSMTPMessage{}
+			reply("250 ok")
+		case "MAIL":
+			message = fakeSMTPMessage{From: pathArgument(arg)}
+			reply("250 ok")
+		case "RCPT":
+			recipient := pathArgument(arg)
+			if s.rcpt != nil {
+				if answer := s.rcpt(recipient); answer != "" {
+					reply(answer)
+					continue
+				}
+			}
+			message.To = append(message.To, recipient)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			message.Data = data.String()
+			time.Sleep(s.delay)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// login runs the server side of an AUTH exchange and reports whether the
+// client gave the expected credentials
+func (s *fakeSMTPServer) login(mechanism, initial string, reply func(string), readLine func() string) bool {
+	challenge := func(text string) string {
+		reply("334 " + base64.StdEncoding.EncodeToString([]byte(text)))
+		decoded, _ := base64.StdEncoding.DecodeString(readLine())
+		return string(decoded)
+	}
+
+	switch mechanism {
+	case AuthPlain:
+		decoded, _ := base64.StdEncoding.DecodeString(initial)
+		response := string(decoded)
+		if initial == "" {
+			response = challenge("")
+		}
+		return response == "\x00"+s.username+"\x00"+s.password
+	case AuthLogin:
+		username := challenge("Username:")
+		password := challenge("Password:")
+		return username == s.username && password == s.password
+	case AuthCRAMMD5:
+		nonce := "<1896.697170952@fake.example>"
+		username, digest, _ := strings.Cut(challenge(nonce), " ")
+		mac := hmac.New(md5.New, []byte(s.password))
+		mac.Write([]byte(nonce))
+		return username == s.username && digest == hex.EncodeToString(mac.Sum(nil))
+	default:
+		return false
+	}
+}
+
+// testCertificate returns the TLS settings of a server with a self-signed
+// certificate for 127.0.0.1, and the certificate in PEM for clients to trust
+func testCertificate(t *testing.T) (*tls.Config, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake SMTP server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	return config, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// pathArgument extracts the address from a MAIL FROM:<...> or RCPT TO:<...> argument
+func pathArgument(arg string) string {
+	start := strings.IndexByte(arg, '<')
+	end := strings.IndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
@@ -0,0 +1,294 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+const (
+	// maxLineLength is the hard RFC 5322 limit on a header line, excluding CRLF
+	maxLineLength = 998
+
+	// maxEncodedWordLength is the RFC 2047 limit on a single encoded-word
+	maxEncodedWordLength = 75
+)
+
+// writeHeaderFields writes header fields in order. A value may only contain a
+// line break as part of a fold (CRLF followed by whitespace); any other CR or
+// LF would start a new header field, so the field is rejected instead.
+func writeHeaderFields(buf *bytes.Buffer, fields []headerField) error {
+	for _, field := range fields {
+		if !isFieldName(field.name) {
+			return errors.NewValidationError(fmt.Sprintf("invalid header field name %q", field.name))
+		}
+		if !isFoldedValue(field.value) {
+			return errors.NewValidationError(fmt.Sprintf("header %s contains a line break", field.name))
+		}
+		buf.WriteString(field.name + ": " + field.value + "\r\n")
+	}
+	return nil
+}
+
+// isFieldName reports whether name is printable ASCII without a colon
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// isFoldedValue reports whether every CR and LF in value belongs to a fold
+func isFoldedValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\r':
+			if i+2 >= len(value) || value[i+1] != '\n' || (value[i+2] != ' ' && value[i+2] != '\t') {
+				return false
+			}
+			i++
+		case '\n', 0:
+			return false
+		}
+	}
+	return true
+}
+
+// unstructuredValue formats free text such as a subject. Line breaks and other
+// control characters are replaced, non-ASCII text becomes RFC 2047
+// encoded-words, and the result is folded to fit after "name: ".
+func unstructuredValue(name, text string) string {
+	text = sanitizeHeaderText(text)
+	offset := len(name) + 2
+
+	if needsEncodedWords(text) {
+		return foldTokens(offset, encodedLineLength, joinWithSpaces(encodeWords(text, offset)))
+	}
+	return foldTokens(offset, recommendedLineLength, whitespaceTokens(text))
+}
+
+// addressListValue formats an address list, encoding non-ASCII display names
+// and folding between addresses. Addresses that do not parse are rejected.
+func addressListValue(name string, addresses []string) (string, error) {
+	offset := len(name) + 2
+
+	var tokens []string
+	for i, address := range addresses {
+		formatted, err := formatAddress(address, offset)
+		if err != nil {
+			return "", errors.NewValidationErrorWithCause(fmt.Sprintf("invalid %s address %q", name, address), err)
+		}
+		if i < len(addresses)-1 {
+			formatted[len(formatted)-1] += ","
+		}
+		if i > 0 {
+			formatted[0] = " " + formatted[0]
+		}
+		tokens = append(tokens, formatted...)
+	}
+	return foldTokens(offset, encodedLineLength, tokens), nil
+}
+
+// formatAddress parses a single "Name <addr>" or bare address and writes it
+// back in a header-safe form, as tokens that may be folded between. Domains
+// are written in their ASCII form; UTF-8 local parts are kept (RFC 6532).
+func formatAddress(address string, offset int) ([]string, error) {
+	parsed, err := models.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	name := sanitizeHeaderText(parsed.Name)
+	if name == "" {
+		return []string{parsed.Addr()}, nil
+	}
+	if needsEncodedWords(name) {
+		return append(joinWithSpaces(encodeWords(name, offset)), " <"+parsed.Addr()+">"), nil
+	}
+
+	// net/mail quotes ASCII display names that contain specials
+	return []string{(&mail.Address{Name: name, Address: parsed.LocalPart + "@" + parsed.Domain}).String()}, nil
+}
+
+var headerControl = regexp.MustCompile(`[\x00-\x08\x0a-\x1f\x7f]+`)
+
+// sanitizeHeaderText replaces runs of control characters, including CR and LF,
+// with a single space so text cannot break out of its header field
+func sanitizeHeaderText(text string) string {
+	return strings.TrimSpace(headerControl.ReplaceAllString(text, " "))
+}
+
+// needsEncodedWords reports whether text must be sent as encoded-words: it has
+// non-ASCII characters, something a decoder would mistake for an encoded-word,
+// or a word too long to fit on a header line
+func needsEncodedWords(text string) bool {
+	if strings.Contains(text, "=?") {
+		return true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	for _, word := range strings.Fields(text) {
+		if len(word) > maxLineLength-recommendedLineLength {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeWords encodes text as UTF-8 encoded-words of at most 75 characters,
+// never splitting a character across words. The first word is shortened so it
+// fits on a line after offset characters. Mostly-ASCII text uses the Q
+// encoding so it stays readable; anything else uses B, mirroring encodeText.
+func encodeWords(text string, offset int) []string {
+	nonASCII := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+
+	encoding, encode := "Q", qEncode
+	if nonASCII > len(text)/3 {
+		encoding, encode = "B", bEncode
+	}
+
+	prefix, suffix := "=?UTF-8?"+encoding+"?", "?="
+	capacity := min(maxEncodedWordLength, encodedLineLength-offset) - len(prefix) - len(suffix)
+
+	var words []string
+	for start := 0; start < len(text); {
+		if len(words) == 1 {
+			capacity = maxEncodedWordLength - len(prefix) - len(suffix)
+		}
+
+		// Grow the chunk one character at a time while it still fits
+		end := start
+		for end < len(text) {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			if len(encode(text[start:end+size])) > capacity {
+				break
+			}
+			end += size
+		}
+		if end == start {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			end += size
+		}
+		words = append(words, prefix+encode(text[start:end])+suffix)
+		start = end
+	}
+	return words
+}
+
+// qEncode applies the RFC 2047 Q encoding, keeping only the characters that are
+// safe in every context, including display names
+func qEncode(text string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == ' ':
+			b.WriteByte('_')
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("!*+-/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('=')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// bEncode applies the RFC 2047 B encoding
+func bEncode(text string) string {
+	return base64.StdEncoding.EncodeToString([]byte(text))
+}
+
+var whitespaceWord = regexp.MustCompile(`[ \t]*[^ \t]+`)
+
+// whitespaceTokens splits text into words that keep their leading whitespace,
+// so a fold can be placed before any of them without changing the text
+func whitespaceTokens(text string) []string {
+	return whitespaceWord.FindAllString(text, -1)
+}
+
+// joinWithSpaces prefixes every token but the first with a space
+func joinWithSpaces(tokens []string) []string {
+	for i := 1; i < len(tokens); i++ {
+		tokens[i] = " " + tokens[i]
+	}
+	return tokens
+}
+
+// foldTokens concatenates tokens, folding before a token that starts with
+// whitespace when the line would grow past limit. offset is the length of the
+// "name: " prefix on the first line.
+func foldTokens(offset, limit int, tokens []string) string {
+	var b strings.Builder
+	lineLength := offset
+	for _, token := range tokens {
+		foldable := token != "" && (token[0] == ' ' || token[0] == '\t')
+		if foldable && lineLength > offset && lineLength+len(token) > limit {
+			b.WriteString("\r\n")
+			lineLength = 0
+		}
+		b.WriteString(token)
+		lineLength += len(token)
+	}
+	return b.String()
+}
+
+// messageIDPattern matches an msg-id carried over from a previous attempt
+var messageIDPattern = regexp.MustCompile(`^<[^<>@\s]+@[^<>@\s]+>$`)
+
+// messageID returns the Message-ID for a job. A retried job keeps the ID it was
+// first sent with, so recipients can recognize a duplicate delivery.
+func (b *messageBuilder) messageID(job *models.EmailJob) (string, error) {
+	if id := job.Metadata[models.MetadataMessageID]; messageIDPattern.MatchString(id) {
+		return id, nil
+	}
+
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return "<" + strconv.FormatInt(time.Now().UnixNano(), 36) + "." + hex.EncodeToString(random) + "@" + b.messageIDDomain() + ">", nil
+}
+
+// messageIDDomain is the domain of the sender address, or the host name when
+// the sender has none
+func (b *messageBuilder) messageIDDomain() string {
+	if parsed, err := mail.ParseAddress(b.from); err == nil {
+		if _, domain, ok := strings.Cut(parsed.Address, "@"); ok && domain != "" {
+			return domain
+		}
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "localhost"
+}
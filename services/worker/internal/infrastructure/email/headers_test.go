@@ -0,0 +1,290 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// checkFolding fails the test if a folded header value has a line longer than
+// limit, counting the "name: " prefix on the first line
+func checkFolding(t *testing.T, name, value string, limit int) {
+	t.Helper()
+
+	for i, line := range strings.Split(name+": "+value, "\r\n") {
+		if len(line) > limit {
+			t.Errorf("line %d of %s is %d characters, want at most %d: %q", i, name, len(line), limit, line)
+		}
+		if i > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			t.Errorf("continuation line %d of %s does not start with whitespace: %q", i, name, line)
+		}
+	}
+}
+
+// decodeHeader unfolds a header value and decodes its encoded-words
+func decodeHeader(t *testing.T, value string) string {
+	t.Helper()
+
+	decoded, err := new(mime.WordDecoder).DecodeHeader(strings.ReplaceAll(value, "\r\n", ""))
+	if err != nil {
+		t.Fatalf("DecodeHeader(%q) returned %v", value, err)
+	}
+	return decoded
+}
+
+func TestIsFoldedValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "plain", want: true},
+		{value: "folded\r\n continuation", want: true},
+		{value: "folded\r\n\tcontinuation", want: true},
+		{value: "bare LF\nBcc: victim@example.com"},
+		{value: "bare CR\rBcc: victim@example.com"},
+		{value: "CRLF\r\nBcc: victim@example.com"},
+		{value: "trailing CRLF\r\n"},
+		{value: "trailing CR\r"},
+		{value: "CRLF then CRLF\r\n\r\n body"},
+		{value: "NUL\x00byte"},
+	}
+
+	for _, tt := range tests {
+		if got := isFoldedValue(tt.value); got != tt.want {
+			t.Errorf("isFoldedValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHeaderFieldsRejectsInjection(t *testing.T) {
+	tests := []headerField{
+		{"Subject", "Hi\r\nBcc: victim@example.com"},
+		{"Subject", "Hi\nBcc: victim@example.com"},
+		{"X-Bad: injected", "value"},
+		{"X Space", "value"},
+		{"", "value"},
+	}
+
+	for _, field := range tests {
+		var buf bytes.Buffer
+		if err := writeHeaderFields(&buf, []headerField{field}); err == nil {
+			t.Errorf("writeHeaderFields accepted %q: %q", field.name, field.value)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeaderFields(&buf, []headerField{{"Subject", "long\r\n folded"}}); err != nil {
+		t.Errorf("writeHeaderFields rejected a folded value: %v", err)
+	}
+}
+
+func TestSanitizeHeaderText(t *testing.T) {
+	tests := map[string]string{
+		"Hello":                            "Hello",
+		"Hello\r\nBcc: victim@example.com": "Hello Bcc: victim@example.com",
+		"a\r\n\r\n\r\nb":                   "a b",
+		"tab\tstays":                       "tab\tstays",
+		"bell\x07and\x00nul\x7fdel":        "bell and nul del",
+		"\r\n  padded  \n":                 "padded",
+		"Olá line separator is not a CR":   "Olá line separator is not a CR",
+	}
+
+	for input, want := range tests {
+		if got := sanitizeHeaderText(input); got != want {
+			t.Errorf("sanitizeHeaderText(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestUnstructuredValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  string
+		want     string
+		encoding string
+	}{
+		{name: "ASCII", subject: "Your order has shipped", want: "Your order has shipped"},
+		{name: "long ASCII", subject: strings.Repeat("Your order has shipped and ", 8), want: strings.TrimSpace(strings.Repeat("Your order has shipped and ", 8))},
+		{name: "mostly ASCII", subject: "Olá, your order has shipped", want: "Olá, your order has shipped", encoding: "=?UTF-8?Q?"},
+		{name: "mostly non-ASCII", subject: "Ваш заказ отправлен", want: "Ваш заказ отправлен", encoding: "=?UTF-8?B?"},
+		{name: "long non-ASCII", subject: strings.Repeat("日本語の件名 ", 20), want: strings.TrimSpace(strings.Repeat("日本語の件名 ", 20)), encoding: "=?UTF-8?B?"},
+		{name: "looks encoded", subject: "=?UTF-8?B?aGk=?= is not encoded", want: "=?UTF-8?B?aGk=?= is not encoded", encoding: "=?UTF-8?Q?"},
+		{name: "injection", subject: "Hi\r\nBcc: victim@example.com", want: "Hi Bcc: victim@example.com"},
+		{name: "very long word", subject: strings.Repeat("x", 1000), want: strings.Repeat("x", 1000), encoding: "=?UTF-8?Q?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := unstructuredValue("Subject", tt.subject)
+
+			if !isFoldedValue(value) {
+				t.Fatalf("value %q has a line break outside a fold", value)
+			}
+			checkFolding(t, "Subject", value, recommendedLineLength)
+			if tt.encoding != "" && !strings.HasPrefix(value, tt.encoding) {
+				t.Errorf("value %q does not start with %s", value, tt.encoding)
+			}
+			if tt.encoding == "" && strings.Contains(value, "=?") {
+				t.Errorf("value %q was encoded", value)
+			}
+			for _, word := range strings.Fields(value) {
+				if strings.HasPrefix(word, "=?") && len(word) > maxEncodedWordLength {
+					t.Errorf("encoded-word of %d characters: %s", len(word), word)
+				}
+			}
+
+			// Encoded subjects decode back; folding whitespace is not significant
+			// between encoded-words, so compare without it
+			got := decodeHeader(t, value)
+			if tt.encoding != "" {
+				got, tt.want = strings.ReplaceAll(got, " ", ""), strings.ReplaceAll(tt.want, " ", "")
+			}
+			if got != tt.want {
+				t.Errorf("decodes to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeWordsKeepsCharactersWhole(t *testing.T) {
+	text := strings.Repeat("é", 100)
+	for _, word := range encodeWords(text, len("Subject: ")) {
+		if _, err := new(mime.WordDecoder).Decode(word); err != nil {
+			t.Fatalf("encoded-word %q does not decode on its own: %v", word, err)
+		}
+	}
+}
+
+func TestHeadersWithHostileInput(t *testing.T) {
+	job := newTestJob(`"Doe, Jane" <jane@example.org>`)
+	job.Subject = "Invoice\r\nBcc: victim@example.com\r\n\r\n<script>"
+	job.Cc = models.AddressList{"Jörg Müller <joerg@example.de>", `"=?utf-8?q?fake?=" <fake@example.com>`}
+	job.ReplyTo = models.AddressList{"Support\tTeam <help@example.com>"}
+	job.Bcc = models.AddressList{"hidden@example.com"}
+
+	message, _, err := newTestBuilder("").build(job, time.Now())
+	if err != nil {
+		t.Fatalf("build returned %v", err)
+	}
+	header, _ := parseMessage(t, message)
+
+	if header.Get("Bcc") != "" || strings.Contains(string(message), "victim@example.com\r\n") || strings.Contains(string(message), "hidden@example.com") {
+		t.Errorf("message carries a Bcc:\n%s", message)
+	}
+	if got := decodeHeader(t, header.Get("Subject")); got != "Invoice Bcc: victim@example.com <script>" {
+		t.Errorf("Subject decodes to %q", got)
+	}
+
+	want := map[string][]string{
+		"To":       {`Doe, Jane <jane@example.org>`},
+		"Cc":       {"Jörg Müller <joerg@example.de>", "=?utf-8?q?fake?= <fake@example.com>"},
+		"Reply-To": {"Support Team <help@example.com>"},
+	}
+	for name, addresses := range want {
+		list, err := header.AddressList(name)
+		if err != nil {
+			t.Fatalf("%s does not parse: %v", name, err)
+		}
+		var got []string
+		for _, address := range list {
+			got = append(got, address.Name+" <"+address.Address+">")
+		}
+		if strings.Join(got, ", ") != strings.Join(addresses, ", ") {
+			t.Errorf("%s parses as %q, want %q", name, got, addresses)
+		}
+	}
+}
+
+func TestAddressListFolding(t *testing.T) {
+	var recipients []string
+	for _, name := range []string{"Anna", "Bruno", "Carla", "Diego", "Elena", "Fábio", "Gabriela", "Heitor"} {
+		recipients = append(recipients, name+" Sobrenome Comprido <"+strings.ToLower(name[:1])+"@example.com>")
+	}
+
+	value, err := addressListValue("To", recipients)
+	if err != nil {
+		t.Fatalf("addressListValue returned %v", err)
+	}
+	checkFolding(t, "To", value, recommendedLineLength)
+
+	list, err := mail.ParseAddressList(strings.ReplaceAll(value, "\r\n", ""))
+	if err != nil || len(list) != len(recipients) {
+		t.Fatalf("folded list parses as %v (%v), want %d addresses", list, err, len(recipients))
+	}
+	if list[5].Name != "Fábio Sobrenome Comprido" {
+		t.Errorf("encoded name decodes to %q", list[5].Name)
+	}
+
+	if _, err := addressListValue("To", []string{"jane@example.org", "not an address"}); err == nil {
+		t.Error("addressListValue accepted an invalid address")
+	}
+}
+
+func TestMessageIDReuse(t *testing.T) {
+	builder := newTestBuilder("")
+	job := newTestJob("jane@example.org")
+
+	first, err := builder.messageID(job)
+	if err != nil {
+		t.Fatalf("messageID returned %v", err)
+	}
+	if !messageIDPattern.MatchString(first) || !strings.HasSuffix(first, "@example.com>") {
+		t.Errorf("Message-ID %q is not <unique@example.com>", first)
+	}
+	if second, _ := builder.messageID(job); second == first {
+		t.Errorf("two first attempts share Message-ID %s", first)
+	}
+
+	// A retry carries the ID it was first sent with
+	job.Metadata[models.MetadataMessageID] = first
+	message, messageID, err := builder.build(job, time.Now())
+	if err != nil {
+		t.Fatalf("build returned %v", err)
+	}
+	header, _ := parseMessage(t, message)
+	if messageID != first || header.Get("Message-ID") != first {
+		t.Errorf("retry sent with Message-ID %s (header %s), want %s", messageID, header.Get("Message-ID"), first)
+	}
+
+	// Anything else in the metadata is not trusted as a header value
+	for _, stored := range []string{"<a@b>\r\nBcc: victim@example.com", "no-brackets@example.com", "<two@at@example.com>", "<>"} {
+		job.Metadata[models.MetadataMessageID] = stored
+		if got, _ := builder.messageID(job); got == stored {
+			t.Errorf("messageID reused %q", stored)
+		}
+	}
+}
+
+func TestBccOnlyInEnvelope(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := NewSMTPService(newSMTPTestConfig(server.port()))
+	defer service.Close()
+
+	job := newTestJob("jane@example.org")
+	job.Cc = models.AddressList{"john@example.org"}
+	job.Bcc = models.AddressList{"Audit <audit@example.org>", "JANE@example.org"}
+
+	if _, err := service.SendEmail(context.Background(), job); err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	if got := strings.Join(messages[0].To, ","); got != "jane@example.org,john@example.org,audit@example.org" {
+		t.Errorf("envelope recipients %q, want To, Cc and Bcc once each", got)
+	}
+
+	header, _ := parseMessage(t, []byte(messages[0].Data))
+	if header.Get("Bcc") != "" || strings.Contains(messages[0].Data, "audit@example.org") {
+		t.Errorf("message names a Bcc recipient:\n%s", messages[0].Data)
+	}
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlHiddenBlocks = regexp.MustCompile(`(?is)<(script|style|head|title)\b[^>]*>.*?</(script|style|head|title)\s*>`)
+	htmlComments     = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlLinks        = regexp.MustCompile(`(?is)<a\b[^>]*?\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))[^>]*>(.*?)</a\s*>`)
+	htmlListItems    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlLineBreaks   = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBlockTags    = regexp.MustCompile(`(?i)</?(p|div|h[1-6]|ul|ol|table|tr|blockquote|pre|hr|section|article|header|footer)\b[^>]*>`)
+	htmlTags         = regexp.MustCompile(`(?s)<[^>]*>`)
+	inlineSpace      = regexp.MustCompile(`[ \t\f\v]+`)
+	extraBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText derives a readable plain-text alternative from an HTML body.
+// Scripts and styles are dropped, block elements become line breaks, list items
+// become "- " bullets and links keep their target as "text (url)".
+func htmlToText(body string) string {
+	text := htmlHiddenBlocks.ReplaceAllString(body, "")
+	text = htmlComments.ReplaceAllString(text, "")
+	text = htmlLinks.ReplaceAllStringFunc(text, linkToText)
+
+	// Source newlines are insignificant in HTML; only tags break lines
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	text = htmlListItems.ReplaceAllString(text, "\n- ")
+	text = htmlLineBreaks.ReplaceAllString(text, "\n")
+	text = htmlBlockTags.ReplaceAllString(text, "\n\n")
+	text = htmlTags.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(inlineSpace.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	text = extraBlankLines.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text)
+}
+
+// linkToText renders an anchor as its text followed by the target URL, unless
+// the text already is the URL or the link is not navigable
+func linkToText(anchor string) string {
+	match := htmlLinks.FindStringSubmatch(anchor)
+	href := strings.TrimSpace(html.UnescapeString(match[1] + match[2] + match[3]))
+	label := match[4]
+
+	plainLabel := strings.TrimSpace(html.UnescapeString(htmlTags.ReplaceAllString(label, "")))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return label
+	}
+	if plainLabel == "" || plainLabel == href || "mailto:"+plainLabel == href {
+		return href
+	}
+
+	return label + " (" + href + ")"
+}
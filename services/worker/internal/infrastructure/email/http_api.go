@@ -0,0 +1,314 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// Payload fields sent to an HTTP provider. Each is written to the JSON path
+// named by EmailConfig.HTTPPayloadMapping, or to a top-level key of the same
+// name when unmapped; mapping a field to HTTPFieldOmitted leaves it out.
+const (
+	// HTTPFieldFrom is the envelope sender
+	HTTPFieldFrom = "from"
+	// HTTPFieldTo lists every envelope recipient, Bcc included
+	HTTPFieldTo = "to"
+	// HTTPFieldSubject is the subject as given in the job
+	HTTPFieldSubject = "subject"
+	// HTTPFieldMessageID is the Message-ID header of the message
+	HTTPFieldMessageID = "message_id"
+	// HTTPFieldRaw is the complete MIME message, base64 encoded
+	HTTPFieldRaw = "raw"
+
+	HTTPFieldOmitted = "-"
+)
+
+// HTTPPayloadFields lists the payload fields in the order they are written
+var HTTPPayloadFields = []string{HTTPFieldFrom, HTTPFieldTo, HTTPFieldSubject, HTTPFieldMessageID, HTTPFieldRaw}
+
+// defaultHTTPTimeout bounds a provider request when no timeout is configured
+const defaultHTTPTimeout = 30 * time.Second
+
+// maxProviderErrorBody limits how much of an error response is read
+const maxProviderErrorBody = 4 << 10
+
+// HTTPAPIService implements EmailService by posting each message as JSON to an
+// email provider API. The message is rendered exactly as for SMTP, DKIM
+// signature included, and sent raw, so any provider accepting raw MIME can be
+// reached by mapping the payload fields onto its request format, e.g.
+//
+//	from=FromEmailAddress,to=Destination.ToAddresses,raw=Content.Raw.Data
+type HTTPAPIService struct {
+	config   *EmailConfig
+	client   *http.Client
+	builder  *messageBuilder
+	resolver Resolver
+	paths    map[string][]string
+	pathErr  error
+}
+
+// NewHTTPAPIService creates an email service for an HTTP provider API
+func NewHTTPAPIService(config *EmailConfig) *HTTPAPIService {
+	s := &HTTPAPIService{
+		config:   config,
+		client:   config.HTTPClient,
+		builder:  newMessageBuilder(config),
+		resolver: config.Resolver,
+	}
+	if s.client == nil {
+		timeout := config.HTTPTimeout
+		if timeout <= 0 {
+			timeout = defaultHTTPTimeout
+		}
+		s.client = &http.Client{Timeout: timeout}
+	}
+	if s.resolver == nil {
+		s.resolver = net.DefaultResolver
+	}
+	s.paths, s.pathErr = payloadPaths(config.HTTPPayloadMapping)
+	return s
+}
+
+// SendEmail posts the message to the provider. The provider takes the message
+// for every recipient or none, so on success all recipients are accepted.
+func (s *HTTPAPIService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	if err := job.Validate(); err != nil {
+		return nil, errors.NewValidationErrorWithCause("invalid job", err)
+	}
+
+	if err := s.ValidateConfig(); err != nil {
+		return nil, err
+	}
+
+	recipients := job.Recipients()
+	if s.config.CheckRecipientDomains {
+		if err := checkRecipientDomains(ctx, s.resolver, recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	message, messageID, err := s.builder.render(job)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.payload(job, recipients, message, messageID)
+	if err != nil {
+		return nil, errors.NewSMTPErrorWithCause("failed to encode provider request", err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, body)
+	if err != nil {
+		return nil, errors.NewSMTPErrorWithCause("failed to reach email provider", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, classifyProviderResponse(resp)
+	}
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	return &models.DeliveryResult{Accepted: recipients, MessageID: messageID}, nil
+}
+
+// do sends an authenticated request to the provider endpoint
+func (s *HTTPAPIService) do(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.config.HTTPEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.config.HTTPAuthHeader != "" && s.config.HTTPAuthValue != "" {
+		req.Header.Set(s.config.HTTPAuthHeader, s.config.HTTPAuthValue)
+	}
+	return s.client.Do(req)
+}
+
+// payload builds the JSON request body from the mapped fields
+func (s *HTTPAPIService) payload(job *models.EmailJob, recipients []string, message []byte, messageID string) ([]byte, error) {
+	values := map[string]any{
+		HTTPFieldFrom:      s.config.From,
+		HTTPFieldTo:        recipients,
+		HTTPFieldSubject:   job.Subject,
+		HTTPFieldMessageID: messageID,
+		HTTPFieldRaw:       base64.StdEncoding.EncodeToString(message),
+	}
+
+	payload := make(map[string]any)
+	for _, field := range HTTPPayloadFields {
+		if path, ok := s.paths[field]; ok {
+			setPath(payload, path, values[field])
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// payloadPaths resolves the JSON path of every payload field, rejecting
+// unknown fields and paths that would overwrite one another
+func payloadPaths(mapping map[string]string) (map[string][]string, error) {
+	for field := range mapping {
+		if !slices.Contains(HTTPPayloadFields, field) {
+			return nil, fmt.Errorf("unknown payload field %q", field)
+		}
+	}
+
+	paths := make(map[string][]string)
+	leaves := make(map[string]string)   // path -> field stored there
+	branches := make(map[string]string) // path -> a field nested below it
+	for _, field := range HTTPPayloadFields {
+		target, ok := mapping[field]
+		if !ok {
+			target = field
+		}
+		if target == HTTPFieldOmitted {
+			continue
+		}
+
+		path := strings.Split(target, ".")
+		for i, key := range path {
+			if key == "" {
+				return nil, fmt.Errorf("payload field %s has an invalid path %q", field, target)
+			}
+			// A path may neither repeat another nor pass through its value
+			prefix := strings.Join(path[:i+1], ".")
+			other, ok := leaves[prefix]
+			if !ok && i == len(path)-1 {
+				other, ok = branches[prefix]
+			}
+			if ok {
+				return nil, fmt.Errorf("payload fields %s and %s overlap at %q", other, field, prefix)
+			}
+		}
+		for i := range path[:len(path)-1] {
+			branches[strings.Join(path[:i+1], ".")] = field
+		}
+		leaves[target] = field
+		paths[field] = path
+	}
+	return paths, nil
+}
+
+// setPath stores value in payload under the nested keys of path
+func setPath(payload map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := payload[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			payload[key] = next
+		}
+		payload = next
+	}
+	payload[path[len(path)-1]] = value
+}
+
+// classifyProviderResponse turns an error response into a DomainError. Rejected
+// credentials are a configuration problem; anything else is classified by
+// status code.
+func classifyProviderResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxProviderErrorBody))
+	cause := fmt.Errorf("HTTP %d: %s", resp.StatusCode, providerErrorMessage(body))
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return errors.NewConfigErrorWithCause("email provider rejected the credentials", cause)
+	}
+	return errors.NewEmailProviderError("email provider refused the message", resp.StatusCode, cause)
+}
+
+// providerErrorMessage extracts the message from common JSON error bodies,
+// falling back to the body text
+func providerErrorMessage(body []byte) string {
+	var parsed struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		var text string
+		switch {
+		case parsed.Message != "":
+			return parsed.Message
+		case json.Unmarshal(parsed.Error, &text) == nil && text != "":
+			return text
+		case json.Unmarshal(parsed.Error, &nested) == nil && nested.Message != "":
+			return nested.Message
+		case len(parsed.Errors) > 0 && parsed.Errors[0].Message != "":
+			return parsed.Errors[0].Message
+		}
+	}
+
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return "empty response"
+	}
+	return text
+}
+
+// Ping checks that the provider endpoint answers and accepts the credentials.
+// Any other response counts as reachable, since providers rarely serve HEAD.
+func (s *HTTPAPIService) Ping(ctx context.Context) error {
+	if err := s.ValidateConfig(); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, nil)
+	if err != nil {
+		return errors.NewSMTPErrorWithCause("failed to reach email provider", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return classifyProviderResponse(resp)
+	}
+	return nil
+}
+
+// Close releases idle connections to the provider
+func (s *HTTPAPIService) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// ValidateConfig validates the email service configuration
+func (s *HTTPAPIService) ValidateConfig() error {
+	if s.config == nil {
+		return errors.NewConfigError("email config is nil")
+	}
+
+	if s.config.From == "" {
+		return errors.NewConfigError("from address is required")
+	}
+
+	endpoint, err := url.Parse(s.config.HTTPEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return errors.NewConfigError("HTTP endpoint must be an absolute http or https URL")
+	}
+
+	if s.pathErr != nil {
+		return errors.NewConfigErrorWithCause("invalid payload mapping", s.pathErr)
+	}
+
+	return nil
+}
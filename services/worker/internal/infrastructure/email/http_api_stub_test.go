@@ -0,0 +1,140 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// httpAPIStub is a local stand-in for an email provider API, for tests of the
+// HTTP transport. It serves the default payload mapping, checks the auth header
+// when one is set, records every message it accepts and can be told to fail
+// upcoming requests.
+type httpAPIStub struct {
+	server     *httptest.Server
+	authHeader string
+	authValue  string
+
+	mu       sync.Mutex
+	messages []httpAPIStubMessage
+	failures []int
+}
+
+// httpAPIStubMessage is a message accepted by the stub
+type httpAPIStubMessage struct {
+	From      string
+	To        []string
+	Subject   string
+	MessageID string
+	Raw       []byte
+}
+
+// newHTTPAPIStub starts a stub provider. With a non-empty authHeader, requests
+// must carry authValue in that header or are refused with 401.
+func newHTTPAPIStub(authHeader, authValue string) *httpAPIStub {
+	s := &httpAPIStub{authHeader: authHeader, authValue: authValue}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL is the endpoint to configure as EmailConfig.HTTPEndpoint
+func (s *httpAPIStub) URL() string {
+	return s.server.URL
+}
+
+// Config returns an email configuration pointing at the stub
+func (s *httpAPIStub) Config(from string) *EmailConfig {
+	return &EmailConfig{
+		From:           from,
+		HTTPEndpoint:   s.server.URL,
+		HTTPAuthHeader: s.authHeader,
+		HTTPAuthValue:  s.authValue,
+		HTTPClient:     s.server.Client(),
+	}
+}
+
+// FailNext makes the next requests fail with the given status codes, in order
+func (s *httpAPIStub) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Messages returns the messages accepted so far
+func (s *httpAPIStub) Messages() []httpAPIStubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]httpAPIStubMessage(nil), s.messages...)
+}
+
+// Close shuts the stub down
+func (s *httpAPIStub) Close() {
+	s.server.Close()
+}
+
+func (s *httpAPIStub) handle(w http.ResponseWriter, r *http.Request) {
+	if s.authHeader != "" && r.Header.Get(s.authHeader) != s.authValue {
+		writeStubError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeStubError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		writeStubError(w, status, fmt.Sprintf("simulated %s", http.StatusText(status)))
+		return
+	}
+	s.mu.Unlock()
+
+	var payload struct {
+		From      string   `json:"from"`
+		To        []string `json:"to"`
+		Subject   string   `json:"subject"`
+		MessageID string   `json:"message_id"`
+		Raw       string   `json:"raw"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeStubError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	raw, err := base64.StdEncoding.DecodeString(payload.Raw)
+	if err != nil || len(payload.To) == 0 {
+		writeStubError(w, http.StatusUnprocessableEntity, "raw message and recipients are required")
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, httpAPIStubMessage{
+		From:      payload.From,
+		To:        payload.To,
+		Subject:   payload.Subject,
+		MessageID: payload.MessageID,
+		Raw:       raw,
+	})
+	id := len(s.messages)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": fmt.Sprintf("stub-%d", id)})
+}
+
+// writeStubError answers with a provider-style JSON error body
+func writeStubError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": message}})
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+func newTestJob(to string) *models.EmailJob {
+	return models.NewEmailJob("job-1", to, "Hello", "Body text", 3)
+}
+
+func TestHTTPAPIServiceSendEmail(t *testing.T) {
+	stub := newHTTPAPIStub("Authorization", "Bearer secret")
+	defer stub.Close()
+
+	service := NewHTTPAPIService(stub.Config("sender@example.com"))
+	job := newTestJob("Jane <jane@example.org>")
+	job.Cc = models.AddressList{"cc@example.net"}
+
+	result, err := service.SendEmail(context.Background(), job)
+	if err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+
+	messages := stub.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("stub received %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+	if message.From != "sender@example.com" || message.Subject != "Hello" {
+		t.Errorf("stub received from %q subject %q", message.From, message.Subject)
+	}
+	if got := strings.Join(message.To, ","); got != "jane@example.org,cc@example.net" {
+		t.Errorf("stub received recipients %q", got)
+	}
+	if message.MessageID == "" || message.MessageID != result.MessageID {
+		t.Errorf("stub received Message-ID %q, result has %q", message.MessageID, result.MessageID)
+	}
+	if !strings.Contains(string(message.Raw), "Subject: Hello") {
+		t.Errorf("raw message lacks the subject header:\n%s", message.Raw)
+	}
+	if len(result.Accepted) != 2 || len(result.Rejected) != 0 {
+		t.Errorf("result accepted %v rejected %v", result.Accepted, result.Rejected)
+	}
+}
+
+func TestHTTPAPIServiceErrorMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		config    bool
+		permanent bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, permanent: true},
+		{name: "unprocessable", status: http.StatusUnprocessableEntity, permanent: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, config: true},
+		{name: "forbidden", status: http.StatusForbidden, config: true},
+		{name: "request timeout", status: http.StatusRequestTimeout},
+		{name: "rate limited", status: http.StatusTooManyRequests},
+		{name: "server error", status: http.StatusInternalServerError},
+		{name: "unavailable", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newHTTPAPIStub("", "")
+			defer stub.Close()
+			stub.FailNext(tt.status)
+
+			service := NewHTTPAPIService(stub.Config("sender@example.com"))
+			_, err := service.SendEmail(context.Background(), newTestJob("jane@example.org"))
+			if err == nil {
+				t.Fatal("SendEmail succeeded, want an error")
+			}
+
+			if tt.config {
+				if !errors.IsConfigError(err) {
+					t.Errorf("got %v, want a config error", err)
+				}
+				return
+			}
+			if errors.ErrorCode(err) != errors.SMTPErrorCode {
+				t.Errorf("got %v, want an SMTP error", err)
+			}
+			if errors.IsPermanentError(err) != tt.permanent {
+				t.Errorf("permanent = %v, want %v", errors.IsPermanentError(err), tt.permanent)
+			}
+			if errors.IsRetryableError(err) == tt.permanent {
+				t.Errorf("retryable = %v, want %v", errors.IsRetryableError(err), !tt.permanent)
+			}
+			if status := err.(*errors.DomainError).HTTPStatus; status != tt.status {
+				t.Errorf("HTTPStatus = %d, want %d", status, tt.status)
+			}
+			if !strings.Contains(err.Error(), "simulated") {
+				t.Errorf("error %q lacks the provider's message", err)
+			}
+		})
+	}
+}
+
+func TestHTTPAPIServiceRejectsWrongCredentials(t *testing.T) {
+	stub := newHTTPAPIStub("X-Api-Key", "right")
+	defer stub.Close()
+
+	config := stub.Config("sender@example.com")
+	config.HTTPAuthValue = "wrong"
+	service := NewHTTPAPIService(config)
+
+	if _, err := service.SendEmail(context.Background(), newTestJob("jane@example.org")); !errors.IsConfigError(err) {
+		t.Errorf("SendEmail returned %v, want a config error", err)
+	}
+	if err := service.Ping(context.Background()); !errors.IsConfigError(err) {
+		t.Errorf("Ping returned %v, want a config error", err)
+	}
+	if len(stub.Messages()) != 0 {
+		t.Error("stub accepted a message with wrong credentials")
+	}
+}
+
+func TestPayloadPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"from": "from", "to": "to", "subject": "subject", "message_id": "message_id", "raw": "raw"},
+		},
+		{
+			name:    "nested and omitted",
+			mapping: map[string]string{"to": "Destination.ToAddresses", "raw": "Content.Raw.Data", "subject": "-", "message_id": "-"},
+			want:    map[string]string{"from": "from", "to": "Destination.ToAddresses", "raw": "Content.Raw.Data"},
+		},
+		{
+			name:    "siblings under one object",
+			mapping: map[string]string{"from": "message.from", "to": "message.to"},
+			want:    map[string]string{"from": "message.from", "to": "message.to", "subject": "subject", "message_id": "message_id", "raw": "raw"},
+		},
+		{
+			name:    "unknown field",
+			mapping: map[string]string{"cc": "cc"},
+			wantErr: `unknown payload field "cc"`,
+		},
+		{
+			name:    "empty path segment",
+			mapping: map[string]string{"raw": "content..data"},
+			wantErr: `payload field raw has an invalid path "content..data"`,
+		},
+		{
+			name:    "same path twice",
+			mapping: map[string]string{"subject": "from"},
+			wantErr: `payload fields from and subject overlap at "from"`,
+		},
+		{
+			name:    "path through another value",
+			mapping: map[string]string{"raw": "from.raw"},
+			wantErr: `payload fields from and raw overlap at "from"`,
+		},
+		{
+			name:    "value on another path",
+			mapping: map[string]string{"from": "content.from", "raw": "content"},
+			wantErr: `payload fields from and raw overlap at "content"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := payloadPaths(tt.mapping)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+
+			got := make(map[string]string, len(paths))
+			for field, path := range paths {
+				got[field] = strings.Join(path, ".")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got paths %v, want %v", got, tt.want)
+			}
+			for field, path := range tt.want {
+				if got[field] != path {
+					t.Errorf("field %s at %q, want %q", field, got[field], path)
+				}
+			}
+		})
+	}
+}
@@ -2,20 +2,37 @@ package email
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"time"
 
 	"task-scheduler-worker/internal/domain/models"
 )
 
 // EmailService defines the interface for email sending operations
 type EmailService interface {
-	// Send an email
-	SendEmail(ctx context.Context, job *models.EmailJob) error
+	// Send an email, reporting which recipients the server accepted
+	SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error)
 	
 	// Health check
 	Ping(ctx context.Context) error
 	
 	// Configuration validation
 	ValidateConfig() error
+
+	// Release pooled connections and other resources
+	Close() error
+}
+
+// PoolStatsProvider is implemented by email services that pool connections
+type PoolStatsProvider interface {
+	PoolStats() models.ConnectionPoolStats
+}
+
+// BackendProvider is implemented by email services that delegate to several backends
+type BackendProvider interface {
+	Backends() []BackendStatus
 }
 
 // EmailConfig holds email service configuration
@@ -23,4 +40,80 @@ type EmailConfig struct {
 	SMTPHost string
 	SMTPPort string
 	From     string
-}
\ No newline at end of file
+
+	// BlobDir is the local directory attachment blob refs are resolved against
+	BlobDir string
+
+	// DKIM signs every outgoing message when set
+	DKIM *DKIMSigner
+
+	// CheckRecipientDomains looks up the MX or address records of every
+	// recipient domain before sending, through Resolver (net.DefaultResolver
+	// when nil)
+	CheckRecipientDomains bool
+	Resolver              Resolver
+
+	// Direct-to-MX delivery: the port exchangers listen on, the name sent in
+	// EHLO (the host name when empty) and how many connections may be open to
+	// one recipient domain at a time
+	MXPort            string
+	HeloName          string
+	MaxConnsPerDomain int
+
+	// HTTP provider API: the endpoint messages are posted to, the header and
+	// value that authenticate the request, the request timeout and the JSON
+	// paths payload fields are written to (see HTTPPayloadFields). HTTPClient
+	// overrides the client built from these, e.g. to reach a test server.
+	HTTPEndpoint       string
+	HTTPAuthHeader     string
+	HTTPAuthValue      string
+	HTTPTimeout        time.Duration
+	HTTPPayloadMapping map[string]string
+	HTTPClient         *http.Client
+
+	// Development sinks: the directory the file sink writes .eml files to and
+	// the writer the stdout sink prints to (standard output when nil)
+	SinkDir    string
+	SinkWriter io.Writer
+
+	// TLSMode is one of the TLSMode* constants
+	TLSMode string
+	// CAFile is an optional PEM bundle trusted in addition to the system roots
+	CAFile string
+	// TLSConfig overrides the TLS settings built from CAFile, e.g. to trust a test server
+	TLSConfig *tls.Config
+
+	// SMTP AUTH credentials; AuthMechanism is one of the Auth* constants, or empty
+	// to pick PLAIN or LOGIN once the session is encrypted. Plaintext sessions
+	// need an explicit mechanism, normally CRAM-MD5.
+	Username      string
+	Password      string
+	AuthMechanism string
+
+	// Connection pool limits: open connections, connections kept idle between
+	// messages, messages sent before a connection is retired, and how long an
+	// idle connection is trusted before it is closed
+	MaxActiveConns     int
+	MaxIdleConns       int
+	MaxMessagesPerConn int
+	IdleTimeout        time.Duration
+}
+
+// TLS modes supported by SMTPService
+const (
+	// TLSModeNone speaks plaintext SMTP
+	TLSModeNone = "none"
+	// TLSModeOpportunistic upgrades with STARTTLS when the server offers it
+	TLSModeOpportunistic = "opportunistic"
+	// TLSModeRequired refuses to send unless STARTTLS succeeds
+	TLSModeRequired = "required"
+	// TLSModeImplicit connects over TLS from the start, usually on port 465
+	TLSModeImplicit = "implicit"
+)
+
+// SMTP AUTH mechanisms supported by SMTPService
+const (
+	AuthPlain   = "PLAIN"
+	AuthLogin   = "LOGIN"
+	AuthCRAMMD5 = "CRAM-MD5"
+)
\ No newline at end of file
@@ -0,0 +1,327 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"mime/quotedprintable"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+const (
+	// RFC 5322 section 2.1.1 caps lines at 998 characters and recommends 78;
+	// bodies with longer lines are quoted-printable encoded
+	recommendedLineLength = 78
+
+	// base64 and quoted-printable bodies are wrapped at 76 characters (RFC 2045)
+	encodedLineLength = 76
+)
+
+// headerField is a single header line; headers are kept in order for stable output
+type headerField struct {
+	name  string
+	value string
+}
+
+// mimeEntity is a node of a MIME message: a leaf with encoded content, or a
+// multipart container with child entities
+type mimeEntity struct {
+	mediaType string
+	header    []headerField
+	body      []byte
+
+	parts    []*mimeEntity
+	boundary string
+}
+
+// messageBuilder renders jobs as RFC 5322 messages
+type messageBuilder struct {
+	from    string
+	blobDir string
+	dkim    *DKIMSigner
+}
+
+// newMessageBuilder creates a builder for messages sent from the configured address
+func newMessageBuilder(config *EmailConfig) *messageBuilder {
+	return &messageBuilder{
+		from:    config.From,
+		blobDir: config.BlobDir,
+		dkim:    config.DKIM,
+	}
+}
+
+// build renders a job as a MIME message and returns it with its Message-ID.
+// Jobs with both a text and an HTML body become multipart/alternative, and a
+// job with only an HTML body gets a generated plain-text alternative. Inline
+// attachments are grouped with the HTML in multipart/related and regular
+// attachments wrap everything in multipart/mixed. The finished message is
+// DKIM-signed when a signer is configured.
+func (b *messageBuilder) build(job *models.EmailJob, date time.Time) ([]byte, string, error) {
+	body, err := newBodyEntity(job)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var inline, attached []*mimeEntity
+	for i := range job.Attachments {
+		attachment := &job.Attachments[i]
+
+		content, err := loadAttachment(b.blobDir, attachment)
+		if err != nil {
+			return nil, "", err
+		}
+
+		entity := newAttachmentEntity(attachment, content)
+		if attachment.Disposition == models.DispositionInline && attachment.ContentID != "" && job.HTMLBody != "" {
+			inline = append(inline, entity)
+		} else {
+			attached = append(attached, entity)
+		}
+	}
+
+	if len(inline) > 0 {
+		if body, err = newMultipartEntity("related", append([]*mimeEntity{body}, inline...)...); err != nil {
+			return nil, "", err
+		}
+	}
+	if len(attached) > 0 {
+		if body, err = newMultipartEntity("mixed", append([]*mimeEntity{body}, attached...)...); err != nil {
+			return nil, "", err
+		}
+	}
+
+	messageID, err := b.messageID(job)
+	if err != nil {
+		return nil, "", err
+	}
+
+	header, err := b.headers(job, date, messageID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeaderFields(&buf, header); err != nil {
+		return nil, "", err
+	}
+	if err := body.writeTo(&buf); err != nil {
+		return nil, "", err
+	}
+
+	message := buf.Bytes()
+	if b.dkim != nil {
+		if message, err = b.dkim.Sign(message); err != nil {
+			return nil, "", err
+		}
+	}
+	return message, messageID, nil
+}
+
+// render builds the job's message dated now. Domain errors such as an invalid
+// attachment pass through; anything else is reported as an SMTP error.
+func (b *messageBuilder) render(job *models.EmailJob) ([]byte, string, error) {
+	message, messageID, err := b.build(job, time.Now())
+	if err != nil {
+		if errors.IsDomainError(err) {
+			return nil, "", err
+		}
+		return nil, "", errors.NewSMTPErrorWithCause("failed to build message", err)
+	}
+	return message, messageID, nil
+}
+
+// headers returns the message header fields. Bcc recipients are deliberately
+// left out; they only appear in the SMTP envelope.
+func (b *messageBuilder) headers(job *models.EmailJob, date time.Time, messageID string) ([]headerField, error) {
+	from, err := addressListValue("From", []string{b.from})
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 5322 allows an empty group when every recipient is blind
+	to := "undisclosed-recipients:;"
+	if len(job.To) > 0 {
+		if to, err = addressListValue("To", job.To); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := []headerField{
+		{"From", from},
+		{"To", to},
+	}
+
+	optional := []struct {
+		name      string
+		addresses models.AddressList
+	}{
+		{"Cc", job.Cc}, {"Reply-To", job.ReplyTo},
+	}
+	for _, list := range optional {
+		if len(list.addresses) == 0 {
+			continue
+		}
+		value, err := addressListValue(list.name, list.addresses)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, headerField{list.name, value})
+	}
+
+	return append(fields,
+		headerField{"Subject", unstructuredValue("Subject", job.Subject)},
+		headerField{"Date", date.Format(time.RFC1123Z)},
+		headerField{"Message-ID", messageID},
+		headerField{"MIME-Version", "1.0"},
+	), nil
+}
+
+// newBodyEntity builds the text and/or HTML content of a job
+func newBodyEntity(job *models.EmailJob) (*mimeEntity, error) {
+	if job.HTMLBody == "" {
+		return newTextEntity("text/plain", job.Body), nil
+	}
+
+	text := job.Body
+	if text == "" {
+		text = htmlToText(job.HTMLBody)
+	}
+
+	// Clients show the last alternative they understand, so HTML goes last
+	return newMultipartEntity("alternative",
+		newTextEntity("text/plain", text),
+		newTextEntity("text/html", job.HTMLBody),
+	)
+}
+
+// newTextEntity creates a UTF-8 text part with the most compact safe transfer encoding
+func newTextEntity(mediaType, content string) *mimeEntity {
+	encoding, body := encodeText(content)
+	return &mimeEntity{
+		mediaType: mediaType,
+		header: []headerField{
+			{"Content-Type", mediaType + "; charset=UTF-8"},
+			{"Content-Transfer-Encoding", encoding},
+		},
+		body: body,
+	}
+}
+
+// newMultipartEntity creates a multipart container with a fresh boundary
+func newMultipartEntity(subtype string, parts ...*mimeEntity) (*mimeEntity, error) {
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType := "multipart/" + subtype
+	contentType := mediaType
+	// RFC 2387 requires multipart/related to name the type of its root part
+	if subtype == "related" {
+		contentType += ";\r\n type=\"" + parts[0].mediaType + "\""
+	}
+	// Folded so the header stays within the recommended line length
+	contentType += ";\r\n boundary=\"" + boundary + "\""
+
+	return &mimeEntity{
+		mediaType: mediaType,
+		header:    []headerField{{"Content-Type", contentType}},
+		parts:     parts,
+		boundary:  boundary,
+	}, nil
+}
+
+// writeTo writes the entity's header, a blank line and its content
+func (e *mimeEntity) writeTo(buf *bytes.Buffer) error {
+	if err := writeHeaderFields(buf, e.header); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+
+	if len(e.parts) == 0 {
+		buf.Write(e.body)
+		return nil
+	}
+
+	for _, part := range e.parts {
+		buf.WriteString("\r\n--" + e.boundary + "\r\n")
+		if err := part.writeTo(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\r\n--" + e.boundary + "--\r\n")
+	return nil
+}
+
+// newBoundary returns a random multipart boundary. The "=_" prefix cannot occur
+// in quoted-printable or base64 output, so encoded content never collides with it.
+func newBoundary() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "=_" + hex.EncodeToString(random), nil
+}
+
+// encodeText picks a transfer encoding for text content and encodes it with CRLF
+// line endings: 7bit for short-lined ASCII, quoted-printable for mostly-ASCII
+// text and base64 for text that is mostly non-ASCII
+func encodeText(content string) (string, []byte) {
+	content = normalizeNewlines(content)
+
+	nonASCII := 0
+	for i := 0; i < len(content); i++ {
+		if content[i] >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+
+	if nonASCII == 0 && longestLine(content) <= recommendedLineLength {
+		return "7bit", []byte(content)
+	}
+
+	if nonASCII > len(content)/3 {
+		return "base64", encodeBase64Lines([]byte(content))
+	}
+
+	var buf bytes.Buffer
+	writer := quotedprintable.NewWriter(&buf)
+	writer.Write([]byte(content))
+	writer.Close()
+	return "quoted-printable", buf.Bytes()
+}
+
+// encodeBase64Lines base64-encodes data in lines of encodedLineLength characters
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var buf bytes.Buffer
+	for len(encoded) > encodedLineLength {
+		buf.WriteString(encoded[:encodedLineLength] + "\r\n")
+		encoded = encoded[encodedLineLength:]
+	}
+	buf.WriteString(encoded)
+	return buf.Bytes()
+}
+
+// normalizeNewlines converts bare CR and LF line breaks to CRLF
+func normalizeNewlines(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	return strings.ReplaceAll(content, "\n", "\r\n")
+}
+
+// longestLine returns the length of the longest CRLF-separated line
+func longestLine(content string) int {
+	longest := 0
+	for _, line := range strings.Split(content, "\r\n") {
+		longest = max(longest, len(line))
+	}
+	return longest
+}
@@ -0,0 +1,191 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildBodyStructure(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		html      string
+		structure string
+		wantText  string
+	}{
+		{name: "text only", text: "Hello\nthere", structure: "text/plain", wantText: "Hello\r\nthere"},
+		{name: "text and HTML", text: "Hello", html: "<p>Hi</p>", structure: "multipart/alternative[text/plain text/html]", wantText: "Hello"},
+		{name: "HTML only", html: "<h1>Welcome</h1><p>Read the <a href=\"https://example.com/docs\">docs</a>.</p>", structure: "multipart/alternative[text/plain text/html]", wantText: "Welcome\r\n\r\nRead the docs (https://example.com/docs)."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newTestJob("jane@example.org")
+			job.Body, job.HTMLBody = tt.text, tt.html
+
+			message, _, err := newTestBuilder("").build(job, time.Now())
+			if err != nil {
+				t.Fatalf("build returned %v", err)
+			}
+			header, root := parseMessage(t, message)
+
+			if header.Get("MIME-Version") != "1.0" {
+				t.Errorf("MIME-Version %q, want 1.0", header.Get("MIME-Version"))
+			}
+			if got := root.structure(); got != tt.structure {
+				t.Fatalf("message structure is %s, want %s", got, tt.structure)
+			}
+
+			text := root
+			if len(root.parts) > 0 {
+				text = root.parts[0]
+				// Clients show the last alternative they understand
+				if html := root.parts[1]; string(html.body) != tt.html {
+					t.Errorf("HTML part decodes to %q, want %q", html.body, tt.html)
+				}
+			}
+			if text.params["charset"] != "UTF-8" {
+				t.Errorf("text part charset %q, want UTF-8", text.params["charset"])
+			}
+			if string(text.body) != tt.wantText {
+				t.Errorf("text part decodes to %q, want %q", text.body, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+	}{
+		{name: "short ASCII", content: "Hello,\nyour order has shipped.", encoding: "7bit"},
+		{name: "78 character line", content: strings.Repeat("a", recommendedLineLength), encoding: "7bit"},
+		{name: "79 character line", content: strings.Repeat("a", recommendedLineLength+1), encoding: "quoted-printable"},
+		{name: "line past 998", content: strings.Repeat("word ", 300), encoding: "quoted-printable"},
+		{name: "mostly ASCII", content: "Olá, your order has shipped.", encoding: "quoted-printable"},
+		{name: "mostly non-ASCII", content: "Ваш заказ отправлен", encoding: "base64"},
+		{name: "trailing spaces", content: strings.Repeat("x", 90) + "   \nnext", encoding: "quoted-printable"},
+		{name: "dot lines", content: ".\n..\nfrom the start", encoding: "7bit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, body := encodeText(tt.content)
+			if encoding != tt.encoding {
+				t.Fatalf("encoding is %s, want %s", encoding, tt.encoding)
+			}
+
+			limit := recommendedLineLength
+			if encoding != "7bit" {
+				limit = encodedLineLength
+			}
+			for _, line := range strings.Split(string(body), "\r\n") {
+				if len(line) > limit {
+					t.Fatalf("%s line of %d characters, want at most %d", encoding, len(line), limit)
+				}
+				if strings.ContainsAny(line, "\r\n") {
+					t.Fatalf("bare line break in %q", line)
+				}
+			}
+
+			entity := newTextEntity("text/plain", tt.content)
+			var raw strings.Builder
+			for _, field := range entity.header {
+				raw.WriteString(field.name + ": " + field.value + "\r\n")
+			}
+			raw.WriteString("\r\n")
+			raw.Write(entity.body)
+
+			_, part := parseMessage(t, []byte(raw.String()))
+			if want := normalizeNewlines(tt.content); string(part.body) != want {
+				t.Errorf("body decodes to %q, want %q", part.body, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := map[string]string{
+		"a\nb":       "a\r\nb",
+		"a\r\nb":     "a\r\nb",
+		"a\rb":       "a\r\nb",
+		"a\n\r\n\rb": "a\r\n\r\n\r\nb",
+	}
+	for input, want := range tests {
+		if got := normalizeNewlines(input); got != want {
+			t.Errorf("normalizeNewlines(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestBuildKeepsLineLimits(t *testing.T) {
+	job := newTestJob("jane@example.org")
+	job.Subject = strings.Repeat("A very long subject line ", 10)
+	job.Body = strings.Repeat("a line with no break at all ", 100)
+	job.HTMLBody = "<p>" + strings.Repeat("<b>tight</b>", 200) + "</p>"
+
+	message, _, err := newTestBuilder("").build(job, time.Now())
+	if err != nil {
+		t.Fatalf("build returned %v", err)
+	}
+
+	headerEnd := strings.Index(string(message), "\r\n\r\n")
+	for i, line := range strings.Split(string(message), "\r\n") {
+		limit := encodedLineLength
+		if i < strings.Count(string(message[:headerEnd]), "\r\n")+1 {
+			limit = recommendedLineLength
+		}
+		if len(line) > limit {
+			t.Errorf("line %d is %d characters, want at most %d: %q", i, len(line), limit, line)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(string(message), "\r\n", ""), "\n") {
+		t.Error("message has a bare LF")
+	}
+}
+
+func TestNewBoundaryIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		boundary, err := newBoundary()
+		if err != nil {
+			t.Fatalf("newBoundary returned %v", err)
+		}
+		if !strings.HasPrefix(boundary, "=_") || seen[boundary] {
+			t.Fatalf("boundary %q is repeated or lacks the =_ prefix", boundary)
+		}
+		seen[boundary] = true
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "paragraphs", html: "<p>First</p><p>Second</p>", want: "First\n\nSecond"},
+		{name: "line breaks", html: "one<br>two<br/>three", want: "one\ntwo\nthree"},
+		{name: "source newlines", html: "<p>wrapped\n  across\nlines</p>", want: "wrapped across lines"},
+		{name: "list", html: "<ul><li>apples</li><li>pears</li></ul>", want: "- apples\n- pears"},
+		{name: "link", html: `<a href="https://example.com/a?b=1&amp;c=2">Open</a>`, want: "Open (https://example.com/a?b=1&c=2)"},
+		{name: "link showing its URL", html: `<a href="https://example.com">https://example.com</a>`, want: "https://example.com"},
+		{name: "mailto", html: `<a href="mailto:help@example.com">help@example.com</a>`, want: "mailto:help@example.com"},
+		{name: "anchor link", html: `<a href="#top">Back to top</a>`, want: "Back to top"},
+		{name: "javascript link", html: `<a href="javascript:void(0)">Click</a>`, want: "Click"},
+		{name: "hidden content", html: "<head><title>T</title><style>p{}</style></head><script>alert(1)</script><p>Body</p><!-- note -->", want: "Body"},
+		{name: "entities", html: "Fish &amp; chips&nbsp;&lt;3 &eacute;t&eacute;", want: "Fish & chips <3 été"},
+		{name: "blank lines collapse", html: "<div><div><p>a</p></div></div><hr><p>b</p>", want: "a\n\nb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.html); got != tt.want {
+				t.Errorf("htmlToText(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// MXService implements EmailService by delivering straight to the mail
+// exchangers of each recipient domain instead of relaying through SMTPHost.
+// Recipients are grouped by domain, each domain's MX hosts are tried in
+// preference order (or the domain itself when it has none), and the number of
+// connections open to one domain at a time is limited.
+type MXService struct {
+	config   *EmailConfig
+	resolver Resolver
+	builder  *messageBuilder
+	heloName string
+
+	tlsOnce   sync.Once
+	tlsConfig *tls.Config
+	tlsErr    error
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewMXService creates a direct-to-MX email service
+func NewMXService(config *EmailConfig) *MXService {
+	s := &MXService{
+		config:   config,
+		resolver: config.Resolver,
+		builder:  newMessageBuilder(config),
+		heloName: config.HeloName,
+		slots:    make(map[string]chan struct{}),
+	}
+	if s.resolver == nil {
+		s.resolver = net.DefaultResolver
+	}
+	if s.heloName == "" {
+		// Receiving servers expect the sending host's name
+		if host, err := os.Hostname(); err == nil {
+			s.heloName = host
+		} else {
+			s.heloName = "localhost"
+		}
+	}
+	return s
+}
+
+// domainGroup is the set of recipients sharing a domain
+type domainGroup struct {
+	domain     string
+	recipients []string
+}
+
+// SendEmail delivers the message to every recipient domain. As with a relay,
+// the job succeeds when at least one recipient accepts it; recipients of
+// domains that could not be reached are reported as rejected, and those turned
+// away with a transient reply, such as greylisting, are marked not permanent
+// so they can be retried on their own.
+func (s *MXService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	if err := job.Validate(); err != nil {
+		return nil, errors.NewValidationErrorWithCause("invalid job", err)
+	}
+
+	if err := s.ValidateConfig(); err != nil {
+		return nil, err
+	}
+
+	message, messageID, err := s.builder.render(job)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.DeliveryResult{MessageID: messageID}
+	var failure error
+	for _, group := range groupByDomain(job.Recipients()) {
+		domainResult, err := s.deliverToDomain(ctx, group, message)
+		if err == nil {
+			result.Accepted = append(result.Accepted, domainResult.Accepted...)
+			result.Rejected = append(result.Rejected, domainResult.Rejected...)
+			continue
+		}
+
+		if domainResult != nil {
+			// The exchanger refused every recipient individually
+			result.Rejected = append(result.Rejected, domainResult.Rejected...)
+		} else {
+			for _, recipient := range group.recipients {
+				result.Rejected = append(result.Rejected, domainRejection(recipient, err))
+			}
+		}
+
+		// Report a transient failure in preference to a permanent one, so the
+		// job is retried if any domain may still accept it
+		if failure == nil || (!isPermanentFailure(err) && isPermanentFailure(failure)) {
+			failure = err
+		}
+	}
+
+	if len(result.Accepted) == 0 {
+		if errors.IsDomainError(failure) {
+			return result, failure
+		}
+		return result, classifySMTPError("failed to send email", failure)
+	}
+
+	return result, nil
+}
+
+// groupByDomain splits envelope recipients by domain, keeping their order
+func groupByDomain(recipients []string) []domainGroup {
+	var groups []domainGroup
+	index := make(map[string]int)
+	for _, recipient := range recipients {
+		domain := strings.ToLower(recipient[strings.LastIndexByte(recipient, '@')+1:])
+		i, ok := index[domain]
+		if !ok {
+			i = len(groups)
+			index[domain] = i
+			groups = append(groups, domainGroup{domain: domain})
+		}
+		groups[i].recipients = append(groups[i].recipients, recipient)
+	}
+	return groups
+}
+
+// deliverToDomain sends the message to one domain's recipients, trying each
+// mail exchanger in turn until one takes the transaction. A permanent reply
+// ends the attempt; connection failures and transient replies move on to the
+// next host (RFC 5321 section 5.1).
+func (s *MXService) deliverToDomain(ctx context.Context, group domainGroup, message []byte) (*models.DeliveryResult, error) {
+	release, err := s.acquire(ctx, group.domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	hosts, err := s.mailHosts(ctx, group.domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastResult *models.DeliveryResult
+	var lastErr error
+	for _, host := range hosts {
+		result, err := s.deliverToHost(ctx, host, group.recipients, message)
+		if err == nil {
+			return result, nil
+		}
+
+		lastResult, lastErr = result, err
+		if isSMTPReply(err) && isPermanentReply(err) {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return lastResult, fmt.Errorf("delivery to %s failed: %w", group.domain, lastErr)
+}
+
+// mailHosts returns the hosts to try for a domain; an address literal is its own host
+func (s *MXService) mailHosts(ctx context.Context, domain string) ([]string, error) {
+	if strings.HasPrefix(domain, "[") {
+		literal := strings.TrimSuffix(strings.TrimPrefix(domain, "["), "]")
+		return []string{strings.TrimPrefix(literal, "IPv6:")}, nil
+	}
+	return resolveMailHosts(ctx, s.resolver, domain)
+}
+
+// deliverToHost tries each address of an exchanger until one connects, then
+// runs the mail transaction on that connection
+func (s *MXService) deliverToHost(ctx context.Context, host string, recipients []string, message []byte) (*models.DeliveryResult, error) {
+	addresses := []string{host}
+	if net.ParseIP(host) == nil {
+		resolved, err := s.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", host, err)
+		}
+		addresses = resolved
+	}
+
+	var lastErr error = fmt.Errorf("%s has no addresses", host)
+	for _, address := range addresses {
+		attemptCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		client, err := s.dial(attemptCtx, host, address)
+		if err != nil {
+			cancel()
+			lastErr = err
+			continue
+		}
+
+		result, err := deliver(attemptCtx, client, s.config.From, recipients, message)
+		if err == nil {
+			client.Quit()
+		} else {
+			client.Close()
+		}
+		cancel()
+		return result, err
+	}
+	return nil, lastErr
+}
+
+// dial connects to one address of an exchanger, greets it with the configured
+// name and upgrades to TLS according to the TLS mode
+func (s *MXService) dial(ctx context.Context, host, address string) (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, s.config.MXPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+
+	// net/smtp has no context support, so bound the session by the deadline
+	conn.SetDeadline(deadlineFor(ctx))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client for %s: %w", host, err)
+	}
+
+	if err := client.Hello(s.heloName); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("EHLO to %s failed: %w", host, err)
+	}
+
+	tlsConfig, err := s.tlsConfigFor(host)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := startTLS(client, s.config, tlsConfig); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+
+	recordConnectionSecurity(ctx, describeConnection(client, s.config.TLSMode, ""))
+	return client, nil
+}
+
+// tlsConfigFor returns the TLS settings for an exchanger. Opportunistic TLS
+// encrypts without authenticating the server, since exchangers rarely present
+// certificates for their MX names (RFC 7435); required TLS verifies them.
+func (s *MXService) tlsConfigFor(host string) (*tls.Config, error) {
+	s.tlsOnce.Do(func() {
+		s.tlsConfig, s.tlsErr = buildTLSConfig(s.config)
+	})
+	if s.tlsErr != nil {
+		return nil, s.tlsErr
+	}
+
+	tlsConfig := s.tlsConfig.Clone()
+	if s.config.TLSConfig == nil || s.config.TLSConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if s.config.TLSMode == TLSModeOpportunistic {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
+}
+
+// acquire takes one of the domain's connection slots, waiting while all are in
+// use, and returns the function that gives it back
+func (s *MXService) acquire(ctx context.Context, domain string) (func(), error) {
+	s.mu.Lock()
+	slots, ok := s.slots[domain]
+	if !ok {
+		slots = make(chan struct{}, s.config.MaxConnsPerDomain)
+		s.slots[domain] = slots
+	}
+	s.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// domainRejection records a recipient whose domain could not take the message
+func domainRejection(recipient string, err error) models.RecipientRejection {
+	var classified *errors.DomainError
+	if !stderrors.As(err, &classified) {
+		classified = classifySMTPError("", err)
+	}
+	return models.RecipientRejection{
+		Address:      recipient,
+		Code:         classified.SMTPCode,
+		EnhancedCode: classified.EnhancedCode,
+		Message:      err.Error(),
+		Permanent:    isPermanentFailure(err),
+	}
+}
+
+// isPermanentFailure reports whether a domain's delivery failure will not
+// clear up on retry, such as a domain that does not accept mail
+func isPermanentFailure(err error) bool {
+	if errors.IsDomainError(err) {
+		return !errors.IsRetryableError(err)
+	}
+	return classifySMTPError("", err).Permanent
+}
+
+// Ping validates the configuration; there is no single server to reach
+func (s *MXService) Ping(ctx context.Context) error {
+	return s.ValidateConfig()
+}
+
+// Close releases nothing; connections are closed after each domain's delivery
+func (s *MXService) Close() error {
+	return nil
+}
+
+// ValidateConfig validates the email service configuration
+func (s *MXService) ValidateConfig() error {
+	if s.config == nil {
+		return errors.NewConfigError("email config is nil")
+	}
+
+	if s.config.From == "" {
+		return errors.NewConfigError("from address is required")
+	}
+
+	if _, err := strconv.Atoi(s.config.MXPort); err != nil {
+		return errors.NewConfigError("MX port must be numeric")
+	}
+
+	if s.config.MaxConnsPerDomain < 1 {
+		return errors.NewConfigError("max connections per domain must be >= 1")
+	}
+
+	// Exchangers listen for plaintext SMTP on port 25 and upgrade with STARTTLS
+	if s.config.TLSMode == TLSModeImplicit || !isValidTLSMode(s.config.TLSMode) {
+		return errors.NewConfigError("TLS mode for MX delivery must be one of: none, opportunistic, required")
+	}
+
+	if _, err := s.tlsConfigFor(""); err != nil {
+		return errors.NewConfigErrorWithCause("invalid TLS configuration", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package email
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// greylist answers each recipient of domain with a transient reply the first
+// time it is seen and accepts it afterwards, as greylisting servers do
+func greylist(domain string) func(string) string {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	return func(recipient string) string {
+		if !strings.HasSuffix(recipient, "@"+domain) {
+			return ""
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[recipient] {
+			return ""
+		}
+		seen[recipient] = true
+		return "450 4.7.1 Greylisted, try again later"
+	}
+}
+
+// newMXTestService returns an MX service whose exchangers are the loopback
+// listeners on port, as named by resolver
+func newMXTestService(port string, resolver Resolver, maxConns int) *MXService {
+	return NewMXService(&EmailConfig{
+		From:              "sender@example.com",
+		MXPort:            port,
+		HeloName:          "worker.example.com",
+		MaxConnsPerDomain: maxConns,
+		TLSMode:           TLSModeNone,
+		Resolver:          resolver,
+	})
+}
+
+func TestMXServiceSendEmail(t *testing.T) {
+	// The primary exchanger accepts everything but unknown mailboxes; the
+	// second one greylists b.test and must not be reached for a.test, whose
+	// preferred exchanger at 127.0.0.3 is down
+	primary := &fakeSMTPServer{rcpt: func(recipient string) string {
+		if strings.HasPrefix(recipient, "nobody@") {
+			return "550 5.1.1 Mailbox does not exist"
+		}
+		return ""
+	}}
+	primary.start(t, "127.0.0.1:0")
+	secondary := &fakeSMTPServer{rcpt: greylist("b.test")}
+	secondary.start(t, net.JoinHostPort("127.0.0.2", primary.port()))
+
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"a.test": {
+				{Host: "backup.a.test.", Pref: 30},
+				{Host: "mx.a.test.", Pref: 20},
+				{Host: "down.a.test.", Pref: 10},
+			},
+			"c.test": {{Host: "mx.c.test.", Pref: 10}},
+		},
+		hosts: map[string][]string{
+			"down.a.test":   {"127.0.0.3"},
+			"mx.a.test":     {"127.0.0.1"},
+			"backup.a.test": {"127.0.0.2"},
+			"b.test":        {"127.0.0.2"},
+			"mx.c.test":     {"127.0.0.1"},
+		},
+	}
+	service := newMXTestService(primary.port(), resolver, 2)
+
+	job := newTestJob("jane@a.test")
+	job.Cc = models.AddressList{"john@b.test", "nobody@c.test"}
+
+	result, err := service.SendEmail(context.Background(), job)
+	if err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+	if got := strings.Join(result.Accepted, ","); got != "jane@a.test" {
+		t.Errorf("accepted %q, want jane@a.test", got)
+	}
+	if len(result.Rejected) != 2 {
+		t.Fatalf("rejected %v, want john@b.test and nobody@c.test", result.Rejected)
+	}
+	for _, rejection := range result.Rejected {
+		switch rejection.Address {
+		case "john@b.test":
+			if rejection.Permanent || rejection.Code != 450 {
+				t.Errorf("greylisted recipient reported as %+v", rejection)
+			}
+		case "nobody@c.test":
+			if !rejection.Permanent || rejection.Code != 550 {
+				t.Errorf("unknown mailbox reported as %+v", rejection)
+			}
+		default:
+			t.Errorf("unexpected rejection %+v", rejection)
+		}
+	}
+	if got := strings.Join(result.Deferred(), ","); got != "john@b.test" {
+		t.Errorf("deferred %q, want john@b.test", got)
+	}
+
+	messages := primary.Messages()
+	if len(messages) != 1 || strings.Join(messages[0].To, ",") != "jane@a.test" {
+		t.Fatalf("primary exchanger received %+v, want one message to jane@a.test", messages)
+	}
+	if messages[0].From != "sender@example.com" {
+		t.Errorf("envelope sender %q, want sender@example.com", messages[0].From)
+	}
+	if messages := secondary.Messages(); len(messages) != 0 {
+		t.Fatalf("secondary exchanger received %+v before the retry", messages)
+	}
+
+	// The retry goes to the deferred recipient alone
+	job.PendingRecipients = result.Deferred()
+	result, err = service.SendEmail(context.Background(), job)
+	if err != nil {
+		t.Fatalf("retry returned %v", err)
+	}
+	if got := strings.Join(result.Accepted, ","); got != "john@b.test" || len(result.Rejected) != 0 {
+		t.Errorf("retry accepted %q rejected %v, want only john@b.test", got, result.Rejected)
+	}
+	if messages := secondary.Messages(); len(messages) != 1 || strings.Join(messages[0].To, ",") != "john@b.test" {
+		t.Errorf("secondary exchanger received %+v, want one message to john@b.test", messages)
+	}
+	if messages := primary.Messages(); len(messages) != 1 {
+		t.Errorf("primary exchanger received %d messages, want the first only", len(messages))
+	}
+}
+
+func TestMXServiceLimitsConnectionsPerDomain(t *testing.T) {
+	server := &fakeSMTPServer{delay: 50 * time.Millisecond}
+	server.start(t, "127.0.0.1:0")
+
+	resolver := &fakeResolver{hosts: map[string][]string{"a.test": {"127.0.0.1"}}}
+	service := newMXTestService(server.port(), resolver, 2)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.SendEmail(context.Background(), newTestJob("jane@a.test"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("SendEmail returned %v", err)
+		}
+	}
+	if got := len(server.Messages()); got != 6 {
+		t.Errorf("server received %d messages, want 6", got)
+	}
+	if got := server.MaxSessions(); got > 2 {
+		t.Errorf("%d connections were open to the domain at once, want at most 2", got)
+	}
+}
@@ -0,0 +1,91 @@
+package email
+
+import (
+	"cmp"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// Resolver looks up where a domain receives mail. *net.Resolver implements it;
+// tests can inject a fake that needs no DNS.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// resolveTimeout bounds the lookups made for one domain
+const resolveTimeout = 10 * time.Second
+
+// resolveMailHosts returns the hosts accepting mail for a domain in MX
+// preference order. A domain without MX records receives mail at its own
+// address (RFC 5321 section 5.1). A domain that does not exist, has no address
+// or publishes a null MX (RFC 7505) is a validation error; lookup failures that
+// may clear up are returned as retryable SMTP errors.
+func resolveMailHosts(ctx context.Context, resolver Resolver, domain string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	records, err := resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return nil, errors.NewSMTPErrorWithCause(fmt.Sprintf("failed to look up MX records for %s", domain), err)
+	}
+
+	if len(records) > 0 {
+		// net.Resolver already sorts by preference; a fake may not
+		slices.SortStableFunc(records, func(a, b *net.MX) int { return cmp.Compare(a.Pref, b.Pref) })
+
+		if len(records) == 1 && (records[0].Host == "." || records[0].Host == "") {
+			return nil, errors.NewValidationError(fmt.Sprintf("recipient domain %s does not accept mail", domain))
+		}
+
+		hosts := make([]string, len(records))
+		for i, record := range records {
+			hosts[i] = strings.TrimSuffix(record.Host, ".")
+		}
+		return hosts, nil
+	}
+
+	addresses, err := resolver.LookupHost(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return nil, errors.NewSMTPErrorWithCause(fmt.Sprintf("failed to look up %s", domain), err)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.NewValidationError(fmt.Sprintf("recipient domain %s has no MX or address records", domain))
+	}
+	return []string{domain}, nil
+}
+
+// isNotFound reports whether a lookup failed because the name or record does not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return stderrors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// checkRecipientDomains makes sure every recipient domain can receive mail
+// before a connection is made. Address literals are not looked up.
+func checkRecipientDomains(ctx context.Context, resolver Resolver, recipients []string) error {
+	checked := make(map[string]bool)
+	for _, recipient := range recipients {
+		address, err := models.ParseAddress(recipient)
+		if err != nil {
+			return errors.NewValidationErrorWithCause("invalid recipient", err)
+		}
+		if checked[address.Domain] || strings.HasPrefix(address.Domain, "[") {
+			continue
+		}
+		checked[address.Domain] = true
+
+		if _, err := resolveMailHosts(ctx, resolver, address.Domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package email
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+// fakeResolver answers lookups from fixed records. Names without records are
+// not found; names in fail give a temporary error.
+type fakeResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	fail  map[string]bool
+
+	mu      sync.Mutex
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.count(name)
+	if r.fail[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	records, ok := r.mx[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.fail[host] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+	addresses, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addresses, nil
+}
+
+func (r *fakeResolver) count(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[name]++
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com": {
+				{Host: "mx2.example.com.", Pref: 20},
+				{Host: "mx1.example.com.", Pref: 10},
+			},
+			"nomail.example": {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{
+			"a-only.example": {"192.0.2.10"},
+		},
+		fail: map[string]bool{
+			"flaky.example": true,
+		},
+	}
+}
+
+func TestResolveMailHosts(t *testing.T) {
+	tests := []struct {
+		domain     string
+		hosts      string
+		validation bool
+		retryable  bool
+	}{
+		{domain: "example.com", hosts: "mx1.example.com,mx2.example.com"},
+		{domain: "a-only.example", hosts: "a-only.example"},
+		{domain: "nomail.example", validation: true},
+		{domain: "missing.example", validation: true},
+		{domain: "flaky.example", retryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			hosts, err := resolveMailHosts(context.Background(), newFakeResolver(), tt.domain)
+			switch {
+			case tt.validation:
+				if !errors.IsValidationError(err) {
+					t.Errorf("got hosts %v error %v, want a validation error", hosts, err)
+				}
+			case tt.retryable:
+				if !errors.IsRetryableError(err) {
+					t.Errorf("got hosts %v error %v, want a retryable error", hosts, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+				if got := strings.Join(hosts, ","); got != tt.hosts {
+					t.Errorf("got hosts %q, want %q", got, tt.hosts)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRecipientDomains(t *testing.T) {
+	resolver := newFakeResolver()
+	recipients := []string{
+		"jane@example.com",
+		"john@example.com",
+		"ops@a-only.example",
+		"postmaster@[192.0.2.1]",
+	}
+	if err := checkRecipientDomains(context.Background(), resolver, recipients); err != nil {
+		t.Fatalf("checkRecipientDomains returned %v", err)
+	}
+	if resolver.lookups["example.com"] != 1 {
+		t.Errorf("example.com looked up %d times, want once", resolver.lookups["example.com"])
+	}
+	if len(resolver.lookups) != 2 {
+		t.Errorf("looked up %v, want only the two named domains", resolver.lookups)
+	}
+
+	err := checkRecipientDomains(context.Background(), newFakeResolver(), []string{"jane@example.com", "info@nomail.example"})
+	if !errors.IsValidationError(err) || !strings.Contains(err.Error(), "nomail.example") {
+		t.Errorf("got %v, want a validation error naming nomail.example", err)
+	}
+
+	err = checkRecipientDomains(context.Background(), newFakeResolver(), []string{"not an address"})
+	if !errors.IsValidationError(err) {
+		t.Errorf("got %v, want a validation error", err)
+	}
+}
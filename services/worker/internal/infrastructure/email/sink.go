@@ -0,0 +1,223 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// FileSinkService implements EmailService by writing each rendered message to
+// SinkDir as <JobID>.eml instead of sending it, so the whole pipeline can run
+// without a mail server. A retried job overwrites its earlier file.
+type FileSinkService struct {
+	config  *EmailConfig
+	builder *messageBuilder
+}
+
+// NewFileSinkService creates an email service writing messages to files
+func NewFileSinkService(config *EmailConfig) *FileSinkService {
+	return &FileSinkService{
+		config:  config,
+		builder: newMessageBuilder(config),
+	}
+}
+
+// unsafeFileNameChars matches characters not kept in a file name taken from a job ID
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// SendEmail writes the message to the sink directory
+func (s *FileSinkService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	message, messageID, err := renderForSink(s.builder, job, s.ValidateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.write(s.path(job.JobID), message); err != nil {
+		return nil, errors.NewSMTPErrorWithCause("failed to write message file", err)
+	}
+
+	return &models.DeliveryResult{Accepted: job.Recipients(), MessageID: messageID}, nil
+}
+
+// path returns the file a job's message is written to. Characters that could
+// leave the directory or trouble a file system are replaced.
+func (s *FileSinkService) path(jobID string) string {
+	name := unsafeFileNameChars.ReplaceAllString(jobID, "_")
+	if strings.Trim(name, ".") == "" {
+		name = "_" + name
+	}
+	return filepath.Join(s.config.SinkDir, name+".eml")
+}
+
+// write replaces the file through a rename, so readers never see a partial message
+func (s *FileSinkService) write(path string, message []byte) error {
+	if err := os.MkdirAll(s.config.SinkDir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.config.SinkDir, ".eml-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(message); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Ping checks that the sink directory exists or can be created
+func (s *FileSinkService) Ping(ctx context.Context) error {
+	if err := s.ValidateConfig(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.config.SinkDir, 0o755); err != nil {
+		return errors.NewSMTPErrorWithCause("sink directory is not usable", err)
+	}
+	return nil
+}
+
+// Close releases nothing; every file is closed once written
+func (s *FileSinkService) Close() error {
+	return nil
+}
+
+// ValidateConfig validates the email service configuration
+func (s *FileSinkService) ValidateConfig() error {
+	if s.config == nil {
+		return errors.NewConfigError("email config is nil")
+	}
+
+	if s.config.From == "" {
+		return errors.NewConfigError("from address is required")
+	}
+
+	if s.config.SinkDir == "" {
+		return errors.NewConfigError("sink directory is required")
+	}
+
+	return nil
+}
+
+// StdoutSinkService implements EmailService by printing each rendered message
+// to SinkWriter (standard output when nil) in mboxrd format, so messages can
+// be read in the worker's output or split apart by mail tools.
+type StdoutSinkService struct {
+	config  *EmailConfig
+	builder *messageBuilder
+	now     func() time.Time
+
+	// mu keeps messages from concurrent jobs from interleaving
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewStdoutSinkService creates an email service printing messages
+func NewStdoutSinkService(config *EmailConfig) *StdoutSinkService {
+	s := &StdoutSinkService{
+		config:  config,
+		builder: newMessageBuilder(config),
+		now:     time.Now,
+		writer:  config.SinkWriter,
+	}
+	if s.writer == nil {
+		s.writer = os.Stdout
+	}
+	return s
+}
+
+// SendEmail prints the message as one mbox entry
+func (s *StdoutSinkService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	message, messageID, err := renderForSink(s.builder, job, s.ValidateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := mboxEntry(s.config.From, s.now(), message)
+
+	s.mu.Lock()
+	_, err = s.writer.Write(entry)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, errors.NewSMTPErrorWithCause("failed to print message", err)
+	}
+
+	return &models.DeliveryResult{Accepted: job.Recipients(), MessageID: messageID}, nil
+}
+
+// mboxEntry formats a message as an mboxrd entry: a From_ separator line,
+// the message with LF line endings and From_ lines quoted, and a blank line
+func mboxEntry(from string, date time.Time, message []byte) []byte {
+	var buf bytes.Buffer
+	envelope := from
+	if address, err := models.ParseAddress(from); err == nil {
+		envelope = address.Addr()
+	}
+	fmt.Fprintf(&buf, "From %s %s\n", envelope, date.UTC().Format(time.ANSIC))
+
+	text := strings.TrimSuffix(strings.ReplaceAll(string(message), "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			buf.WriteByte('>')
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// Ping validates the configuration; there is nothing to reach
+func (s *StdoutSinkService) Ping(ctx context.Context) error {
+	return s.ValidateConfig()
+}
+
+// Close releases nothing; the writer belongs to the caller
+func (s *StdoutSinkService) Close() error {
+	return nil
+}
+
+// ValidateConfig validates the email service configuration
+func (s *StdoutSinkService) ValidateConfig() error {
+	if s.config == nil {
+		return errors.NewConfigError("email config is nil")
+	}
+
+	if s.config.From == "" {
+		return errors.NewConfigError("from address is required")
+	}
+
+	return nil
+}
+
+// renderForSink validates a job and the sink's configuration and renders the
+// message as it would be sent
+func renderForSink(builder *messageBuilder, job *models.EmailJob, validateConfig func() error) ([]byte, string, error) {
+	if err := job.Validate(); err != nil {
+		return nil, "", errors.NewValidationErrorWithCause("invalid job", err)
+	}
+
+	if err := validateConfig(); err != nil {
+		return nil, "", err
+	}
+
+	return builder.render(job)
+}
@@ -0,0 +1,204 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+func TestFileSinkPath(t *testing.T) {
+	dir := t.TempDir()
+	service := NewFileSinkService(&EmailConfig{From: "sender@example.com", SinkDir: dir})
+
+	tests := map[string]string{
+		"job-1":             "job-1.eml",
+		"0190b7f2.a_b":      "0190b7f2.a_b.eml",
+		"../../etc/passwd":  ".._.._etc_passwd.eml",
+		`..\windows\system`: ".._windows_system.eml",
+		"a b:c*d?":          "a_b_c_d_.eml",
+		"jöb":               "j_b.eml",
+		"..":                "_...eml",
+		".":                 "_..eml",
+		"":                  "_.eml",
+	}
+	for jobID, want := range tests {
+		path := service.path(jobID)
+		if filepath.Dir(path) != dir {
+			t.Errorf("job %q is written outside the sink directory: %s", jobID, path)
+		}
+		if got := filepath.Base(path); got != want {
+			t.Errorf("job %q is written to %s, want %s", jobID, got, want)
+		}
+	}
+}
+
+func TestFileSinkWritesMessage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox", "new")
+	service := NewFileSinkService(&EmailConfig{From: "sender@example.com", SinkDir: dir})
+
+	job := newTestJob("jane@example.org")
+	job.JobID = "../job-1"
+	result, err := service.SendEmail(context.Background(), job)
+	if err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+	if strings.Join(result.Accepted, ",") != "jane@example.org" || result.MessageID == "" {
+		t.Errorf("result %+v, want jane@example.org accepted with a Message-ID", result)
+	}
+
+	path := filepath.Join(dir, ".._job-1.eml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("message file not written: %v", err)
+	}
+	header, body := parseMessage(t, data)
+	if header.Get("Message-ID") != result.MessageID || string(body.body) != "Body text" {
+		t.Errorf("file holds Message-ID %q and body %q", header.Get("Message-ID"), body.body)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("file mode %v, want 0644", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("sink directory holds %d entries, want only the message", len(entries))
+	}
+}
+
+func TestFileSinkReplacesFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	service := NewFileSinkService(&EmailConfig{From: "sender@example.com", SinkDir: dir})
+	path := filepath.Join(dir, "job-1.eml")
+
+	job := newTestJob("jane@example.org")
+	if _, err := service.SendEmail(context.Background(), job); err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+
+	// A reader that opened the first file keeps seeing all of it while the
+	// retry writes a new one
+	reader, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	first, _ := os.ReadFile(path)
+
+	job.Body = strings.Repeat("A much longer body for the retry. ", 100)
+	if _, err := service.SendEmail(context.Background(), job); err != nil {
+		t.Fatalf("SendEmail returned %v on retry", err)
+	}
+
+	held, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(held, first) {
+		t.Error("the open file changed under its reader; it was rewritten in place")
+	}
+	second, _ := os.ReadFile(path)
+	if !bytes.Contains(second, []byte("A much longer body")) {
+		t.Error("retry did not replace the file")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".eml-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestFileSinkErrors(t *testing.T) {
+	dir := t.TempDir()
+	blocked := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sink directory that cannot be created is retried
+	service := NewFileSinkService(&EmailConfig{From: "sender@example.com", SinkDir: filepath.Join(blocked, "outbox")})
+	if _, err := service.SendEmail(context.Background(), newTestJob("jane@example.org")); !errors.IsRetryableError(err) {
+		t.Errorf("SendEmail returned %v, want a retryable error", err)
+	}
+	if err := service.Ping(context.Background()); err == nil {
+		t.Error("Ping returned nil for an unusable sink directory")
+	}
+
+	// An invalid job is not written
+	service = NewFileSinkService(&EmailConfig{From: "sender@example.com", SinkDir: dir})
+	job := models.NewEmailJob("job-1", "not an address", "Hello", "Body text", 3)
+	if _, err := service.SendEmail(context.Background(), job); !errors.IsValidationError(err) {
+		t.Errorf("SendEmail returned %v for an invalid job, want a validation error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "job-1.eml")); !os.IsNotExist(err) {
+		t.Error("invalid job was written")
+	}
+
+	if err := NewFileSinkService(&EmailConfig{From: "sender@example.com"}).ValidateConfig(); !errors.IsConfigError(err) {
+		t.Errorf("ValidateConfig returned %v without a directory, want a config error", err)
+	}
+}
+
+func TestMboxEntry(t *testing.T) {
+	date := time.Date(2024, time.March, 7, 14, 5, 9, 0, time.FixedZone("BRT", -3*3600))
+	message := "Subject: Hi\r\n\r\nFrom the start\r\n>From quoted\r\n>>From twice\r\nFrom\r\nFromage\r\n from indented\r\n"
+
+	got := string(mboxEntry("Sender <sender@example.com>", date, []byte(message)))
+	want := "From sender@example.com Thu Mar  7 17:05:09 2024\n" +
+		"Subject: Hi\n" +
+		"\n" +
+		">From the start\n" +
+		">>From quoted\n" +
+		">>>From twice\n" +
+		"From\n" +
+		"Fromage\n" +
+		" from indented\n" +
+		"\n"
+	if got != want {
+		t.Errorf("mboxEntry =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestStdoutSinkService(t *testing.T) {
+	var out bytes.Buffer
+	service := NewStdoutSinkService(&EmailConfig{From: "sender@example.com", SinkWriter: &out})
+	service.now = func() time.Time { return time.Date(2024, time.March, 7, 14, 5, 9, 0, time.UTC) }
+
+	for _, body := range []string{"First", "From here on, the second"} {
+		job := newTestJob("jane@example.org")
+		job.Body = body
+		if _, err := service.SendEmail(context.Background(), job); err != nil {
+			t.Fatalf("SendEmail returned %v", err)
+		}
+	}
+
+	// Mail tools split the output on unquoted From_ lines
+	var separators []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "From ") {
+			separators = append(separators, line)
+		}
+	}
+	if len(separators) != 2 || separators[0] != "From sender@example.com Thu Mar  7 14:05:09 2024" {
+		t.Errorf("output has separators %q, want one per message", separators)
+	}
+	if !strings.Contains(out.String(), "\n>From here on, the second\n") {
+		t.Errorf("body line starting with From is not quoted:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "\r") {
+		t.Error("output keeps CRLF line endings")
+	}
+}
@@ -2,12 +2,20 @@ package email
 
 import (
 	"context"
+	"crypto/tls"
+	stderrors "errors"
 	"fmt"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"task-scheduler-worker/internal/domain/errors"
 	"task-scheduler-worker/internal/domain/models"
@@ -16,156 +24,292 @@ import (
 // SMTPService implements EmailService using SMTP
 type SMTPService struct {
 	config *EmailConfig
+
+	tlsOnce   sync.Once
+	tlsConfig *tls.Config
+	tlsErr    error
+
+	pool     *smtpPool
+	builder  *messageBuilder
+	resolver Resolver
 }
 
-// NewSMTPService creates a new SMTP email service
+// NewSMTPService creates a new SMTP email service backed by a connection pool
 func NewSMTPService(config *EmailConfig) *SMTPService {
-	return &SMTPService{
-		config: config,
+	s := &SMTPService{
+		config:   config,
+		builder:  newMessageBuilder(config),
+		resolver: config.Resolver,
 	}
+	if s.resolver == nil {
+		s.resolver = net.DefaultResolver
+	}
+	s.pool = newSMTPPool(s.dial, config.MaxActiveConns, config.MaxIdleConns, config.MaxMessagesPerConn, config.IdleTimeout)
+	return s
 }
 
-// SendEmail sends an email using SMTP
-func (s *SMTPService) SendEmail(ctx context.Context, job *models.EmailJob) error {
+// SendEmail sends an email using SMTP. The message goes out as long as the
+// server accepts at least one recipient; rejected recipients are reported in
+// the result.
+func (s *SMTPService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
 	if err := job.Validate(); err != nil {
-		return errors.NewValidationErrorWithCause("invalid job", err)
+		return nil, errors.NewValidationErrorWithCause("invalid job", err)
 	}
 
 	if err := s.ValidateConfig(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Error simulation for testing
 	if err := s.simulateErrorForTestingEmails(job); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create message
-	message := s.formatMessage(job)
+	// Refuse recipients whose domains cannot receive mail before connecting
+	if s.config.CheckRecipientDomains {
+		if err := checkRecipientDomains(ctx, s.resolver, job.Recipients()); err != nil {
+			return nil, err
+		}
+	}
 
-	// Connect to SMTP server
-	addr := fmt.Sprintf("%s:%s", s.config.SMTPHost, s.config.SMTPPort)
+	// Create message
+	message, messageID, err := s.builder.render(job)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create context with timeout for SMTP operation
 	smtpCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	// Send email with context
-	err := s.sendWithContext(smtpCtx, addr, s.config.From, []string{job.To}, []byte(message))
+	// Send email with context; Bcc recipients only ever appear in the envelope
+	result, err := s.sendWithContext(smtpCtx, s.config.From, job.Recipients(), message)
+	if result != nil {
+		result.MessageID = messageID
+	}
 	if err != nil {
-		return errors.NewSMTPErrorWithCause("failed to send email", err)
+		return result, classifySMTPError("failed to send email", err)
 	}
 
-	return nil
+	return result, nil
 }
 
-// sendWithContext sends email with context support
-func (s *SMTPService) sendWithContext(ctx context.Context, addr, from string, to []string, msg []byte) error {
-	// Connect with timeout
-	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+// sendWithContext sends email with context support over a pooled session
+func (s *SMTPService) sendWithContext(ctx context.Context, from string, to []string, msg []byte) (*models.DeliveryResult, error) {
+	conn, err := s.pool.get(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+		return nil, err
 	}
-	defer conn.Close()
 
-	// Create SMTP client
-	client, err := smtp.NewClient(conn, s.config.SMTPHost)
-	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %w", err)
+	conn.conn.SetDeadline(deadlineFor(ctx))
+	recordConnectionSecurity(ctx, conn.security)
+
+	result, err := deliver(ctx, conn.client, from, to, msg)
+	if err == nil {
+		conn.messages++
+	}
+
+	// A rejected command leaves the session usable, anything else may not have
+	s.pool.put(conn, err == nil || isSMTPReply(err))
+
+	return result, err
+}
+
+// errSMTPUTF8Unsupported is returned before MAIL FROM when an address needs
+// SMTPUTF8 and the server does not offer it
+var errSMTPUTF8Unsupported = stderrors.New("server does not support SMTPUTF8")
+
+// requiresSMTPUTF8 reports whether any envelope address contains UTF-8
+func requiresSMTPUTF8(from string, to []string) bool {
+	for _, address := range append([]string{from}, to...) {
+		for i := 0; i < len(address); i++ {
+			if address[i] >= utf8.RuneSelf {
+				return true
+			}
+		}
 	}
-	defer client.Quit()
+	return false
+}
 
+// deliver runs one mail transaction on an established session. Recipients the
+// server refuses are recorded and skipped; the transaction only fails outright
+// when every recipient is refused.
+func deliver(ctx context.Context, client *smtp.Client, from string, to []string, msg []byte) (*models.DeliveryResult, error) {
 	// Check if context is cancelled
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
 
+	// net/smtp asks for SMTPUTF8 whenever the server offers it, but a UTF-8
+	// address can only be sent when it does
+	if requiresSMTPUTF8(from, to) {
+		if ok, _ := client.Extension("SMTPUTF8"); !ok {
+			return nil, errSMTPUTF8Unsupported
+		}
+	}
+
 	// Set sender
 	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("failed to set sender: %w", err)
+		return nil, fmt.Errorf("failed to set sender: %w", err)
 	}
 
 	// Set recipients
+	result := &models.DeliveryResult{}
+	var rejection error
 	for _, recipient := range to {
-		if err := client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+		err := client.Rcpt(recipient)
+		if err == nil {
+			result.Accepted = append(result.Accepted, recipient)
+			continue
+		}
+
+		var reply *textproto.Error
+		if !stderrors.As(err, &reply) {
+			return nil, fmt.Errorf("failed to set recipient %s: %w", recipient, err)
 		}
+
+		rejected := newRecipientRejection(recipient, reply)
+		result.Rejected = append(result.Rejected, rejected)
+
+		// Report a transient rejection in preference to a permanent one, so a job
+		// whose recipients all failed is still retried if any of them may succeed
+		if rejection == nil || (!rejected.Permanent && isPermanentReply(rejection)) {
+			rejection = fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+		}
+	}
+
+	if len(result.Accepted) == 0 {
+		return result, rejection
 	}
 
 	// Check if context is cancelled
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
 
 	// Send message
 	writer, err := client.Data()
 	if err != nil {
-		return fmt.Errorf("failed to get data writer: %w", err)
+		return nil, fmt.Errorf("failed to get data writer: %w", err)
 	}
-	defer writer.Close()
 
 	_, err = writer.Write(msg)
 	if err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+		writer.Close()
+		return nil, fmt.Errorf("failed to write message: %w", err)
 	}
 
-	return nil
+	// Closing the writer ends DATA and returns the server's verdict on the message
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("message rejected: %w", err)
+	}
+
+	return result, nil
 }
 
-// formatMessage formats the email message
-func (s *SMTPService) formatMessage(job *models.EmailJob) string {
-	return fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"Date: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/plain; charset=UTF-8\r\n"+
-		"\r\n"+
-		"%s\r\n",
-		s.config.From,
-		job.To,
-		job.Subject,
-		time.Now().Format(time.RFC1123Z),
-		job.Body,
+// dial connects to the SMTP server and prepares a session for sending: implicit
+// TLS or STARTTLS according to the TLS mode, then SMTP AUTH if configured
+func (s *SMTPService) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(s.config.SMTPHost, s.config.SMTPPort)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	tlsConfig, err := s.getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if s.config.TLSMode == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	// net/smtp has no context support, so bound the handshake by the deadline
+	conn.SetDeadline(deadlineFor(ctx))
+
+	// Create SMTP client
+	client, err := smtp.NewClient(conn, s.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+
+	if err := startTLS(client, s.config, tlsConfig); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	authMechanism, err := authenticate(client, s.config)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &smtpConn{
+		client:   client,
+		conn:     conn,
+		security: describeConnection(client, s.config.TLSMode, authMechanism),
+		lastUsed: time.Now(),
+	}, nil
+}
+
+// recordConnectionSecurity adds the session's TLS and AUTH state to the span in ctx
+func recordConnectionSecurity(ctx context.Context, security connectionSecurity) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("smtp.tls_mode", security.Mode),
+		attribute.Bool("smtp.tls", security.TLS),
+		attribute.String("smtp.tls_version", security.TLSVersion),
+		attribute.String("smtp.tls_cipher", security.CipherSuite),
+		attribute.String("smtp.auth_mechanism", security.AuthMechanism),
 	)
 }
 
+// getTLSConfig builds the TLS settings once and reuses them for every connection
+func (s *SMTPService) getTLSConfig() (*tls.Config, error) {
+	s.tlsOnce.Do(func() {
+		s.tlsConfig, s.tlsErr = buildTLSConfig(s.config)
+	})
+	return s.tlsConfig, s.tlsErr
+}
+
 // Ping checks SMTP server connectivity
 func (s *SMTPService) Ping(ctx context.Context) error {
 	if err := s.ValidateConfig(); err != nil {
 		return err
 	}
 
-	addr := fmt.Sprintf("%s:%s", s.config.SMTPHost, s.config.SMTPPort)
-
 	// Try to connect with timeout
 	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	conn, err := s.dial(pingCtx)
 	if err != nil {
+		if pingCtx.Err() != nil {
+			return errors.NewSMTPErrorWithCause("SMTP ping timeout", pingCtx.Err())
+		}
 		return errors.NewSMTPErrorWithCause("SMTP server unreachable", err)
 	}
-	defer conn.Close()
+	conn.close()
 
-	// Check if context was cancelled
-	select {
-	case <-pingCtx.Done():
-		return errors.NewSMTPErrorWithCause("SMTP ping timeout", pingCtx.Err())
-	default:
-	}
+	return nil
+}
 
-	// Try to create SMTP client to verify server response
-	client, err := smtp.NewClient(conn, s.config.SMTPHost)
-	if err != nil {
-		return errors.NewSMTPErrorWithCause("SMTP server not responding properly", err)
-	}
-	defer client.Quit()
+// PoolStats reports the state of the SMTP connection pool
+func (s *SMTPService) PoolStats() models.ConnectionPoolStats {
+	return s.pool.stats()
+}
 
+// Close closes the idle pooled connections
+func (s *SMTPService) Close() error {
+	s.pool.close()
 	return nil
 }
 
@@ -192,6 +336,26 @@ func (s *SMTPService) ValidateConfig() error {
 		return errors.NewConfigError("from address is required")
 	}
 
+	if !isValidTLSMode(s.config.TLSMode) {
+		return errors.NewConfigError("TLS mode must be one of: none, opportunistic, required, implicit")
+	}
+
+	if !isValidAuthMechanism(s.config.AuthMechanism) {
+		return errors.NewConfigError("AUTH mechanism must be one of: PLAIN, LOGIN, CRAM-MD5")
+	}
+
+	if s.config.Password != "" && s.config.Username == "" {
+		return errors.NewConfigError("SMTP username is required when a password is set")
+	}
+
+	if err := validatePoolConfig(s.config); err != nil {
+		return err
+	}
+
+	if _, err := s.getTLSConfig(); err != nil {
+		return errors.NewConfigErrorWithCause("invalid TLS configuration", err)
+	}
+
 	return nil
 }
 
@@ -204,13 +368,13 @@ func (s *SMTPService) GetConfig() *EmailConfig {
 func (s *SMTPService) simulateErrorForTestingEmails(job *models.EmailJob) error {
 	// Simulate error for error-1@email.com only on first attempt (retry_count == 0)
 	// This will fail the first time, then succeed on retry
-	if strings.Contains(job.To, "error-1@email.com") && job.RetryCount == 0 {
+	if strings.Contains(job.To.String(), "error-1@email.com") && job.RetryCount == 0 {
 		return errors.NewSMTPErrorWithCause("simulated error for error-1@email.com on first attempt",
 			fmt.Errorf("test error simulation: first attempt failure"))
 	}
 
 	// Simulate error for error@email.com on all attempts (persistent failure)
-	if strings.Contains(job.To, "error@email.com") {
+	if strings.Contains(job.To.String(), "error@email.com") {
 		return errors.NewSMTPErrorWithCause("simulated error for error@email.com",
 			fmt.Errorf("test error simulation: persistent failure"))
 	}
@@ -0,0 +1,55 @@
+package email
+
+import (
+	stderrors "errors"
+	"net/textproto"
+	"regexp"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// enhancedStatusCode matches an RFC 3463 class.subject.detail code at the start of a reply
+var enhancedStatusCode = regexp.MustCompile(`^([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// classifySMTPError wraps a send failure as an SMTP DomainError, carrying the
+// server's reply code and enhanced status code when the server rejected the message
+func classifySMTPError(message string, err error) *errors.DomainError {
+	// Retrying against the same server cannot help (RFC 6531 section 3.7.4.1)
+	if stderrors.Is(err, errSMTPUTF8Unsupported) {
+		return errors.NewSMTPReplyError(message, 553, "5.6.7", err)
+	}
+
+	var reply *textproto.Error
+	if !stderrors.As(err, &reply) {
+		// Network failures, timeouts and the like are transient
+		return errors.NewSMTPErrorWithCause(message, err)
+	}
+
+	return errors.NewSMTPReplyError(message, reply.Code, enhancedStatusCode.FindString(reply.Msg), err)
+}
+
+// isSMTPReply reports whether err is a reply from the server rather than a
+// connection failure, meaning the session itself is still intact. A message
+// refused for lacking SMTPUTF8 never reached the server, so it counts too.
+func isSMTPReply(err error) bool {
+	var reply *textproto.Error
+	return stderrors.As(err, &reply) || stderrors.Is(err, errSMTPUTF8Unsupported)
+}
+
+// newRecipientRejection records a RCPT rejection, classified like a send failure
+func newRecipientRejection(address string, reply *textproto.Error) models.RecipientRejection {
+	classified := classifySMTPError("recipient rejected", reply)
+	return models.RecipientRejection{
+		Address:      address,
+		Code:         reply.Code,
+		EnhancedCode: classified.EnhancedCode,
+		Message:      reply.Msg,
+		Permanent:    classified.Permanent,
+	}
+}
+
+// isPermanentReply reports whether err carries a permanent SMTP reply
+func isPermanentReply(err error) bool {
+	return classifySMTPError("", err).Permanent
+}
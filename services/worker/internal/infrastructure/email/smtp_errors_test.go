@@ -0,0 +1,78 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"testing"
+
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+func TestClassifySMTPError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		code         int
+		enhancedCode string
+		permanent    bool
+		reply        bool
+	}{
+		{name: "unknown user", err: &textproto.Error{Code: 550, Msg: "5.1.1 User unknown"}, code: 550, enhancedCode: "5.1.1", permanent: true, reply: true},
+		{name: "policy rejection", err: &textproto.Error{Code: 554, Msg: "Transaction failed"}, code: 554, permanent: true, reply: true},
+		{name: "greylisted", err: &textproto.Error{Code: 451, Msg: "4.7.1 Try again later"}, code: 451, enhancedCode: "4.7.1", reply: true},
+		{name: "service closing", err: &textproto.Error{Code: 421, Msg: "Too many connections"}, code: 421, reply: true},
+		{name: "mailbox full", err: &textproto.Error{Code: 452, Msg: "4.2.2 Mailbox full"}, code: 452, enhancedCode: "4.2.2", reply: true},
+		{name: "enhanced code overrides reply code", err: &textproto.Error{Code: 550, Msg: "4.2.2 Over quota"}, code: 550, enhancedCode: "4.2.2", reply: true},
+		{name: "code-like text mid message", err: &textproto.Error{Code: 451, Msg: "see 5.1.1 for details"}, code: 451, reply: true},
+		{name: "wrapped reply", err: fmt.Errorf("data: %w", &textproto.Error{Code: 552, Msg: "5.3.4 Message too big"}), code: 552, enhancedCode: "5.3.4", permanent: true, reply: true},
+		{name: "SMTPUTF8 unsupported", err: errSMTPUTF8Unsupported, code: 553, enhancedCode: "5.6.7", permanent: true, reply: true},
+		{name: "connection dropped", err: io.EOF},
+		{name: "dial failure", err: &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classified := classifySMTPError("send failed", tt.err)
+
+			if classified.Code != errors.SMTPErrorCode {
+				t.Errorf("code %s, want %s", classified.Code, errors.SMTPErrorCode)
+			}
+			if classified.SMTPCode != tt.code || classified.EnhancedCode != tt.enhancedCode {
+				t.Errorf("reply %d %q, want %d %q", classified.SMTPCode, classified.EnhancedCode, tt.code, tt.enhancedCode)
+			}
+			if classified.Permanent != tt.permanent || isPermanentReply(tt.err) != tt.permanent {
+				t.Errorf("permanent = %v, want %v", classified.Permanent, tt.permanent)
+			}
+			if errors.IsRetryableError(classified) == tt.permanent {
+				t.Errorf("retryable = %v, want %v", !tt.permanent, !tt.permanent)
+			}
+			if isSMTPReply(tt.err) != tt.reply {
+				t.Errorf("isSMTPReply = %v, want %v", !tt.reply, tt.reply)
+			}
+		})
+	}
+}
+
+func TestNewRecipientRejection(t *testing.T) {
+	tests := []struct {
+		reply     *textproto.Error
+		enhanced  string
+		permanent bool
+	}{
+		{reply: &textproto.Error{Code: 550, Msg: "5.1.1 No such user"}, enhanced: "5.1.1", permanent: true},
+		{reply: &textproto.Error{Code: 450, Msg: "4.2.1 Mailbox busy"}, enhanced: "4.2.1"},
+		{reply: &textproto.Error{Code: 553, Msg: "Relaying denied"}, permanent: true},
+	}
+
+	for _, tt := range tests {
+		rejection := newRecipientRejection("jane@example.org", tt.reply)
+		if rejection.Address != "jane@example.org" || rejection.Code != tt.reply.Code || rejection.Message != tt.reply.Msg {
+			t.Errorf("rejection %+v does not carry the reply %v", rejection, tt.reply)
+		}
+		if rejection.EnhancedCode != tt.enhanced || rejection.Permanent != tt.permanent {
+			t.Errorf("%v rejected as %q permanent=%v, want %q permanent=%v", tt.reply, rejection.EnhancedCode, rejection.Permanent, tt.enhanced, tt.permanent)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package email
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+// smtpConn is an established SMTP session that can carry several messages
+type smtpConn struct {
+	client   *smtp.Client
+	conn     net.Conn
+	security connectionSecurity
+	messages int
+	lastUsed time.Time
+}
+
+// close ends the session politely, falling back to dropping the connection
+func (c *smtpConn) close() {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
+
+// smtpPool hands out SMTP sessions to concurrent senders. Open connections are
+// bounded by maxActive; sessions are reset with RSET between messages and
+// retired after maxMessages messages or idleTimeout without use.
+type smtpPool struct {
+	dial        func(ctx context.Context) (*smtpConn, error)
+	maxIdle     int
+	maxMessages int
+	idleTimeout time.Duration
+
+	// slots holds one token per open connection, idle holds the reusable ones
+	slots chan struct{}
+	idle  chan *smtpConn
+
+	// mu orders returning sessions to idle against close, so no session is
+	// parked after the pool has been drained
+	mu     sync.Mutex
+	closed bool
+
+	created   atomic.Uint64
+	reused    atomic.Uint64
+	discarded atomic.Uint64
+}
+
+// newSMTPPool creates an empty pool; connections are dialed on demand
+func newSMTPPool(dial func(ctx context.Context) (*smtpConn, error), maxActive, maxIdle, maxMessages int, idleTimeout time.Duration) *smtpPool {
+	return &smtpPool{
+		dial:        dial,
+		maxIdle:     maxIdle,
+		maxMessages: maxMessages,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, maxActive),
+		idle:        make(chan *smtpConn, maxActive),
+	}
+}
+
+// get borrows a healthy session, reusing an idle one when possible and
+// otherwise dialing a new one once the number of open connections allows it
+func (p *smtpPool) get(ctx context.Context) (*smtpConn, error) {
+	for {
+		// Prefer idle sessions over dialing
+		select {
+		case conn := <-p.idle:
+			if p.healthy(ctx, conn) {
+				p.reused.Add(1)
+				return conn, nil
+			}
+			p.discard(conn)
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case conn := <-p.idle:
+			if p.healthy(ctx, conn) {
+				p.reused.Add(1)
+				return conn, nil
+			}
+			p.discard(conn)
+		case p.slots <- struct{}{}:
+			conn, err := p.dial(ctx)
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			p.created.Add(1)
+			return conn, nil
+		}
+	}
+}
+
+// healthy checks an idle session before it is handed out again
+func (p *smtpPool) healthy(ctx context.Context, conn *smtpConn) bool {
+	if p.idleTimeout > 0 && time.Since(conn.lastUsed) > p.idleTimeout {
+		return false
+	}
+
+	conn.conn.SetDeadline(deadlineFor(ctx))
+	return conn.client.Noop() == nil
+}
+
+// put returns a borrowed session. Sessions that failed mid-conversation, have
+// carried their share of messages or do not fit in the idle set are closed.
+func (p *smtpPool) put(conn *smtpConn, reusable bool) {
+	conn.lastUsed = time.Now()
+
+	if !reusable || conn.messages >= p.maxMessages || len(p.idle) >= p.maxIdle {
+		p.discard(conn)
+		return
+	}
+
+	// Clear any half-finished transaction before the next sender gets it
+	conn.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.client.Reset(); err != nil {
+		p.discard(conn)
+		return
+	}
+
+	p.mu.Lock()
+	parked := false
+	if !p.closed {
+		select {
+		case p.idle <- conn:
+			parked = true
+		default:
+		}
+	}
+	p.mu.Unlock()
+
+	if !parked {
+		p.discard(conn)
+	}
+}
+
+// discard closes a session and frees its slot
+func (p *smtpPool) discard(conn *smtpConn) {
+	conn.close()
+	p.discarded.Add(1)
+	<-p.slots
+}
+
+// close shuts down all idle sessions. Sessions still borrowed are closed when
+// they are returned.
+func (p *smtpPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	var sessions []*smtpConn
+	for drained := false; !drained; {
+		select {
+		case conn := <-p.idle:
+			sessions = append(sessions, conn)
+		default:
+			drained = true
+		}
+	}
+	p.mu.Unlock()
+
+	for _, conn := range sessions {
+		p.discard(conn)
+	}
+}
+
+// stats reports the pool's current size and lifetime counters
+func (p *smtpPool) stats() models.ConnectionPoolStats {
+	open := len(p.slots)
+	idle := len(p.idle)
+
+	return models.ConnectionPoolStats{
+		Open:      open,
+		Idle:      idle,
+		InUse:     max(open-idle, 0),
+		MaxActive: cap(p.slots),
+		MaxIdle:   p.maxIdle,
+		Created:   p.created.Load(),
+		Reused:    p.reused.Load(),
+		Discarded: p.discarded.Load(),
+	}
+}
+
+// deadlineFor returns the context deadline, or a default bound for the SMTP exchange
+func deadlineFor(ctx context.Context) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(30 * time.Second)
+}
+
+// validatePoolConfig checks the pool limits in the email configuration
+func validatePoolConfig(config *EmailConfig) error {
+	if config.MaxActiveConns < 1 {
+		return errors.NewConfigError("SMTP pool max active connections must be >= 1")
+	}
+	if config.MaxIdleConns < 0 || config.MaxIdleConns > config.MaxActiveConns {
+		return errors.NewConfigError("SMTP pool max idle connections must be between 0 and max active connections")
+	}
+	if config.MaxMessagesPerConn < 1 {
+		return errors.NewConfigError("SMTP pool max messages per connection must be >= 1")
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPoolTestService returns a service sending to server with the given pool limits
+func newPoolTestService(t *testing.T, server *fakeSMTPServer, maxActive, maxIdle, maxMessages int, idleTimeout time.Duration) *SMTPService {
+	t.Helper()
+
+	config := newSMTPTestConfig(server.port())
+	config.MaxActiveConns = maxActive
+	config.MaxIdleConns = maxIdle
+	config.MaxMessagesPerConn = maxMessages
+	config.IdleTimeout = idleTimeout
+	service := NewSMTPService(config)
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+// sendTestEmails sends count messages through service
+func sendTestEmails(t *testing.T, service *SMTPService, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		if _, err := service.SendEmail(context.Background(), newTestJob("jane@example.org")); err != nil {
+			t.Fatalf("SendEmail %d returned %v", i+1, err)
+		}
+	}
+}
+
+// countCommands returns how often verb was received
+func countCommands(server *fakeSMTPServer, verb string) int {
+	count := 0
+	for _, command := range server.Commands() {
+		if command == verb {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPoolReusesSessions(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 2, 1, 10, time.Minute)
+
+	sendTestEmails(t, service, 3)
+
+	if got := server.Connections(); got != 1 {
+		t.Errorf("server accepted %d connections, want 1 reused session", got)
+	}
+	// Each message is followed by RSET before the session is parked, and
+	// checked with NOOP before it is handed out again
+	want := "EHLO MAIL RCPT DATA RSET NOOP MAIL RCPT DATA RSET NOOP MAIL RCPT DATA RSET"
+	if got := strings.Join(server.Commands(), " "); got != want {
+		t.Errorf("server received %s, want %s", got, want)
+	}
+
+	stats := service.PoolStats()
+	if stats.Created != 1 || stats.Reused != 2 || stats.Idle != 1 || stats.InUse != 0 {
+		t.Errorf("pool stats %+v, want 1 created, 2 reused and 1 idle", stats)
+	}
+}
+
+func TestPoolRetiresSessionsAfterMaxMessages(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 1, 1, 2, time.Minute)
+
+	sendTestEmails(t, service, 5)
+
+	if got := server.Connections(); got != 3 {
+		t.Errorf("server accepted %d connections for 5 messages at 2 per session, want 3", got)
+	}
+	if got := countCommands(server, "QUIT"); got != 2 {
+		t.Errorf("%d sessions were ended with QUIT, want the 2 retired ones", got)
+	}
+	if stats := service.PoolStats(); stats.Created != 3 || stats.Discarded != 2 || stats.Open != 1 {
+		t.Errorf("pool stats %+v, want 3 created, 2 discarded and 1 open", stats)
+	}
+}
+
+func TestPoolExpiresIdleSessions(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 1, 1, 10, 20*time.Millisecond)
+
+	sendTestEmails(t, service, 1)
+	time.Sleep(50 * time.Millisecond)
+	sendTestEmails(t, service, 1)
+
+	if got := server.Connections(); got != 2 {
+		t.Errorf("server accepted %d connections, want a new one after the idle timeout", got)
+	}
+	// An expired session is dropped without asking the server
+	if got := countCommands(server, "NOOP"); got != 0 {
+		t.Errorf("expired session was checked with NOOP %d times", got)
+	}
+	if stats := service.PoolStats(); stats.Reused != 0 || stats.Discarded != 1 {
+		t.Errorf("pool stats %+v, want the expired session discarded", stats)
+	}
+}
+
+func TestPoolReplacesBrokenSessions(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 1, 1, 10, time.Minute)
+	pool := service.pool
+
+	conn, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+	pool.put(conn, true)
+
+	// The server side went away while the session sat idle
+	conn.conn.Close()
+
+	replacement, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v after the idle session broke", err)
+	}
+	if replacement == conn {
+		t.Fatal("get handed out the broken session")
+	}
+	pool.put(replacement, true)
+
+	if stats := pool.stats(); stats.Created != 2 || stats.Reused != 0 || stats.Discarded != 1 || stats.Open != 1 {
+		t.Errorf("pool stats %+v, want the broken session replaced", stats)
+	}
+}
+
+func TestPoolDiscardsUnusableSessions(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 2, 1, 10, time.Minute)
+	pool := service.pool
+
+	first, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+	second, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+
+	// A session that failed mid-conversation is never reused, and only
+	// maxIdle sessions are kept
+	pool.put(first, false)
+	pool.put(second, true)
+	third, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+	fourth, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+	pool.put(third, true)
+	pool.put(fourth, true)
+
+	if third != second {
+		t.Error("the healthy session was not reused")
+	}
+	if stats := pool.stats(); stats.Created != 3 || stats.Discarded != 2 || stats.Idle != 1 || stats.Open != 1 {
+		t.Errorf("pool stats %+v, want 3 created, 2 discarded and 1 idle", stats)
+	}
+}
+
+func TestPoolWaitsForFreeSlot(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 1, 1, 10, time.Minute)
+	pool := service.pool
+
+	conn, err := pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("get returned %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.get(ctx); err == nil {
+		t.Fatal("get dialed past the active connection limit")
+	}
+
+	// A returned session goes to the next waiting sender
+	got := make(chan *smtpConn)
+	go func() {
+		waiting, _ := pool.get(context.Background())
+		got <- waiting
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.put(conn, true)
+	if waiting := <-got; waiting != conn {
+		t.Error("waiting sender did not get the returned session")
+	} else {
+		pool.put(waiting, true)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	server := &fakeSMTPServer{}
+	server.start(t, "127.0.0.1:0")
+	service := newPoolTestService(t, server, 3, 3, 10, time.Minute)
+	pool := service.pool
+
+	var sessions []*smtpConn
+	for i := 0; i < 3; i++ {
+		conn, err := pool.get(context.Background())
+		if err != nil {
+			t.Fatalf("get returned %v", err)
+		}
+		sessions = append(sessions, conn)
+	}
+	pool.put(sessions[0], true)
+
+	// Sessions returned while and after the pool closes are not parked
+	var wg sync.WaitGroup
+	for _, conn := range sessions[1:] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.put(conn, true)
+		}()
+	}
+	pool.close()
+	wg.Wait()
+
+	if stats := pool.stats(); stats.Open != 0 || stats.Idle != 0 || stats.Discarded != 3 {
+		t.Errorf("pool stats %+v after close, want every session discarded", stats)
+	}
+	if got := countCommands(server, "QUIT"); got != 3 {
+		t.Errorf("%d sessions were ended with QUIT, want 3", got)
+	}
+}
@@ -0,0 +1,207 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// connectionSecurity describes how an SMTP session was secured, for tracing
+type connectionSecurity struct {
+	Mode          string
+	TLS           bool
+	TLSVersion    string
+	CipherSuite   string
+	AuthMechanism string
+}
+
+// buildTLSConfig returns the TLS settings for connections to host, trusting the
+// configured CA bundle on top of the system roots
+func buildTLSConfig(config *EmailConfig) (*tls.Config, error) {
+	if config.TLSConfig != nil {
+		tlsConfig := config.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = config.SMTPHost
+		}
+		return tlsConfig, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: config.SMTPHost,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no certificates", config.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	return tlsConfig, nil
+}
+
+// startTLS upgrades the session according to the TLS mode. Implicit TLS
+// sessions are already encrypted and are left alone.
+func startTLS(client *smtp.Client, config *EmailConfig, tlsConfig *tls.Config) error {
+	if config.TLSMode != TLSModeOpportunistic && config.TLSMode != TLSModeRequired {
+		return nil
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		if config.TLSMode == TLSModeRequired {
+			return fmt.Errorf("server does not offer STARTTLS")
+		}
+		return nil
+	}
+
+	if err := client.StartTLS(tlsConfig); err != nil {
+		return fmt.Errorf("STARTTLS failed: %w", err)
+	}
+
+	return nil
+}
+
+// authenticate runs SMTP AUTH when credentials are configured and returns the
+// mechanism used
+func authenticate(client *smtp.Client, config *EmailConfig) (string, error) {
+	if config.Username == "" {
+		return "", nil
+	}
+
+	ok, offered := client.Extension("AUTH")
+	if !ok {
+		return "", fmt.Errorf("server does not support AUTH")
+	}
+
+	mechanism := config.AuthMechanism
+	if mechanism == "" {
+		if _, secure := client.TLSConnectionState(); !secure {
+			return "", fmt.Errorf("refusing to pick an AUTH mechanism for an unencrypted session; configure one explicitly")
+		}
+		mechanism = preferredAuthMechanism(offered)
+		if mechanism == "" {
+			return "", fmt.Errorf("server offers no supported AUTH mechanism (%s)", offered)
+		}
+	}
+
+	var auth smtp.Auth
+	switch mechanism {
+	case AuthPlain:
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)
+	case AuthLogin:
+		auth = &loginAuth{username: config.Username, password: config.Password, host: config.SMTPHost}
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(config.Username, config.Password)
+	default:
+		return "", fmt.Errorf("unsupported AUTH mechanism %s", mechanism)
+	}
+
+	if err := client.Auth(auth); err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return mechanism, nil
+}
+
+// preferredAuthMechanism picks the mechanism to use over TLS from those the
+// server offers. The session is already encrypted, so PLAIN is preferred;
+// CRAM-MD5 only guards plaintext sessions and is never picked automatically.
+func preferredAuthMechanism(offered string) string {
+	available := make(map[string]bool)
+	for _, mechanism := range strings.Fields(strings.ToUpper(offered)) {
+		available[mechanism] = true
+	}
+
+	for _, mechanism := range []string{AuthPlain, AuthLogin} {
+		if available[mechanism] {
+			return mechanism
+		}
+	}
+	return ""
+}
+
+// isValidTLSMode reports whether mode is one of the TLSMode* constants, or empty for none
+func isValidTLSMode(mode string) bool {
+	switch mode {
+	case "", TLSModeNone, TLSModeOpportunistic, TLSModeRequired, TLSModeImplicit:
+		return true
+	default:
+		return false
+	}
+}
+
+// isValidAuthMechanism reports whether mechanism is empty or one of the Auth* constants
+func isValidAuthMechanism(mechanism string) bool {
+	switch mechanism {
+	case "", AuthPlain, AuthLogin, AuthCRAMMD5:
+		return true
+	default:
+		return false
+	}
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide but
+// many relays still require
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start begins LOGIN, refusing to send credentials over an unencrypted
+// connection to anything but localhost, like smtp.PlainAuth
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, fmt.Errorf("wrong host name")
+	}
+	return AuthLogin, nil, nil
+}
+
+// Next answers the server's username and password prompts
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// describeConnection reports how the client's session is secured
+func describeConnection(client *smtp.Client, mode, authMechanism string) connectionSecurity {
+	security := connectionSecurity{Mode: mode, AuthMechanism: authMechanism}
+
+	if state, ok := client.TLSConnectionState(); ok {
+		security.TLS = true
+		security.TLSVersion = tls.VersionName(state.Version)
+		security.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	}
+
+	return security
+}
@@ -0,0 +1,225 @@
+package email
+
+import (
+	"context"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newSMTPTestConfig returns a config for the fake server listening on port
+func newSMTPTestConfig(port string) *EmailConfig {
+	return &EmailConfig{
+		SMTPHost:           "127.0.0.1",
+		SMTPPort:           port,
+		From:               "sender@example.com",
+		MaxActiveConns:     1,
+		MaxIdleConns:       1,
+		MaxMessagesPerConn: 10,
+	}
+}
+
+// writeCAFile stores the PEM certificate as a CA bundle and returns its path
+func writeCAFile(t *testing.T, certPEM []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+	return path
+}
+
+func TestSMTPServiceTLSModes(t *testing.T) {
+	serverTLS, certPEM := testCertificate(t)
+	caFile := writeCAFile(t, certPEM)
+
+	tests := []struct {
+		name     string
+		starttls bool
+		implicit bool
+		mode     string
+		trustCA  bool
+		wantTLS  bool
+		wantErr  string
+	}{
+		{name: "required STARTTLS", starttls: true, mode: TLSModeRequired, trustCA: true, wantTLS: true},
+		{name: "required without STARTTLS", mode: TLSModeRequired, trustCA: true, wantErr: "server does not offer STARTTLS"},
+		{name: "required with unknown CA", starttls: true, mode: TLSModeRequired, wantErr: "STARTTLS failed"},
+		{name: "opportunistic STARTTLS", starttls: true, mode: TLSModeOpportunistic, trustCA: true, wantTLS: true},
+		{name: "opportunistic without STARTTLS", mode: TLSModeOpportunistic, trustCA: true},
+		{name: "none ignores STARTTLS", starttls: true, mode: TLSModeNone},
+		{name: "implicit with CA file", implicit: true, mode: TLSModeImplicit, trustCA: true, wantTLS: true},
+		{name: "implicit with unknown CA", implicit: true, mode: TLSModeImplicit, wantErr: "failed to connect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTPServer{implicitTLS: tt.implicit}
+			if tt.starttls || tt.implicit {
+				server.tlsConfig = serverTLS
+			}
+			server.start(t, "127.0.0.1:0")
+
+			config := newSMTPTestConfig(server.port())
+			config.TLSMode = tt.mode
+			if tt.trustCA {
+				config.CAFile = caFile
+			}
+			service := NewSMTPService(config)
+
+			conn, err := service.dial(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("dial returned %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial returned %v", err)
+			}
+			defer conn.close()
+
+			if conn.security.TLS != tt.wantTLS {
+				t.Errorf("TLS = %v, want %v", conn.security.TLS, tt.wantTLS)
+			}
+			if tt.wantTLS && conn.security.TLSVersion == "" {
+				t.Error("TLS version not recorded")
+			}
+		})
+	}
+}
+
+func TestSMTPServiceAuth(t *testing.T) {
+	serverTLS, certPEM := testCertificate(t)
+	caFile := writeCAFile(t, certPEM)
+
+	tests := []struct {
+		name      string
+		offered   string
+		mechanism string
+		password  string
+		plaintext bool
+		want      string
+		wantErr   string
+	}{
+		{name: "PLAIN", offered: "PLAIN LOGIN CRAM-MD5", mechanism: AuthPlain, want: AuthPlain},
+		{name: "LOGIN", offered: "PLAIN LOGIN CRAM-MD5", mechanism: AuthLogin, want: AuthLogin},
+		{name: "CRAM-MD5", offered: "PLAIN LOGIN CRAM-MD5", mechanism: AuthCRAMMD5, want: AuthCRAMMD5},
+		{name: "PLAIN preferred over TLS", offered: "LOGIN PLAIN CRAM-MD5", want: AuthPlain},
+		{name: "only LOGIN offered", offered: "LOGIN", want: AuthLogin},
+		{name: "nothing supported offered", offered: "XOAUTH2", wantErr: "no supported AUTH mechanism"},
+		{name: "only CRAM-MD5 offered over TLS", offered: "CRAM-MD5", wantErr: "no supported AUTH mechanism"},
+		{name: "wrong password", offered: "PLAIN", password: "wrong", wantErr: "authentication failed"},
+		{name: "CRAM-MD5 configured for plaintext", offered: "PLAIN CRAM-MD5", mechanism: AuthCRAMMD5, plaintext: true, want: AuthCRAMMD5},
+		{name: "plaintext without a mechanism", offered: "PLAIN CRAM-MD5", plaintext: true, wantErr: "unencrypted session"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTPServer{
+				authMechanisms: tt.offered,
+				username:       "worker",
+				password:       "s3cret",
+			}
+			if !tt.plaintext {
+				server.tlsConfig = serverTLS
+			}
+			server.start(t, "127.0.0.1:0")
+
+			config := newSMTPTestConfig(server.port())
+			config.TLSMode = TLSModeRequired
+			config.CAFile = caFile
+			if tt.plaintext {
+				config.TLSMode = TLSModeNone
+			}
+			config.Username = "worker"
+			config.Password = "s3cret"
+			if tt.password != "" {
+				config.Password = tt.password
+			}
+			config.AuthMechanism = tt.mechanism
+			service := NewSMTPService(config)
+
+			conn, err := service.dial(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("dial returned %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial returned %v", err)
+			}
+			defer conn.close()
+
+			if conn.security.AuthMechanism != tt.want {
+				t.Errorf("authenticated with %q, want %q", conn.security.AuthMechanism, tt.want)
+			}
+			if logins := server.Logins(); len(logins) != 1 || logins[0] != tt.want {
+				t.Errorf("server saw logins %v, want %s", logins, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMTPServiceSendsOverImplicitTLS(t *testing.T) {
+	serverTLS, certPEM := testCertificate(t)
+	server := &fakeSMTPServer{
+		tlsConfig:      serverTLS,
+		implicitTLS:    true,
+		authMechanisms: "LOGIN",
+		username:       "worker",
+		password:       "s3cret",
+	}
+	server.start(t, "127.0.0.1:0")
+
+	config := newSMTPTestConfig(server.port())
+	config.TLSMode = TLSModeImplicit
+	config.CAFile = writeCAFile(t, certPEM)
+	config.Username = "worker"
+	config.Password = "s3cret"
+	service := NewSMTPService(config)
+	defer service.Close()
+
+	if err := service.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping returned %v", err)
+	}
+
+	result, err := service.SendEmail(context.Background(), newTestJob("jane@example.org"))
+	if err != nil {
+		t.Fatalf("SendEmail returned %v", err)
+	}
+	if got := strings.Join(result.Accepted, ","); got != "jane@example.org" {
+		t.Errorf("accepted %q, want jane@example.org", got)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	if !strings.Contains(messages[0].Data, "Message-ID: "+result.MessageID) {
+		t.Errorf("message lacks its Message-ID %s:\n%s", result.MessageID, messages[0].Data)
+	}
+}
+
+func TestLoginAuthRefusesPlaintext(t *testing.T) {
+	auth := &loginAuth{username: "worker", password: "s3cret", host: "smtp.example.com"}
+
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com"}); err == nil {
+		t.Error("LOGIN started over an unencrypted connection to a remote host")
+	}
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "mail.example.net", TLS: true}); err == nil {
+		t.Error("LOGIN started with another host than configured")
+	}
+
+	mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	if err != nil || mechanism != AuthLogin {
+		t.Fatalf("Start returned %q, %v", mechanism, err)
+	}
+	if _, err := auth.Next([]byte("Token:"), true); err == nil {
+		t.Error("LOGIN answered an unknown challenge")
+	}
+}
@@ -2,6 +2,8 @@ package messaging
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"task-scheduler-worker/internal/domain/models"
 )
@@ -16,16 +18,91 @@ type MessageBroker interface {
 	DeclareQueues(ctx context.Context) error
 	
 	// Message operations
-	ConsumeEmailJobs(ctx context.Context, handler func(*models.EmailJob)) error
+	ConsumeEmailJobs(ctx context.Context, handler EmailJobHandler) error
 	PublishEmailJob(ctx context.Context, queue string, job *models.EmailJob) error
+	PublishDelayedEmailJob(ctx context.Context, job *models.EmailJob, delay time.Duration) error
+
+	// Queue depth and consumers of every priority lane
+	LaneStats(ctx context.Context) (map[string]models.LaneStats, error)
 	
 	// Health check
 	Ping(ctx context.Context) error
 }
 
+// EmailJobHandler processes a consumed job and reports how its delivery should be settled
+type EmailJobHandler func(ctx context.Context, job *models.EmailJob) DeliveryOutcome
+
+// DeliveryOutcome tells the broker what to do with a delivery once its handler returns
+type DeliveryOutcome int
+
+const (
+	// DeliveryAck removes the message from the queue
+	DeliveryAck DeliveryOutcome = iota
+	// DeliveryRequeue returns the message to the queue for redelivery
+	DeliveryRequeue
+	// DeliveryReject drops the message without redelivery
+	DeliveryReject
+)
+
 // QueueNames defines the queue names used by the worker
 type QueueNames struct {
 	EmailTasks      string
 	EmailRetry      string
 	EmailFailed     string
-}
\ No newline at end of file
+}
+
+// DefaultQueueNames returns the queue names shared with the API service
+func DefaultQueueNames() *QueueNames {
+	return &QueueNames{
+		EmailTasks:  "email_tasks",
+		EmailRetry:  "email_tasks_retry",
+		EmailFailed: "email_tasks_failed",
+	}
+}
+
+// LaneQueue returns the queue jobs of a priority are consumed from. Normal
+// priority uses the main queue the API publishes to.
+func (q *QueueNames) LaneQueue(priority models.JobPriority) string {
+	if priority == "" || priority == models.JobPriorityNormal {
+		return q.EmailTasks
+	}
+	return fmt.Sprintf("%s.%s", q.EmailTasks, priority)
+}
+
+// RetryDelayTiers are the delays retry queues exist for. Every distinct delay
+// needs a durable queue of its own per lane, so delays are rounded to one of
+// these tiers rather than kept to the second.
+var RetryDelayTiers = []time.Duration{
+	5 * time.Second,
+	30 * time.Second,
+	1 * time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	1 * time.Hour,
+}
+
+// RetryTier returns the retry delay tier nearest to delay. Delays of zero or
+// less mean no delay and are returned as they are.
+func RetryTier(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+
+	nearest := RetryDelayTiers[0]
+	for _, tier := range RetryDelayTiers[1:] {
+		if (tier - delay).Abs() < (nearest - delay).Abs() {
+			nearest = tier
+		}
+	}
+	return nearest
+}
+
+// RetryQueue returns the name of the retry queue that holds jobs of a priority
+// for the given delay tier
+func (q *QueueNames) RetryQueue(priority models.JobPriority, delay time.Duration) string {
+	if priority == "" || priority == models.JobPriorityNormal {
+		return fmt.Sprintf("%s.%d", q.EmailRetry, delay.Milliseconds())
+	}
+	return fmt.Sprintf("%s.%s.%d", q.EmailRetry, priority, delay.Milliseconds())
+}
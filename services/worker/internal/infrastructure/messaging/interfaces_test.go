@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/models"
+)
+
+func TestRetryTier(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{delay: 0, want: 0},
+		{delay: 200 * time.Millisecond, want: 5 * time.Second},
+		{delay: 17 * time.Second, want: 5 * time.Second},
+		{delay: 18 * time.Second, want: 30 * time.Second},
+		{delay: 48 * time.Second, want: time.Minute},
+		{delay: 2 * time.Minute, want: time.Minute},
+		{delay: 4 * time.Minute, want: 5 * time.Minute},
+		{delay: 11 * time.Minute, want: 15 * time.Minute},
+		{delay: 29*time.Minute + 17*time.Second, want: 30 * time.Minute},
+		{delay: 3 * time.Hour, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RetryTier(tt.delay); got != tt.want {
+			t.Errorf("RetryTier(%v) = %v, want %v", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestRetryQueuesAreBounded(t *testing.T) {
+	queueNames := DefaultQueueNames()
+	queues := make(map[string]bool)
+
+	// Jittered delays up to a 30 minute cap land in a handful of queues per lane
+	for delay := time.Second; delay <= 30*time.Minute; delay += 7 * time.Second {
+		for _, priority := range models.JobPriorities {
+			queues[queueNames.RetryQueue(priority, RetryTier(delay))] = true
+		}
+	}
+
+	if limit := len(RetryDelayTiers) * len(models.JobPriorities); len(queues) > limit {
+		t.Errorf("delays spread over %d retry queues, want at most %d", len(queues), limit)
+	}
+}
@@ -3,63 +3,270 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 
 	"task-scheduler-worker/internal/domain/errors"
 	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/pkg/logger"
+)
+
+const (
+	// Backoff bounds between reconnection attempts after the connection drops
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	// How long an idle retry queue outlives its message TTL before the broker
+	// deletes it. Every publish redeclares the queue, so a queue can only expire
+	// once all of its messages have been dead-lettered.
+	retryQueueExpiryMargin = 5 * time.Minute
+)
+
+// ErrReconnecting is returned while the service is re-establishing a dropped connection
+var ErrReconnecting = errors.NewRabbitMQError("reconnecting to RabbitMQ")
+
+// connectionState tracks the lifecycle of the RabbitMQ connection
+type connectionState int
+
+const (
+	stateDisconnected connectionState = iota
+	stateConnected
+	stateReconnecting
+	stateClosed
 )
 
 // RabbitMQService implements MessageBroker using RabbitMQ
 type RabbitMQService struct {
-	url        string
-	conn       *amqp.Connection
-	channel    *amqp.Channel
-	queueNames *QueueNames
+	url           string
+	queueNames    *QueueNames
+	concurrency   int
+	prefetchCount int
+	laneWeights   map[models.JobPriority]int
+	logger        *logger.Logger
+
+	// mu guards the connection, channel and state, which are swapped on reconnect
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	state       connectionState
+	reconnected chan struct{} // closed and replaced after every successful (re)connect
+	closed      chan struct{} // closed by Close to stop reconnection attempts
 }
 
-// NewRabbitMQService creates a new RabbitMQ message broker service
-func NewRabbitMQService(rabbitMQURL string) *RabbitMQService {
+// NewRabbitMQService creates a new RabbitMQ message broker service that runs up to
+// concurrency handlers at once and lets the broker push up to prefetchCount unacked
+// messages across all priority lanes. Lanes with jobs waiting share the handlers
+// in proportion to laneWeights.
+func NewRabbitMQService(rabbitMQURL string, concurrency, prefetchCount int, laneWeights map[models.JobPriority]int, logger *logger.Logger) *RabbitMQService {
 	return &RabbitMQService{
-		url: rabbitMQURL,
-		queueNames: &QueueNames{
-			EmailTasks:  "email_tasks",
-			EmailRetry:  "email_tasks_retry",
-			EmailFailed: "email_tasks_failed",
-		},
+		url:           rabbitMQURL,
+		concurrency:   concurrency,
+		prefetchCount: prefetchCount,
+		laneWeights:   laneWeights,
+		logger:        logger.WithComponent("rabbitmq"),
+		queueNames:    DefaultQueueNames(),
+		reconnected:   make(chan struct{}),
+		closed:        make(chan struct{}),
 	}
 }
 
-// Connect establishes connection to RabbitMQ
+// Connect establishes connection to RabbitMQ and starts watching it for drops
 func (r *RabbitMQService) Connect(ctx context.Context) error {
-	var err error
-	
-	r.conn, err = amqp.Dial(r.url)
+	conn, channel, err := r.dial()
+	if err != nil {
+		return err
+	}
+
+	r.setConnected(conn, channel)
+	go r.watchConnection(conn, channel)
+
+	return nil
+}
+
+// dial opens a connection and a configured channel
+func (r *RabbitMQService) dial() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(r.url)
 	if err != nil {
-		return errors.NewRabbitMQErrorWithCause("failed to connect to RabbitMQ", err)
+		return nil, nil, errors.NewRabbitMQErrorWithCause("failed to connect to RabbitMQ", err)
 	}
 
-	r.channel, err = r.conn.Channel()
+	channel, err := conn.Channel()
 	if err != nil {
-		return errors.NewRabbitMQErrorWithCause("failed to open channel", err)
+		conn.Close()
+		return nil, nil, errors.NewRabbitMQErrorWithCause("failed to open channel", err)
 	}
 
-	return nil
+	// Cap unacked deliveries so a slow SMTP server cannot pile messages up in
+	// memory. The limit is global, shared by the lane consumers on the channel,
+	// rather than granted to each of them.
+	if err := channel.Qos(r.prefetchCount, 0, true); err != nil {
+		conn.Close()
+		return nil, nil, errors.NewRabbitMQErrorWithCause("failed to set channel prefetch", err)
+	}
+
+	// Each lane's consumer is capped below the channel limit as well, so a
+	// flood in one lane cannot hold every delivery the broker will push
+	if err := channel.Qos(lanePrefetch(r.prefetchCount, len(models.JobPriorities)), 0, false); err != nil {
+		conn.Close()
+		return nil, nil, errors.NewRabbitMQErrorWithCause("failed to set lane prefetch", err)
+	}
+
+	// Publisher confirms let PublishEmailJob know the broker has taken the message
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, errors.NewRabbitMQErrorWithCause("failed to enable publisher confirms", err)
+	}
+
+	return conn, channel, nil
+}
+
+// lanePrefetch returns how many unacked deliveries one lane's consumer may
+// hold. It leaves room under the channel limit for a delivery of every other
+// lane, so while one lane floods the weighted round-robin still sees the jobs
+// of the others as soon as they arrive.
+func lanePrefetch(prefetchCount, lanes int) int {
+	return max(1, prefetchCount-(lanes-1))
+}
+
+// setConnected swaps in a fresh connection and wakes everyone waiting for one
+func (r *RabbitMQService) setConnected(conn *amqp.Connection, channel *amqp.Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.conn = conn
+	r.channel = channel
+	r.state = stateConnected
+	close(r.reconnected)
+	r.reconnected = make(chan struct{})
+}
+
+// watchConnection blocks until the connection or channel closes and then
+// reconnects, unless the close was requested through Close
+func (r *RabbitMQService) watchConnection(conn *amqp.Connection, channel *amqp.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	var reason *amqp.Error
+	select {
+	case reason = <-connClosed:
+	case reason = <-channelClosed:
+	case <-r.closed:
+		return
+	}
+
+	r.mu.Lock()
+	if r.state == stateClosed {
+		r.mu.Unlock()
+		return
+	}
+	r.state = stateReconnecting
+	r.mu.Unlock()
+
+	r.logger.Warn("RabbitMQ connection lost, reconnecting", "reason", reason)
+
+	// A channel can close on its own while the connection survives; start clean either way
+	conn.Close()
+
+	r.reconnect()
+}
+
+// reconnect dials with exponential backoff until it succeeds or the service is closed,
+// re-declaring queues so consumers can resume on the new channel
+func (r *RabbitMQService) reconnect() {
+	delay := minReconnectDelay
+
+	for attempt := 1; ; attempt++ {
+		conn, channel, err := r.dial()
+		if err == nil {
+			if err = r.declareQueues(channel); err != nil {
+				conn.Close()
+			}
+		}
+
+		if err == nil {
+			r.mu.Lock()
+			closing := r.state == stateClosed
+			r.mu.Unlock()
+			if closing {
+				conn.Close()
+				return
+			}
+
+			r.setConnected(conn, channel)
+			go r.watchConnection(conn, channel)
+
+			r.logger.Info("Reconnected to RabbitMQ", "attempt", attempt)
+			return
+		}
+
+		r.logger.Error("Failed to reconnect to RabbitMQ", "attempt", attempt, "retry_in", delay.String(), "error", err)
+
+		select {
+		case <-r.closed:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// waitForChannel returns the current channel, blocking while a reconnect is in progress
+func (r *RabbitMQService) waitForChannel(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		r.mu.RLock()
+		state, channel, reconnected := r.state, r.channel, r.reconnected
+		r.mu.RUnlock()
+
+		switch state {
+		case stateConnected:
+			if !channel.IsClosed() {
+				return channel, nil
+			}
+			// The watcher has not noticed the drop yet, wait for it to reconnect
+		case stateClosed:
+			return nil, errors.NewRabbitMQError("connection is closed")
+		case stateDisconnected:
+			return nil, errors.NewRabbitMQError("channel not initialized")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.NewRabbitMQErrorWithCause("gave up waiting for RabbitMQ reconnect", ctx.Err())
+		case <-reconnected:
+		}
+	}
 }
 
 // Close closes the RabbitMQ connection
 func (r *RabbitMQService) Close() error {
+	r.mu.Lock()
+	if r.state == stateClosed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.state = stateClosed
+	close(r.closed)
+	conn, channel := r.conn, r.channel
+	r.mu.Unlock()
+
 	var errs []error
 
-	if r.channel != nil {
-		if err := r.channel.Close(); err != nil {
+	if channel != nil && !channel.IsClosed() {
+		if err := channel.Close(); err != nil {
 			errs = append(errs, errors.NewRabbitMQErrorWithCause("failed to close channel", err))
 		}
 	}
 
-	if r.conn != nil {
-		if err := r.conn.Close(); err != nil {
+	if conn != nil && !conn.IsClosed() {
+		if err := conn.Close(); err != nil {
 			errs = append(errs, errors.NewRabbitMQErrorWithCause("failed to close connection", err))
 		}
 	}
@@ -73,27 +280,39 @@ func (r *RabbitMQService) Close() error {
 
 // DeclareQueues declares all required queues
 func (r *RabbitMQService) DeclareQueues(ctx context.Context) error {
-	if r.channel == nil {
+	r.mu.RLock()
+	channel := r.channel
+	r.mu.RUnlock()
+
+	if channel == nil {
 		return errors.NewRabbitMQError("channel not initialized")
 	}
 
-	// Declare main email tasks queue
-	_, err := r.channel.QueueDeclare(
-		r.queueNames.EmailTasks, // name
-		true,                    // durable
-		false,                   // delete when unused
-		false,                   // exclusive
-		false,                   // no-wait
-		nil,                     // arguments
-	)
-	if err != nil {
-		return errors.NewRabbitMQErrorWithCause("failed to declare email tasks queue", err)
+	return r.declareQueues(channel)
+}
+
+// declareQueues declares all required queues on the given channel
+func (r *RabbitMQService) declareQueues(channel *amqp.Channel) error {
+	// Declare a queue per priority lane; the normal lane is the main email
+	// tasks queue shared with the API
+	for _, priority := range models.JobPriorities {
+		_, err := channel.QueueDeclare(
+			r.queueNames.LaneQueue(priority), // name
+			true,                             // durable
+			false,                            // delete when unused
+			false,                            // exclusive
+			false,                            // no-wait
+			nil,                              // arguments
+		)
+		if err != nil {
+			return errors.NewRabbitMQErrorWithCause(fmt.Sprintf("failed to declare %s priority queue", priority), err)
+		}
 	}
 
-	// Retry queue no longer needed - using single queue with retry delay
+	// Retry queues are declared on demand per delay tier, see declareRetryQueue
 
 	// Declare failed queue
-	_, err = r.channel.QueueDeclare(
+	_, err := channel.QueueDeclare(
 		r.queueNames.EmailFailed, // name
 		true,                     // durable
 		false,                    // delete when unused
@@ -108,73 +327,308 @@ func (r *RabbitMQService) DeclareQueues(ctx context.Context) error {
 	return nil
 }
 
-// ConsumeEmailJobs consumes email jobs from the main queue
-func (r *RabbitMQService) ConsumeEmailJobs(ctx context.Context, handler func(*models.EmailJob)) error {
-	if r.channel == nil {
-		return errors.NewRabbitMQError("channel not initialized")
-	}
-
-	msgs, err := r.channel.Consume(
-		r.queueNames.EmailTasks, // queue
-		"",                      // consumer
-		true,                    // auto-ack
-		false,                   // exclusive
-		false,                   // no-local
-		false,                   // no-wait
-		nil,                     // args
+// declareRetryQueue declares the retry queue for a lane and delay tier. Messages
+// sit in it for the queue's TTL and are then dead-lettered back onto the lane's
+// queue.
+func (r *RabbitMQService) declareRetryQueue(channel *amqp.Channel, priority models.JobPriority, delay time.Duration) (string, error) {
+	queue := r.queueNames.RetryQueue(priority, delay)
+
+	_, err := channel.QueueDeclare(
+		queue, // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-expires":                 (delay + retryQueueExpiryMargin).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": r.queueNames.LaneQueue(priority),
+		},
 	)
 	if err != nil {
-		return errors.NewRabbitMQErrorWithCause("failed to register consumer", err)
+		return "", errors.NewRabbitMQErrorWithCause("failed to declare retry queue", err)
+	}
+
+	return queue, nil
+}
+
+// ConsumeEmailJobs consumes email jobs from every priority lane with manual
+// acknowledgements, running at most r.concurrency handlers at once. It blocks until
+// ctx is cancelled, waits for in-flight handlers to settle their deliveries and
+// requeues anything the broker delivered but no handler picked up. If the
+// connection drops, consumption resumes on the new channel once the service has
+// reconnected.
+func (r *RabbitMQService) ConsumeEmailJobs(ctx context.Context, handler EmailJobHandler) error {
+	// Each delivery is handled on its own goroutine, bounded by a fixed number of
+	// slots. The loop only pulls the next delivery once a slot is free, which
+	// together with the channel prefetch keeps memory use bounded.
+	slots := make(chan struct{}, r.concurrency)
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+
+	for {
+		channel, err := r.waitForChannel(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		stopped, err := r.consumeFromChannel(ctx, channel, handler, slots, &inflight)
+		if stopped || err != nil {
+			return err
+		}
+
+		r.logger.Warn("Delivery channel closed, waiting for RabbitMQ reconnect")
+	}
+}
+
+// lane is the consumer of one priority's queue on the current channel
+type lane struct {
+	priority    models.JobPriority
+	queue       string
+	consumerTag string
+	weight      int
+	msgs        <-chan amqp.Delivery
+
+	// pending holds a delivery taken from msgs but not yet dispatched
+	pending *amqp.Delivery
+	// credit is the lane's smooth weighted round-robin balance
+	credit int
+}
+
+// consumeFromChannel dispatches deliveries from one channel until ctx is cancelled
+// (stopped is true) or a lane's delivery stream closes (stopped is false)
+func (r *RabbitMQService) consumeFromChannel(
+	ctx context.Context,
+	channel *amqp.Channel,
+	handler EmailJobHandler,
+	slots chan struct{},
+	inflight *sync.WaitGroup,
+) (bool, error) {
+	lanes := make([]*lane, 0, len(models.JobPriorities))
+	for _, priority := range models.JobPriorities {
+		l := &lane{
+			priority:    priority,
+			queue:       r.queueNames.LaneQueue(priority),
+			consumerTag: fmt.Sprintf("email-worker-%s-%d", priority, time.Now().UnixNano()),
+			weight:      r.laneWeights[priority],
+		}
+		if l.weight < 1 {
+			l.weight = 1
+		}
+
+		msgs, err := channel.Consume(
+			l.queue,       // queue
+			l.consumerTag, // consumer
+			false,         // auto-ack
+			false,         // exclusive
+			false,         // no-local
+			false,         // no-wait
+			nil,           // args
+		)
+		if err != nil {
+			cancelLanes(channel, lanes)
+			if channel.IsClosed() {
+				// Lost the channel between reconnecting and registering, try again
+				return false, nil
+			}
+			return true, errors.NewRabbitMQErrorWithCause("failed to register consumer", err)
+		}
+		l.msgs = msgs
+		lanes = append(lanes, l)
 	}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
+	for {
+		select {
+		case <-ctx.Done():
+			return true, cancelLanes(channel, lanes)
+		case slots <- struct{}{}:
+		}
+
+		l, msg, ok := nextDelivery(ctx, lanes)
+		if !ok {
+			<-slots
+			if ctx.Err() != nil {
+				return true, cancelLanes(channel, lanes)
+			}
+			// Start over with every lane on a fresh set of consumers
+			cancelLanes(channel, lanes)
+			return false, nil
+		}
+
+		job, err := decodeEmailJob(msg.Body)
+		if err != nil {
+			// Malformed messages will never succeed, drop them instead of requeueing
+			r.logger.Warn("Dropping malformed message", "queue", l.queue, "error", err)
+			msg.Reject(false)
+			<-slots
+			continue
+		}
+
+		inflight.Add(1)
+		go func(msg amqp.Delivery, queue string) {
+			defer func() {
+				<-slots
+				inflight.Done()
+			}()
+
+			// Producers that do not route by priority publish every job to
+			// the main queue; move the others to their own lane
+			if target := r.queueNames.LaneQueue(job.Lane()); target != queue {
+				settleDelivery(msg, r.reroute(ctx, target, job))
 				return
-			case msg := <-msgs:
-				if len(msg.Body) == 0 {
-					continue
-				}
+			}
+			settleDelivery(msg, handler(ctx, job))
+		}(msg, l.queue)
+	}
+}
 
-				// First unmarshal to get the content wrapper
-				var messageWrapper struct {
-					Content json.RawMessage `json:"content"`
-				}
-				if err := json.Unmarshal(msg.Body, &messageWrapper); err != nil {
-					// Log error but continue processing
-					continue
+// nextDelivery waits until a lane has a delivery and returns it. When several
+// lanes have deliveries ready, lanes are picked by smooth weighted round-robin,
+// so each gets a share of the handlers in proportion to its weight and no lane
+// starves. ok is false if a lane's delivery stream closed.
+func nextDelivery(ctx context.Context, lanes []*lane) (*lane, amqp.Delivery, bool) {
+	for {
+		ready := false
+		for _, l := range lanes {
+			if l.pending == nil {
+				select {
+				case msg, ok := <-l.msgs:
+					if !ok {
+						return nil, amqp.Delivery{}, false
+					}
+					l.pending = &msg
+				default:
 				}
+			}
+			ready = ready || l.pending != nil
+		}
+		if ready {
+			break
+		}
 
-				// Then unmarshal the actual EmailJob from content
-				var emailJob models.EmailJob
-				if err := json.Unmarshal(messageWrapper.Content, &emailJob); err != nil {
-					// Log error but continue processing
-					continue
-				}
+		// Nothing buffered anywhere, block until any lane or ctx has something
+		cases := make([]reflect.SelectCase, 0, len(lanes)+1)
+		for _, l := range lanes {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(l.msgs)})
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
 
-				// Validate job before processing
-				if err := emailJob.Validate(); err != nil {
-					// Log validation error but continue
-					continue
-				}
+		chosen, value, ok := reflect.Select(cases)
+		if chosen == len(lanes) {
+			return nil, amqp.Delivery{}, false
+		}
+		if !ok {
+			return nil, amqp.Delivery{}, false
+		}
+		msg := value.Interface().(amqp.Delivery)
+		lanes[chosen].pending = &msg
+	}
+
+	var picked *lane
+	total := 0
+	for _, l := range lanes {
+		if l.pending == nil {
+			continue
+		}
+		l.credit += l.weight
+		total += l.weight
+		if picked == nil || l.credit > picked.credit {
+			picked = l
+		}
+	}
+	picked.credit -= total
+
+	msg := *picked.pending
+	picked.pending = nil
+	return picked, msg, true
+}
+
+// reroute moves a job to the queue of its lane, keeping the original delivery
+// until the broker has confirmed the copy
+func (r *RabbitMQService) reroute(ctx context.Context, queue string, job *models.EmailJob) DeliveryOutcome {
+	if err := r.PublishEmailJob(ctx, queue, job); err != nil {
+		r.logger.Warn("Failed to move job to its priority lane, requeueing", "job_id", job.JobID, "queue", queue, "error", err)
+		return DeliveryRequeue
+	}
+	return DeliveryAck
+}
 
-				handler(&emailJob)
+// cancelLanes stops the broker from pushing more messages, then hands back the
+// ones already buffered so another worker can pick them up
+func cancelLanes(channel *amqp.Channel, lanes []*lane) error {
+	var firstErr error
+	for _, l := range lanes {
+		if l.pending != nil {
+			l.pending.Nack(false, true)
+			l.pending = nil
+		}
+
+		if err := channel.Cancel(l.consumerTag, false); err != nil {
+			if channel.IsClosed() {
+				// Unacked deliveries are requeued by the broker when a channel closes
+				continue
+			}
+			if firstErr == nil {
+				firstErr = errors.NewRabbitMQErrorWithCause("failed to cancel consumer", err)
 			}
+			continue
 		}
-	}()
+		for msg := range l.msgs {
+			msg.Nack(false, true)
+		}
+	}
+	return firstErr
+}
 
-	return nil
+// decodeEmailJob unwraps an email job from a raw message body
+func decodeEmailJob(body []byte) (*models.EmailJob, error) {
+	if len(body) == 0 {
+		return nil, errors.NewValidationError("empty message body")
+	}
+
+	// First unmarshal to get the content wrapper
+	var messageWrapper struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(body, &messageWrapper); err != nil {
+		return nil, errors.NewValidationErrorWithCause("invalid message wrapper", err)
+	}
+
+	// Then unmarshal the actual EmailJob from content. Jobs that decode but do
+	// not validate are still handed over, so they fail with a recorded status.
+	var emailJob models.EmailJob
+	if err := json.Unmarshal(messageWrapper.Content, &emailJob); err != nil {
+		return nil, errors.NewValidationErrorWithCause("invalid email job", err)
+	}
+
+	return &emailJob, nil
 }
 
-// PublishEmailJob publishes an email job to the specified queue
-func (r *RabbitMQService) PublishEmailJob(ctx context.Context, queue string, job *models.EmailJob) error {
-	if r.channel == nil {
-		return errors.NewRabbitMQError("channel not initialized")
+// settleDelivery acks, requeues or rejects a delivery according to the handler outcome.
+// Settling fails if the channel was lost meanwhile, in which case the broker has
+// already requeued the message.
+func settleDelivery(msg amqp.Delivery, outcome DeliveryOutcome) {
+	switch outcome {
+	case DeliveryRequeue:
+		msg.Nack(false, true)
+	case DeliveryReject:
+		msg.Nack(false, false)
+	default:
+		msg.Ack(false)
 	}
+}
 
-	if err := job.Validate(); err != nil {
-		return errors.NewValidationErrorWithCause("invalid job", err)
+// PublishEmailJob publishes an email job to the specified queue, waiting for an
+// in-progress reconnect to finish first. The job is not validated: the failed
+// queue must also take the jobs that failed because they were invalid.
+func (r *RabbitMQService) PublishEmailJob(ctx context.Context, queue string, job *models.EmailJob) error {
+	channel, err := r.waitForChannel(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Marshal the job
@@ -195,7 +649,7 @@ func (r *RabbitMQService) PublishEmailJob(ctx context.Context, queue string, job
 		return errors.NewRabbitMQErrorWithCause("failed to marshal wrapped job", err)
 	}
 
-	err = r.channel.PublishWithContext(
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(
 		ctx,
 		"",    // exchange
 		queue, // routing key
@@ -212,21 +666,69 @@ func (r *RabbitMQService) PublishEmailJob(ctx context.Context, queue string, job
 		return errors.NewRabbitMQErrorWithCause("failed to publish job", err)
 	}
 
+	// Wait for the broker to take responsibility for the message so callers can
+	// safely ack the delivery it replaces
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return errors.NewRabbitMQErrorWithCause("failed to confirm publish", err)
+	}
+	if !acked {
+		return errors.NewRabbitMQError("broker rejected published job")
+	}
+
 	return nil
 }
 
-// Ping checks RabbitMQ connectivity
+// PublishDelayedEmailJob parks a job in its lane's retry queue for the given delay,
+// rounded to the nearest of RetryDelayTiers. The broker moves it back onto the
+// lane's queue once the delay has passed, so pending retries survive worker
+// restarts.
+func (r *RabbitMQService) PublishDelayedEmailJob(ctx context.Context, job *models.EmailJob, delay time.Duration) error {
+	// Retrying a job that cannot be sent only delays its failure
+	if err := job.Validate(); err != nil {
+		return errors.NewValidationErrorWithCause("invalid job", err)
+	}
+
+	// Each distinct delay gets its own queue, so only the fixed tiers are used
+	delay = RetryTier(delay)
+	if delay <= 0 {
+		return r.PublishEmailJob(ctx, r.queueNames.LaneQueue(job.Lane()), job)
+	}
+
+	channel, err := r.waitForChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	queue, err := r.declareRetryQueue(channel, job.Lane(), delay)
+	if err != nil {
+		return err
+	}
+
+	return r.PublishEmailJob(ctx, queue, job)
+}
+
+// Ping checks RabbitMQ connectivity. It returns ErrReconnecting while a dropped
+// connection is being re-established.
 func (r *RabbitMQService) Ping(ctx context.Context) error {
-	if r.conn == nil || r.conn.IsClosed() {
+	r.mu.RLock()
+	state, conn, channel := r.state, r.conn, r.channel
+	r.mu.RUnlock()
+
+	if state == stateReconnecting {
+		return ErrReconnecting
+	}
+
+	if conn == nil || conn.IsClosed() {
 		return errors.NewRabbitMQError("connection is closed")
 	}
 
-	if r.channel == nil {
+	if channel == nil {
 		return errors.NewRabbitMQError("channel is not initialized")
 	}
 
 	// Try to declare a temporary queue to test connectivity
-	_, err := r.channel.QueueDeclare(
+	_, err := channel.QueueDeclare(
 		"",    // name (auto-generated)
 		false, // durable
 		true,  // delete when unused
@@ -241,7 +743,43 @@ func (r *RabbitMQService) Ping(ctx context.Context) error {
 	return nil
 }
 
+// LaneStats reports the depth and consumer count of every priority lane's
+// queue. The queues are inspected on a channel of their own, since a failed
+// inspection closes the channel it runs on.
+func (r *RabbitMQService) LaneStats(ctx context.Context) (map[string]models.LaneStats, error) {
+	r.mu.RLock()
+	conn := r.conn
+	r.mu.RUnlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, errors.NewRabbitMQError("connection is closed")
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, errors.NewRabbitMQErrorWithCause("failed to open channel", err)
+	}
+	defer channel.Close()
+
+	stats := make(map[string]models.LaneStats, len(models.JobPriorities))
+	for _, priority := range models.JobPriorities {
+		queue := r.queueNames.LaneQueue(priority)
+		inspected, err := channel.QueueDeclarePassive(queue, true, false, false, false, nil)
+		if err != nil {
+			return nil, errors.NewRabbitMQErrorWithCause(fmt.Sprintf("failed to inspect queue %s", queue), err)
+		}
+		stats[string(priority)] = models.LaneStats{
+			Queue:     queue,
+			Depth:     inspected.Messages,
+			Consumers: inspected.Consumers,
+			Weight:    r.laneWeights[priority],
+		}
+	}
+
+	return stats, nil
+}
+
 // GetQueueNames returns the queue names configuration
 func (r *RabbitMQService) GetQueueNames() *QueueNames {
 	return r.queueNames
-}
\ No newline at end of file
+}
@@ -0,0 +1,175 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/pkg/logger"
+)
+
+// testLanes returns lanes with the default weights fed by buffered channels
+func testLanes() ([]*lane, map[models.JobPriority]chan amqp.Delivery) {
+	weights := map[models.JobPriority]int{models.JobPriorityHigh: 8, models.JobPriorityNormal: 4, models.JobPriorityLow: 1}
+	feeds := make(map[models.JobPriority]chan amqp.Delivery)
+
+	var lanes []*lane
+	for _, priority := range models.JobPriorities {
+		feed := make(chan amqp.Delivery, 1000)
+		feeds[priority] = feed
+		lanes = append(lanes, &lane{priority: priority, weight: weights[priority], msgs: feed})
+	}
+	return lanes, feeds
+}
+
+// publish queues count deliveries on a lane, named after the lane
+func publish(feed chan amqp.Delivery, priority models.JobPriority, count int) {
+	for i := 0; i < count; i++ {
+		feed <- amqp.Delivery{Body: []byte(fmt.Sprintf("%s-%d", priority, i))}
+	}
+}
+
+func TestNextDeliveryFavorsHighPriorityDuringFlood(t *testing.T) {
+	lanes, feeds := testLanes()
+	publish(feeds[models.JobPriorityLow], models.JobPriorityLow, 500)
+	publish(feeds[models.JobPriorityHigh], models.JobPriorityHigh, 5)
+
+	// Every high-priority job waiting behind the flood goes out within the
+	// first picks; the flood only gets its weighted share
+	high := 0
+	for i := 0; i < 6; i++ {
+		l, _, ok := nextDelivery(context.Background(), lanes)
+		if !ok {
+			t.Fatal("nextDelivery found no delivery")
+		}
+		if l.priority == models.JobPriorityHigh {
+			high++
+		}
+	}
+	if high != 5 {
+		t.Errorf("%d of the first 6 picks were high priority, want all 5 high jobs", high)
+	}
+
+	// While the flood continues, a new high-priority job is next
+	for i := 0; i < 20; i++ {
+		if l, _, _ := nextDelivery(context.Background(), lanes); l.priority != models.JobPriorityLow {
+			t.Fatalf("pick %d came from the %s lane, want low", i, l.priority)
+		}
+	}
+	publish(feeds[models.JobPriorityHigh], models.JobPriorityHigh, 1)
+	if l, msg, _ := nextDelivery(context.Background(), lanes); l.priority != models.JobPriorityHigh {
+		t.Errorf("picked %s after a high-priority job arrived, want it first", msg.Body)
+	}
+}
+
+func TestNextDeliverySharesByWeight(t *testing.T) {
+	lanes, feeds := testLanes()
+	for _, priority := range models.JobPriorities {
+		publish(feeds[priority], priority, 500)
+	}
+
+	picks := make(map[models.JobPriority]int)
+	for i := 0; i < 130; i++ {
+		l, _, _ := nextDelivery(context.Background(), lanes)
+		picks[l.priority]++
+	}
+
+	want := map[models.JobPriority]int{models.JobPriorityHigh: 80, models.JobPriorityNormal: 40, models.JobPriorityLow: 10}
+	for priority, count := range want {
+		if picks[priority] != count {
+			t.Errorf("%s lane picked %d times, want %d", priority, picks[priority], count)
+		}
+	}
+}
+
+func TestNextDeliveryStops(t *testing.T) {
+	lanes, feeds := testLanes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, ok := nextDelivery(ctx, lanes); ok {
+		t.Error("nextDelivery returned a delivery after ctx was cancelled")
+	}
+
+	close(feeds[models.JobPriorityNormal])
+	if _, _, ok := nextDelivery(context.Background(), lanes); ok {
+		t.Error("nextDelivery returned a delivery from a closed lane")
+	}
+}
+
+func TestLanePrefetch(t *testing.T) {
+	tests := []struct {
+		prefetch, lanes, want int
+	}{
+		{prefetch: 13, lanes: 3, want: 11},
+		{prefetch: 10, lanes: 1, want: 10},
+		{prefetch: 2, lanes: 3, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := lanePrefetch(tt.prefetch, tt.lanes); got != tt.want {
+			t.Errorf("lanePrefetch(%d, %d) = %d, want %d", tt.prefetch, tt.lanes, got, tt.want)
+		}
+	}
+}
+
+func TestPublishValidation(t *testing.T) {
+	service := NewRabbitMQService("amqp://localhost", 1, 4, nil, logger.NewLogger(&logger.Config{Level: "error"}))
+	job := models.NewEmailJob("job-1", "not an address", "Hello", "Body text", 3)
+
+	// An invalid job still reaches the failed queue; the disconnected service
+	// is the only reason this publish fails
+	err := service.PublishEmailJob(context.Background(), DefaultQueueNames().EmailFailed, job)
+	if err == nil || errors.ErrorCode(err) != errors.RabbitMQErrorCode {
+		t.Errorf("PublishEmailJob returned %v, want the connection error", err)
+	}
+
+	// but it is not retried
+	err = service.PublishDelayedEmailJob(context.Background(), job, RetryDelayTiers[0])
+	if errors.ErrorCode(err) != errors.ValidationErrorCode {
+		t.Errorf("PublishDelayedEmailJob returned %v, want a validation error", err)
+	}
+}
+
+// fakeAcknowledger records how deliveries were settled
+type fakeAcknowledger struct {
+	settled []string
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("ack %d", tag))
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("nack %d requeue=%v", tag, requeue))
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("reject %d requeue=%v", tag, requeue))
+	return nil
+}
+
+func TestSettleDelivery(t *testing.T) {
+	tests := []struct {
+		outcome DeliveryOutcome
+		want    string
+	}{
+		{outcome: DeliveryAck, want: "ack 7"},
+		{outcome: DeliveryRequeue, want: "nack 7 requeue=true"},
+		{outcome: DeliveryReject, want: "nack 7 requeue=false"},
+	}
+
+	for _, tt := range tests {
+		acknowledger := &fakeAcknowledger{}
+		settleDelivery(amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 7}, tt.outcome)
+		if len(acknowledger.settled) != 1 || acknowledger.settled[0] != tt.want {
+			t.Errorf("outcome %v settled as %v, want %q", tt.outcome, acknowledger.settled, tt.want)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/pkg/logger"
+)
+
+// Template directory layout. Every file in layouts/ and partials/ is shared by
+// all templates under its file name, so a body can start with
+// {{template "base.html" .}} and fill the layout's blocks with
+// {{define "content"}}...{{end}}. Each other directory is one template, named
+// by the directory, with a subject and at least one body. Subdirectories of a
+// template named after a locale, such as welcome/pt-BR/, hold translations.
+const (
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+
+	subjectFile = "subject.txt"
+	textFile    = "body.txt"
+	htmlFile    = "body.html"
+)
+
+// FileTemplateService implements TemplateService with templates loaded from a
+// directory. Subjects and text bodies use text/template, HTML bodies use
+// html/template so variables are escaped. The directory is polled for changes
+// and reloaded in place; a broken edit keeps the previous templates live.
+type FileTemplateService struct {
+	dir           string
+	defaultLocale string
+	logger        *logger.Logger
+
+	templates atomic.Pointer[templateSet]
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// templateSet is an immutable snapshot of the compiled templates in the directory
+type templateSet struct {
+	fingerprint string
+	templates   map[string]*compiledTemplate
+}
+
+// compiledTemplate holds the locale variants of one template. The variant at
+// the top of the template directory is keyed by "".
+type compiledTemplate struct {
+	variants map[string]*templateVariant
+}
+
+// templateVariant is one translation of a template. Its parts are parsed once
+// as prototypes and cloned for each formatting locale, because html/template
+// cannot rebind functions once a template has been executed.
+type templateVariant struct {
+	prototype *templateParts
+
+	mu    sync.Mutex
+	bound map[string]*templateParts
+}
+
+// templateParts are the parsed subject and bodies; text or html may be nil
+type templateParts struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// NewFileTemplateService loads the templates in dir and, when reloadInterval is
+// positive, watches it for changes. defaultLocale is the language of the
+// top-level variants and ends every fallback chain. An empty dir disables
+// templating.
+func NewFileTemplateService(dir, defaultLocale string, reloadInterval time.Duration, logger *logger.Logger) (*FileTemplateService, error) {
+	s := &FileTemplateService{
+		dir:           dir,
+		defaultLocale: normalizeLocale(defaultLocale),
+		logger:        logger.WithComponent("templates"),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	s.templates.Store(&templateSet{templates: make(map[string]*compiledTemplate)})
+
+	if dir == "" {
+		close(s.done)
+		return s, nil
+	}
+
+	set, err := loadTemplateSet(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.templates.Store(set)
+	s.logger.Info("Templates loaded", "dir", dir, "count", len(set.templates))
+
+	if reloadInterval > 0 {
+		go s.watch(reloadInterval)
+	} else {
+		close(s.done)
+	}
+
+	return s, nil
+}
+
+// Render executes a template's subject and bodies with the given variables.
+// The translation is picked along the locale's fallback chain (pt-BR, pt, then
+// the default locale) and dates and numbers are formatted for the requested
+// locale when the translation is in its language. Unknown templates and render
+// failures are validation errors: retrying the job cannot fix them.
+func (s *FileTemplateService) Render(ctx context.Context, templateID, locale string, variables map[string]any) (*RenderedTemplate, error) {
+	if s.dir == "" {
+		return nil, errors.NewConfigError("template directory is not configured")
+	}
+
+	tmpl, ok := s.templates.Load().templates[templateID]
+	if !ok {
+		return nil, errors.NewValidationError(fmt.Sprintf("template %s not found", templateID))
+	}
+
+	chain := localeChain(locale, s.defaultLocale)
+	variant, used := tmpl.resolve(chain, s.defaultLocale)
+
+	// Regional conventions of the requested locale apply as long as the text is
+	// in its language; a fallback to another language formats like that language
+	formatChain := chain
+	if language(used) != language(chain[0]) {
+		formatChain = localeChain(used, s.defaultLocale)
+	}
+
+	parts, err := variant.forFormat(formatForChain(formatChain))
+	if err != nil {
+		return nil, errors.NewValidationErrorWithCause(fmt.Sprintf("failed to prepare template %s", templateID), err)
+	}
+
+	rendered, err := parts.render(variables)
+	if err != nil {
+		return nil, errors.NewValidationErrorWithCause(fmt.Sprintf("failed to render template %s", templateID), err)
+	}
+	rendered.Locale = used
+
+	return rendered, nil
+}
+
+// Close stops watching the template directory
+func (s *FileTemplateService) Close() error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return nil
+}
+
+// watch polls the directory and reloads the templates when any file changes
+func (s *FileTemplateService) watch(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// A change is only loaded once it has been stable for a full interval, so
+	// files caught halfway through being written are not picked up. Ticks
+	// missed during a slow load arrive back to back, so the interval is timed
+	// rather than counted in ticks. A version that failed to load is
+	// remembered so the error is logged once.
+	var pending, failed string
+	var pendingSince time.Time
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := fingerprintDir(s.dir)
+		if err != nil {
+			s.logger.Warn("Failed to scan template directory", "error", err)
+			continue
+		}
+		if fingerprint == s.templates.Load().fingerprint || fingerprint == failed {
+			continue
+		}
+		if fingerprint != pending {
+			pending, pendingSince = fingerprint, time.Now()
+			continue
+		}
+		if time.Since(pendingSince) < interval {
+			continue
+		}
+
+		set, err := loadTemplateSet(s.dir)
+		if err != nil {
+			failed = fingerprint
+			s.logger.Error("Failed to reload templates, keeping the previous version", "error", err)
+			continue
+		}
+
+		// A file that changed while it was being read is loaded once it settles
+		if current, err := fingerprintDir(s.dir); err != nil || current != set.fingerprint {
+			continue
+		}
+
+		failed = ""
+		s.templates.Store(set)
+		s.logger.Info("Templates reloaded", "count", len(set.templates))
+	}
+}
+
+// resolve picks the first variant along the chain, falling back to the
+// top-level variant, and reports the locale it is written in
+func (t *compiledTemplate) resolve(chain []string, defaultLocale string) (*templateVariant, string) {
+	for _, locale := range chain {
+		if variant, ok := t.variants[locale]; ok {
+			return variant, locale
+		}
+	}
+	return t.variants[""], defaultLocale
+}
+
+// forFormat returns the variant's parts bound to a locale's formatting
+// functions, cloning the prototype the first time the locale is used
+func (v *templateVariant) forFormat(format *localeFormat) (*templateParts, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if parts, ok := v.bound[format.tag]; ok {
+		return parts, nil
+	}
+
+	parts := &templateParts{}
+	funcs := format.funcs()
+
+	subject, err := v.prototype.subject.Clone()
+	if err != nil {
+		return nil, err
+	}
+	parts.subject = subject.Funcs(funcs)
+
+	if v.prototype.text != nil {
+		text, err := v.prototype.text.Clone()
+		if err != nil {
+			return nil, err
+		}
+		parts.text = text.Funcs(funcs)
+	}
+
+	if v.prototype.html != nil {
+		html, err := v.prototype.html.Clone()
+		if err != nil {
+			return nil, err
+		}
+		parts.html = html.Funcs(htmltemplate.FuncMap(funcs))
+	}
+
+	v.bound[format.tag] = parts
+	return parts, nil
+}
+
+// render executes each part of the template
+func (t *templateParts) render(variables map[string]any) (*RenderedTemplate, error) {
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, variables); err != nil {
+		return nil, err
+	}
+
+	// The subject ends up in a header, where a line break would start a new one
+	subject := strings.TrimSpace(buf.String())
+	if subject == "" {
+		return nil, fmt.Errorf("rendered subject is empty")
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("rendered subject spans multiple lines")
+	}
+
+	rendered := &RenderedTemplate{Subject: subject}
+
+	if t.text != nil {
+		buf.Reset()
+		if err := t.text.Execute(&buf, variables); err != nil {
+			return nil, err
+		}
+		rendered.Text = buf.String()
+	}
+
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, variables); err != nil {
+			return nil, err
+		}
+		rendered.HTML = buf.String()
+	}
+
+	return rendered, nil
+}
+
+// loadTemplateSet parses the shared layouts and partials and compiles every template in dir
+func loadTemplateSet(dir string) (*templateSet, error) {
+	fingerprint, err := fingerprintDir(dir)
+	if err != nil {
+		return nil, errors.NewConfigErrorWithCause("failed to scan template directory", err)
+	}
+
+	// Referencing a variable the job did not provide is an error, not an empty
+	// string. The formatting functions are placeholders until a locale is bound.
+	funcs := localeFormats["en"].funcs()
+	sharedText := texttemplate.New("").Option("missingkey=error").Funcs(funcs)
+	sharedHTML := htmltemplate.New("").Option("missingkey=error").Funcs(htmltemplate.FuncMap(funcs))
+
+	for _, shared := range []string{layoutsDir, partialsDir} {
+		textFiles, htmlFiles, err := sharedFiles(filepath.Join(dir, shared))
+		if err != nil {
+			return nil, errors.NewConfigErrorWithCause("failed to list shared templates", err)
+		}
+		if len(textFiles) > 0 {
+			if _, err := sharedText.ParseFiles(textFiles...); err != nil {
+				return nil, errors.NewConfigErrorWithCause("failed to parse shared templates", err)
+			}
+		}
+		if len(htmlFiles) > 0 {
+			if _, err := sharedHTML.ParseFiles(htmlFiles...); err != nil {
+				return nil, errors.NewConfigErrorWithCause("failed to parse shared templates", err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.NewConfigErrorWithCause("failed to read template directory", err)
+	}
+
+	set := &templateSet{
+		fingerprint: fingerprint,
+		templates:   make(map[string]*compiledTemplate),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == layoutsDir || name == partialsDir || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		tmpl, err := compileTemplate(filepath.Join(dir, name), sharedText, sharedHTML)
+		if err != nil {
+			return nil, errors.NewConfigErrorWithCause(fmt.Sprintf("failed to load template %s", name), err)
+		}
+		set.templates[name] = tmpl
+	}
+
+	return set, nil
+}
+
+// compileTemplate parses one template directory and its locale subdirectories
+func compileTemplate(dir string, sharedText *texttemplate.Template, sharedHTML *htmltemplate.Template) (*compiledTemplate, error) {
+	variant, err := compileVariant(dir, sharedText, sharedHTML)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &compiledTemplate{variants: map[string]*templateVariant{"": variant}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		locale := normalizeLocale(entry.Name())
+		if !isLocaleTag(locale) {
+			return nil, fmt.Errorf("subdirectory %s is not a locale", entry.Name())
+		}
+
+		variant, err := compileVariant(filepath.Join(dir, entry.Name()), sharedText, sharedHTML)
+		if err != nil {
+			return nil, fmt.Errorf("locale %s: %w", locale, err)
+		}
+		tmpl.variants[locale] = variant
+	}
+
+	return tmpl, nil
+}
+
+// compileVariant parses the parts of one translation. Each part is parsed into
+// its own copy of the shared templates so blocks defined by one body cannot
+// leak into another.
+func compileVariant(dir string, sharedText *texttemplate.Template, sharedHTML *htmltemplate.Template) (*templateVariant, error) {
+	parts := &templateParts{}
+
+	subject, err := parseTextFile(sharedText, filepath.Join(dir, subjectFile))
+	if err != nil {
+		return nil, err
+	}
+	if subject == nil {
+		return nil, fmt.Errorf("%s is required", subjectFile)
+	}
+	parts.subject = subject
+
+	if parts.text, err = parseTextFile(sharedText, filepath.Join(dir, textFile)); err != nil {
+		return nil, err
+	}
+	if parts.html, err = parseHTMLFile(sharedHTML, filepath.Join(dir, htmlFile)); err != nil {
+		return nil, err
+	}
+
+	if parts.text == nil && parts.html == nil {
+		return nil, fmt.Errorf("%s or %s is required", textFile, htmlFile)
+	}
+
+	return &templateVariant{
+		prototype: parts,
+		bound:     make(map[string]*templateParts),
+	}, nil
+}
+
+// parseTextFile parses path on top of the shared templates, returning nil if it does not exist
+func parseTextFile(shared *texttemplate.Template, path string) (*texttemplate.Template, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	clone, err := shared.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clone.ParseFiles(path); err != nil {
+		return nil, err
+	}
+	return clone.Lookup(filepath.Base(path)), nil
+}
+
+// parseHTMLFile parses path on top of the shared templates, returning nil if it does not exist
+func parseHTMLFile(shared *htmltemplate.Template, path string) (*htmltemplate.Template, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	clone, err := shared.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clone.ParseFiles(path); err != nil {
+		return nil, err
+	}
+	return clone.Lookup(filepath.Base(path)), nil
+}
+
+// sharedFiles lists the .txt and .html files directly inside dir, if it exists
+func sharedFiles(dir string) (textFiles, htmlFiles []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".txt":
+			textFiles = append(textFiles, filepath.Join(dir, entry.Name()))
+		case ".html":
+			htmlFiles = append(htmlFiles, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return textFiles, htmlFiles, nil
+}
+
+// fingerprintDir hashes the name, size and modification time of every file
+// under dir, so any edit, addition or removal changes the result
+func fingerprintDir(dir string) (string, error) {
+	hash := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,277 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/pkg/logger"
+)
+
+// testLogger returns a logger that stays quiet unless something is wrong
+func testLogger() *logger.Logger {
+	return logger.NewLogger(&logger.Config{Level: "error"})
+}
+
+// newTestService loads the templates in dir without watching it
+func newTestService(t *testing.T, dir string) *FileTemplateService {
+	t.Helper()
+
+	service, err := NewFileTemplateService(dir, "en", 0, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileTemplateService returned %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+// copyTestdata copies the testdata templates to a directory the test may edit
+func copyTestdata(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "templates")
+	if err := os.CopyFS(dir, os.DirFS("testdata")); err != nil {
+		t.Fatalf("failed to copy testdata: %v", err)
+	}
+	return dir
+}
+
+// writeFile replaces a file under dir, creating its directory. The file is
+// written elsewhere and renamed into place, as deployments should, so a
+// watcher never sees it half written.
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	staged := filepath.Join(t.TempDir(), filepath.Base(name))
+	if err := os.WriteFile(staged, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", staged, err)
+	}
+	if err := os.Rename(staged, path); err != nil {
+		t.Fatalf("failed to move %s into place: %v", path, err)
+	}
+}
+
+var welcomeVariables = map[string]any{
+	"Name":    "Ana <b>",
+	"Balance": 1234.5,
+	"Joined":  "2024-03-07",
+}
+
+func TestRenderResolvesLayoutsAndPartials(t *testing.T) {
+	service := newTestService(t, "testdata")
+
+	rendered, err := service.Render(context.Background(), "welcome", "en", welcomeVariables)
+	if err != nil {
+		t.Fatalf("Render returned %v", err)
+	}
+
+	if rendered.Subject != "Welcome, Ana <b>" {
+		t.Errorf("Subject = %q", rendered.Subject)
+	}
+	if want := "Hello Ana <b>, your balance is 1,234.50.\n-- \nSent to Ana <b>\n"; rendered.Text != want {
+		t.Errorf("Text = %q, want %q", rendered.Text, want)
+	}
+	// The layout, the body's block and the partial all escape variables
+	if want := "<html><body><h1>Hello Ana &lt;b&gt;</h1><p>Member since March 7, 2024</p><p>Sent to Ana &lt;b&gt;</p></body></html>\n"; rendered.HTML != want {
+		t.Errorf("HTML = %q, want %q", rendered.HTML, want)
+	}
+	if rendered.Locale != "en" {
+		t.Errorf("Locale = %q, want en", rendered.Locale)
+	}
+}
+
+func TestRenderTextOnlyTemplate(t *testing.T) {
+	service := newTestService(t, "testdata")
+
+	rendered, err := service.Render(context.Background(), "notice", "", map[string]any{"Name": "Ana", "Renews": "2024-12-31"})
+	if err != nil {
+		t.Fatalf("Render returned %v", err)
+	}
+	if rendered.Text != "Your plan renews on 12/31/2024." || rendered.HTML != "" {
+		t.Errorf("rendered text %q and HTML %q, want the text body only", rendered.Text, rendered.HTML)
+	}
+}
+
+func TestRenderPicksTranslation(t *testing.T) {
+	service := newTestService(t, "testdata")
+
+	tests := []struct {
+		locale  string
+		used    string
+		subject string
+		text    string
+	}{
+		{locale: "pt-BR", used: "pt", subject: "Bem-vindo, Ana", text: "Olá Ana, seu saldo é 1.234,50."},
+		{locale: "pt_br", used: "pt", subject: "Bem-vindo, Ana", text: "Olá Ana, seu saldo é 1.234,50."},
+		{locale: "de", used: "en", subject: "Welcome, Ana", text: "Hello Ana, your balance is 1,234.50."},
+		{locale: "", used: "en", subject: "Welcome, Ana", text: "Hello Ana, your balance is 1,234.50."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			variables := map[string]any{"Name": "Ana", "Balance": 1234.5, "Joined": "2024-03-07"}
+			rendered, err := service.Render(context.Background(), "welcome", tt.locale, variables)
+			if err != nil {
+				t.Fatalf("Render returned %v", err)
+			}
+			if rendered.Locale != tt.used {
+				t.Errorf("Locale = %q, want %q", rendered.Locale, tt.used)
+			}
+			if rendered.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", rendered.Subject, tt.subject)
+			}
+			if text, _, _ := strings.Cut(rendered.Text, "\n"); text != tt.text {
+				t.Errorf("Text starts %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestRenderFailuresAreNotRetryable(t *testing.T) {
+	dir := copyTestdata(t)
+	writeFile(t, dir, "multiline/subject.txt", "Line one\n{{.Second}}")
+	writeFile(t, dir, "multiline/body.txt", "Body")
+	writeFile(t, dir, "nolayout/subject.txt", "Subject")
+	writeFile(t, dir, "nolayout/body.txt", `{{template "missing.txt" .}}`)
+	service := newTestService(t, dir)
+
+	tests := []struct {
+		name       string
+		templateID string
+		variables  map[string]any
+	}{
+		{name: "unknown template", templateID: "missing", variables: welcomeVariables},
+		{name: "missing variable", templateID: "welcome", variables: map[string]any{"Name": "Ana", "Joined": "2024-03-07"}},
+		{name: "missing variable in partial", templateID: "notice", variables: map[string]any{"Renews": "2024-12-31"}},
+		{name: "unformattable value", templateID: "welcome", variables: map[string]any{"Name": "Ana", "Balance": "lots", "Joined": "2024-03-07"}},
+		{name: "subject with a line break", templateID: "multiline", variables: map[string]any{"Second": "line two"}},
+		{name: "unknown layout", templateID: "nolayout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Render(context.Background(), tt.templateID, "en", tt.variables)
+			if err == nil {
+				t.Fatal("Render returned no error")
+			}
+			if !errors.IsValidationError(err) || errors.IsRetryableError(err) {
+				t.Errorf("Render returned %v (%s), want a validation error that is not retried", err, errors.ErrorCode(err))
+			}
+		})
+	}
+}
+
+func TestRenderWithoutDirectory(t *testing.T) {
+	service := newTestService(t, "")
+
+	_, err := service.Render(context.Background(), "welcome", "en", welcomeVariables)
+	if !errors.IsConfigError(err) {
+		t.Errorf("Render returned %v, want a config error", err)
+	}
+}
+
+func TestLoadRejectsBrokenTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "no subject", files: map[string]string{"broken/body.txt": "Body"}},
+		{name: "no body", files: map[string]string{"broken/subject.txt": "Subject"}},
+		{name: "syntax error", files: map[string]string{"broken/subject.txt": "Subject", "broken/body.html": "{{if .X}}"}},
+		{name: "bad partial", files: map[string]string{"partials/bad.html": "{{end}}"}},
+		{name: "not a locale", files: map[string]string{"broken/subject.txt": "Subject", "broken/body.txt": "Body", "broken/images/subject.txt": "Subject"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := copyTestdata(t)
+			for name, content := range tt.files {
+				writeFile(t, dir, name, content)
+			}
+
+			_, err := NewFileTemplateService(dir, "en", 0, testLogger())
+			if !errors.IsConfigError(err) {
+				t.Errorf("NewFileTemplateService returned %v, want a config error", err)
+			}
+		})
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	dir := copyTestdata(t)
+	service, err := NewFileTemplateService(dir, "en", 10*time.Millisecond, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileTemplateService returned %v", err)
+	}
+	defer service.Close()
+
+	// waitForSubject renders the notice until its subject is want
+	waitForSubject := func(want string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rendered, err := service.Render(context.Background(), "notice", "en", map[string]any{"Name": "Ana", "Renews": "2024-12-31"})
+			if err == nil && rendered.Subject == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("subject is %v (error %v) after 5s, want %q", rendered, err, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writeFile(t, dir, "notice/subject.txt", "Updated notice for {{.Name}}")
+	waitForSubject("Updated notice for Ana")
+
+	// A new template appears without a restart
+	writeFile(t, dir, "farewell/subject.txt", "Goodbye")
+	writeFile(t, dir, "farewell/body.txt", "Bye")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := service.Render(context.Background(), "farewell", "en", nil); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("new template was not loaded after 5s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A broken edit keeps the previous templates live
+	writeFile(t, dir, "notice/subject.txt", "{{if}}")
+	time.Sleep(100 * time.Millisecond)
+	waitForSubject("Updated notice for Ana")
+
+	// and fixing it is picked up again
+	writeFile(t, dir, "notice/subject.txt", "Fixed notice for {{.Name}}")
+	waitForSubject("Fixed notice for Ana")
+}
+
+func TestCloseStopsWatching(t *testing.T) {
+	service, err := NewFileTemplateService(copyTestdata(t), "en", time.Millisecond, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileTemplateService returned %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		service.Close()
+		service.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+}
@@ -0,0 +1,25 @@
+package templates
+
+import (
+	"context"
+)
+
+// TemplateService renders email content from stored templates
+type TemplateService interface {
+	// Render produces the subject and bodies of a template for the given
+	// locale and variables
+	Render(ctx context.Context, templateID, locale string, variables map[string]any) (*RenderedTemplate, error)
+
+	// Stop watching for template changes
+	Close() error
+}
+
+// RenderedTemplate is the email content produced from a template. Text or HTML
+// is empty when the template does not define that body. Locale is the
+// translation that was used, which may differ from the one requested.
+type RenderedTemplate struct {
+	Subject string
+	Text    string
+	HTML    string
+	Locale  string
+}
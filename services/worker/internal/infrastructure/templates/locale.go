@@ -0,0 +1,235 @@
+package templates
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// normalizeLocale canonicalizes a BCP 47 style tag: "pt_br" becomes "pt-BR" and
+// "zh-hant-tw" becomes "zh-Hant-TW"
+func normalizeLocale(locale string) string {
+	subtags := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	for i, subtag := range subtags {
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 2:
+			subtags[i] = strings.ToUpper(subtag)
+		case len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+// localeTag matches a normalized tag: a language followed by script, region or variant subtags
+var localeTag = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// isLocaleTag reports whether a normalized locale looks like a BCP 47 tag
+func isLocaleTag(locale string) bool {
+	return localeTag.MatchString(locale)
+}
+
+// localeChain lists the locales to try for a requested locale, most specific
+// first, ending with the default: pt-BR gives pt-BR, pt, then the default
+func localeChain(requested, defaultLocale string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(locale string) {
+		if locale != "" && !seen[locale] {
+			seen[locale] = true
+			chain = append(chain, locale)
+		}
+	}
+
+	for locale := normalizeLocale(requested); locale != ""; {
+		add(locale)
+		cut := strings.LastIndexByte(locale, '-')
+		if cut < 0 {
+			break
+		}
+		locale = locale[:cut]
+	}
+	add(normalizeLocale(defaultLocale))
+
+	return chain
+}
+
+// language returns the primary language subtag of a normalized locale
+func language(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
+
+// localeFormat describes how numbers and dates are written in a locale
+type localeFormat struct {
+	tag        string
+	decimal    string
+	group      string
+	shortDate  string
+	timeLayout string
+	// longDate uses {day}, {month} and {year} placeholders
+	longDate string
+	months   [12]string
+}
+
+var (
+	englishMonths    = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	portugueseMonths = [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"}
+	spanishMonths    = [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+	frenchMonths     = [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}
+	germanMonths     = [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}
+	italianMonths    = [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"}
+	dutchMonths      = [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"}
+)
+
+// localeFormats holds the formatting conventions of the supported locales.
+// Locales without an entry use the closest one in their fallback chain.
+var localeFormats = map[string]*localeFormat{
+	"en":    {tag: "en", decimal: ".", group: ",", shortDate: "1/2/2006", timeLayout: "3:04 PM", longDate: "{month} {day}, {year}", months: englishMonths},
+	"en-GB": {tag: "en-GB", decimal: ".", group: ",", shortDate: "02/01/2006", timeLayout: "15:04", longDate: "{day} {month} {year}", months: englishMonths},
+	"pt":    {tag: "pt", decimal: ",", group: ".", shortDate: "02/01/2006", timeLayout: "15:04", longDate: "{day} de {month} de {year}", months: portugueseMonths},
+	"es":    {tag: "es", decimal: ",", group: ".", shortDate: "02/01/2006", timeLayout: "15:04", longDate: "{day} de {month} de {year}", months: spanishMonths},
+	"fr":    {tag: "fr", decimal: ",", group: "\u202f", shortDate: "02/01/2006", timeLayout: "15:04", longDate: "{day} {month} {year}", months: frenchMonths},
+	"de":    {tag: "de", decimal: ",", group: ".", shortDate: "02.01.2006", timeLayout: "15:04", longDate: "{day}. {month} {year}", months: germanMonths},
+	"it":    {tag: "it", decimal: ",", group: ".", shortDate: "02/01/2006", timeLayout: "15:04", longDate: "{day} {month} {year}", months: italianMonths},
+	"nl":    {tag: "nl", decimal: ",", group: ".", shortDate: "02-01-2006", timeLayout: "15:04", longDate: "{day} {month} {year}", months: dutchMonths},
+}
+
+// formatForChain returns the formatting conventions of the first locale in the
+// chain that has them, falling back to English
+func formatForChain(chain []string) *localeFormat {
+	for _, locale := range chain {
+		if format, ok := localeFormats[locale]; ok {
+			return format
+		}
+	}
+	return localeFormats["en"]
+}
+
+// funcs returns the template functions that format values for this locale
+func (f *localeFormat) funcs() template.FuncMap {
+	return template.FuncMap{
+		"locale":         func() string { return f.tag },
+		"formatNumber":   f.formatNumber,
+		"formatDate":     f.formatDate,
+		"formatDateLong": f.formatDateLong,
+		"formatDateTime": f.formatDateTime,
+	}
+}
+
+// formatNumber writes a number with the locale's separators, rounded to the
+// given number of decimals (default 0)
+func (f *localeFormat) formatNumber(value any, decimals ...int) (string, error) {
+	number, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+
+	places := 0
+	if len(decimals) > 0 {
+		places = decimals[0]
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(number), 'f', places, 64)
+	whole, fraction, _ := strings.Cut(formatted, ".")
+
+	var b strings.Builder
+	if number < 0 && strings.Trim(formatted, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(f.decimal + fraction)
+	}
+
+	return b.String(), nil
+}
+
+// formatDate writes a date in the locale's short numeric form
+func (f *localeFormat) formatDate(value any) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(f.shortDate), nil
+}
+
+// formatDateLong writes a date with the month spelled out
+func (f *localeFormat) formatDateLong(value any) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.NewReplacer(
+		"{day}", strconv.Itoa(t.Day()),
+		"{month}", f.months[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+	).Replace(f.longDate), nil
+}
+
+// formatDateTime writes a short date followed by the time of day
+func (f *localeFormat) formatDateTime(value any) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(f.shortDate + " " + f.timeLayout), nil
+}
+
+// toFloat accepts the numeric types template variables arrive as, including
+// JSON numbers and numeric strings
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return number, nil
+	default:
+		return 0, fmt.Errorf("cannot format %T as a number", value)
+	}
+}
+
+// toTime accepts a time.Time, an RFC 3339 timestamp, a YYYY-MM-DD date or Unix seconds
+func toTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.DateOnly, v); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+	default:
+		seconds, err := toFloat(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot format %T as a date", value)
+		}
+		return time.Unix(int64(seconds), 0).UTC(), nil
+	}
+}
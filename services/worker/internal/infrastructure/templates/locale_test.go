@@ -0,0 +1,224 @@
+package templates
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"pt_br":      "pt-BR",
+		"PT-br":      "pt-BR",
+		"zh-hant-tw": "zh-Hant-TW",
+		"en":         "en",
+		"es-419":     "es-419",
+		"":           "",
+	}
+	for input, want := range tests {
+		if got := normalizeLocale(input); got != want {
+			t.Errorf("normalizeLocale(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestIsLocaleTag(t *testing.T) {
+	for _, locale := range []string{"en", "pt-BR", "zh-Hant-TW", "es-419", "ast"} {
+		if !isLocaleTag(locale) {
+			t.Errorf("isLocaleTag(%q) = false", locale)
+		}
+	}
+	for _, locale := range []string{"", "images", "e", "en-", "en--US", "../en"} {
+		if isLocaleTag(locale) {
+			t.Errorf("isLocaleTag(%q) = true", locale)
+		}
+	}
+}
+
+func TestLocaleChain(t *testing.T) {
+	tests := []struct {
+		requested     string
+		defaultLocale string
+		want          []string
+	}{
+		{requested: "pt-BR", defaultLocale: "en", want: []string{"pt-BR", "pt", "en"}},
+		{requested: "pt_br", defaultLocale: "en", want: []string{"pt-BR", "pt", "en"}},
+		{requested: "zh-Hant-TW", defaultLocale: "en", want: []string{"zh-Hant-TW", "zh-Hant", "zh", "en"}},
+		{requested: "en-GB", defaultLocale: "en", want: []string{"en-GB", "en"}},
+		{requested: "en", defaultLocale: "en", want: []string{"en"}},
+		{requested: "", defaultLocale: "en", want: []string{"en"}},
+		{requested: "fr", defaultLocale: "pt-BR", want: []string{"fr", "pt-BR"}},
+	}
+
+	for _, tt := range tests {
+		if got := localeChain(tt.requested, tt.defaultLocale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("localeChain(%q, %q) = %v, want %v", tt.requested, tt.defaultLocale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatForChain(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{requested: "en-GB", want: "en-GB"},
+		{requested: "en-US", want: "en"},
+		{requested: "pt-BR", want: "pt"},
+		{requested: "de-AT", want: "de"},
+		{requested: "ja", want: "en"},
+	}
+
+	for _, tt := range tests {
+		if got := formatForChain(localeChain(tt.requested, "en")).tag; got != tt.want {
+			t.Errorf("%s formats as %s, want %s", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleFormats(t *testing.T) {
+	date := time.Date(2024, time.March, 7, 14, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale   string
+		number   string
+		date     string
+		long     string
+		dateTime string
+	}{
+		{locale: "en", number: "1,234,567.89", date: "3/7/2024", long: "March 7, 2024", dateTime: "3/7/2024 2:05 PM"},
+		{locale: "en-GB", number: "1,234,567.89", date: "07/03/2024", long: "7 March 2024", dateTime: "07/03/2024 14:05"},
+		{locale: "pt", number: "1.234.567,89", date: "07/03/2024", long: "7 de março de 2024", dateTime: "07/03/2024 14:05"},
+		{locale: "es", number: "1.234.567,89", date: "07/03/2024", long: "7 de marzo de 2024", dateTime: "07/03/2024 14:05"},
+		{locale: "fr", number: "1 234 567,89", date: "07/03/2024", long: "7 mars 2024", dateTime: "07/03/2024 14:05"},
+		{locale: "de", number: "1.234.567,89", date: "07.03.2024", long: "7. März 2024", dateTime: "07.03.2024 14:05"},
+		{locale: "it", number: "1.234.567,89", date: "07/03/2024", long: "7 marzo 2024", dateTime: "07/03/2024 14:05"},
+		{locale: "nl", number: "1.234.567,89", date: "07-03-2024", long: "7 maart 2024", dateTime: "07-03-2024 14:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			format := localeFormats[tt.locale]
+
+			number, err := format.formatNumber(1234567.891, 2)
+			if err != nil {
+				t.Fatalf("formatNumber returned %v", err)
+			}
+			short, err := format.formatDate(date)
+			if err != nil {
+				t.Fatalf("formatDate returned %v", err)
+			}
+			long, err := format.formatDateLong(date)
+			if err != nil {
+				t.Fatalf("formatDateLong returned %v", err)
+			}
+			dateTime, err := format.formatDateTime(date)
+			if err != nil {
+				t.Fatalf("formatDateTime returned %v", err)
+			}
+
+			got := []string{number, short, long, dateTime}
+			want := []string{tt.number, tt.date, tt.long, tt.dateTime}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	format := localeFormats["pt"]
+
+	tests := []struct {
+		value    any
+		decimals []int
+		want     string
+	}{
+		{value: 0, want: "0"},
+		{value: 999, want: "999"},
+		{value: 1000, want: "1.000"},
+		{value: int64(-1234567), want: "-1.234.567"},
+		{value: 1234.5, decimals: []int{2}, want: "1.234,50"},
+		{value: 0.999, decimals: []int{2}, want: "1,00"},
+		{value: -0.001, decimals: []int{2}, want: "0,00"},
+		{value: float32(2.5), decimals: []int{1}, want: "2,5"},
+		{value: "98765.4", decimals: []int{1}, want: "98.765,4"},
+	}
+
+	for _, tt := range tests {
+		got, err := format.formatNumber(tt.value, tt.decimals...)
+		if err != nil {
+			t.Errorf("formatNumber(%v) returned %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("formatNumber(%v, %v) = %q, want %q", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+
+	for _, value := range []any{"lots", true, nil, []int{1}} {
+		if _, err := format.formatNumber(value); err == nil {
+			t.Errorf("formatNumber(%v) returned no error", value)
+		}
+	}
+}
+
+func TestToTime(t *testing.T) {
+	want := time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)
+
+	for _, value := range []any{want, "2024-03-07", "2024-03-07T00:00:00Z", float64(want.Unix()), want.Unix()} {
+		got, err := toTime(value)
+		if err != nil {
+			t.Errorf("toTime(%v) returned %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("toTime(%v) = %v, want %v", value, got, want)
+		}
+	}
+
+	for _, value := range []any{"07/03/2024", "yesterday", true} {
+		if _, err := toTime(value); err == nil {
+			t.Errorf("toTime(%v) returned no error", value)
+		}
+	}
+}
+
+func TestRenderFormatsForLocale(t *testing.T) {
+	service := newTestService(t, "testdata")
+
+	tests := []struct {
+		locale string
+		used   string
+		text   string
+	}{
+		// The untranslated notice is English, so English regions keep their
+		// own conventions
+		{locale: "en", used: "en", text: "Your plan renews on 12/31/2024."},
+		{locale: "en-GB", used: "en", text: "Your plan renews on 31/12/2024."},
+		// but another language gets the English text formatted as English
+		{locale: "de-DE", used: "en", text: "Your plan renews on 12/31/2024."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			rendered, err := service.Render(context.Background(), "notice", tt.locale, map[string]any{"Name": "Ana", "Renews": "2024-12-31"})
+			if err != nil {
+				t.Fatalf("Render returned %v", err)
+			}
+			if rendered.Locale != tt.used || rendered.Text != tt.text {
+				t.Errorf("rendered %q in %s, want %q in %s", rendered.Text, rendered.Locale, tt.text, tt.used)
+			}
+		})
+	}
+
+	// A translation in the requested language uses its regional conventions
+	rendered, err := service.Render(context.Background(), "welcome", "pt-BR", map[string]any{"Name": "Ana", "Balance": 1234.5, "Joined": "2024-03-07"})
+	if err != nil {
+		t.Fatalf("Render returned %v", err)
+	}
+	if want := "Olá Ana, seu saldo é 1.234,50."; rendered.Text[:len(want)] != want {
+		t.Errorf("pt-BR text %q, want it to start %q", rendered.Text, want)
+	}
+}
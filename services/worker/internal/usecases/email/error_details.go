@@ -0,0 +1,34 @@
+package email
+
+import (
+	stderrors "errors"
+	"strconv"
+
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+// errorDetails describes a failure for job history: its domain error code and,
+// for mail server rejections, the SMTP reply codes and failure class
+func errorDetails(err error) map[string]string {
+	details := make(map[string]string)
+
+	var domainErr *errors.DomainError
+	if !stderrors.As(err, &domainErr) {
+		return details
+	}
+
+	details["error_code"] = domainErr.Code
+	if domainErr.SMTPCode != 0 {
+		details["smtp_code"] = strconv.Itoa(domainErr.SMTPCode)
+	}
+	if domainErr.EnhancedCode != "" {
+		details["smtp_enhanced_code"] = domainErr.EnhancedCode
+	}
+	if domainErr.Permanent {
+		details["failure_class"] = "permanent"
+	} else if domainErr.SMTPCode != 0 {
+		details["failure_class"] = "transient"
+	}
+
+	return details
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+func TestErrorDetails(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want map[string]string
+	}{
+		{
+			name: "permanent reply",
+			err:  errors.NewSMTPReplyError("send failed", 550, "5.1.1", nil),
+			want: map[string]string{"error_code": errors.SMTPErrorCode, "smtp_code": "550", "smtp_enhanced_code": "5.1.1", "failure_class": "permanent"},
+		},
+		{
+			name: "transient reply",
+			err:  errors.NewSMTPReplyError("send failed", 451, "", nil),
+			want: map[string]string{"error_code": errors.SMTPErrorCode, "smtp_code": "451", "failure_class": "transient"},
+		},
+		{
+			name: "wrapped reply",
+			err:  fmt.Errorf("mx.example.com: %w", errors.NewSMTPReplyError("send failed", 552, "5.3.4", nil)),
+			want: map[string]string{"error_code": errors.SMTPErrorCode, "smtp_code": "552", "smtp_enhanced_code": "5.3.4", "failure_class": "permanent"},
+		},
+		{
+			name: "connection failure",
+			err:  errors.NewSMTPError("connection reset"),
+			want: map[string]string{"error_code": errors.SMTPErrorCode},
+		},
+		{
+			name: "other error",
+			err:  stderrors.New("boom"),
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorDetails(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errorDetails = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package email
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/infrastructure/cache"
+	"task-scheduler-worker/internal/infrastructure/email"
+	"task-scheduler-worker/internal/infrastructure/messaging"
+	"task-scheduler-worker/internal/infrastructure/templates"
+)
+
+// fakeCache keeps job records and idempotency keys in memory, following the
+// rules of the Redis implementation. Methods the tests do not use panic.
+type fakeCache struct {
+	cache.CacheService
+
+	mu        sync.Mutex
+	jobs      map[string]*models.EmailJob
+	statuses  map[string][]models.JobStatus
+	keys      map[string]string
+	scheduled map[string]*models.EmailJob
+	locks     map[string]string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{
+		jobs:      make(map[string]*models.EmailJob),
+		statuses:  make(map[string][]models.JobStatus),
+		keys:      make(map[string]string),
+		scheduled: make(map[string]*models.EmailJob),
+		locks:     make(map[string]string),
+	}
+}
+
+func (c *fakeCache) ApplyStatusChange(ctx context.Context, jobID string, change *models.StatusChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	job, ok := c.jobs[jobID]
+	if !ok {
+		job = &models.EmailJob{JobID: jobID, Status: models.JobStatusQueued}
+		c.jobs[jobID] = job
+	}
+	if !job.Status.CanTransitionTo(change.Status) {
+		return errors.NewInvalidTransitionError(jobID, string(job.Status), string(change.Status))
+	}
+	job.ApplyStatusChange(change)
+	c.statuses[jobID] = append(c.statuses[jobID], change.Status)
+	return nil
+}
+
+// Job returns the stored record of a job
+func (c *fakeCache) Job(jobID string) *models.EmailJob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jobs[jobID]
+}
+
+// Statuses returns the statuses recorded for a job, in order
+func (c *fakeCache) Statuses(jobID string) []models.JobStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]models.JobStatus(nil), c.statuses[jobID]...)
+}
+
+func (c *fakeCache) ClaimIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) (*cache.IdempotencyClaim, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.keys[key]
+	if !ok {
+		c.keys[key] = "pending:" + jobID
+		return &cache.IdempotencyClaim{State: cache.IdempotencyClaimed, JobID: jobID}, nil
+	}
+	state, holder, _ := strings.Cut(current, ":")
+	switch {
+	case holder == jobID:
+		return &cache.IdempotencyClaim{State: cache.IdempotencyClaimed, JobID: jobID}, nil
+	case state == "sent":
+		return &cache.IdempotencyClaim{State: cache.IdempotencyDelivered, JobID: holder}, nil
+	default:
+		return &cache.IdempotencyClaim{State: cache.IdempotencyInFlight, JobID: holder}, nil
+	}
+}
+
+func (c *fakeCache) CompleteIdempotencyKey(ctx context.Context, key, jobID string, window time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !strings.HasPrefix(c.keys[key], "sent:") {
+		c.keys[key] = "sent:" + jobID
+	}
+	return nil
+}
+
+func (c *fakeCache) ReleaseIdempotencyKey(ctx context.Context, key, jobID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys[key] == "pending:"+jobID {
+		delete(c.keys, key)
+	}
+	return nil
+}
+
+// Key returns the stored value of an idempotency key
+func (c *fakeCache) Key(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.keys[key]
+}
+
+func (c *fakeCache) ScheduleJob(ctx context.Context, job *models.EmailJob, retention time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parked := *job
+	c.scheduled[job.JobID] = &parked
+	return nil
+}
+
+func (c *fakeCache) DueJobs(ctx context.Context, now time.Time, limit int) ([]*models.EmailJob, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var due []*models.EmailJob
+	for _, job := range c.scheduled {
+		if !job.SendAt.After(now) {
+			parked := *job
+			due = append(due, &parked)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(*due[j].SendAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (c *fakeCache) UnscheduleJob(ctx context.Context, jobID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.scheduled, jobID)
+	return nil
+}
+
+// Scheduled returns the IDs of the parked jobs, sorted
+func (c *fakeCache) Scheduled() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]string, 0, len(c.scheduled))
+	for id := range c.scheduled {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// AcquireLock grants free locks and renews held ones; locks never expire
+func (c *fakeCache) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if holder, ok := c.locks[name]; ok && holder != owner {
+		return false, nil
+	}
+	c.locks[name] = owner
+	return true, nil
+}
+
+func (c *fakeCache) ReleaseLock(ctx context.Context, name, owner string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locks[name] == owner {
+		delete(c.locks, name)
+	}
+	return nil
+}
+
+// fakeEmailService hands every job to send and records the envelope
+// recipients of each attempt
+type fakeEmailService struct {
+	email.EmailService
+
+	send func(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error)
+
+	mu       sync.Mutex
+	attempts [][]string
+}
+
+func (s *fakeEmailService) SendEmail(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+	s.mu.Lock()
+	s.attempts = append(s.attempts, job.Recipients())
+	s.mu.Unlock()
+
+	if s.send == nil {
+		return &models.DeliveryResult{MessageID: "<1@example.com>", Accepted: job.Recipients()}, nil
+	}
+	return s.send(ctx, job)
+}
+
+// Attempts returns the recipients of every send so far
+func (s *fakeEmailService) Attempts() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.attempts...)
+}
+
+// fakeBroker records the jobs published to each queue
+type fakeBroker struct {
+	messaging.MessageBroker
+
+	// err fails every publish when set
+	err error
+
+	mu        sync.Mutex
+	published map[string][]*models.EmailJob
+	delays    []time.Duration
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{published: make(map[string][]*models.EmailJob)}
+}
+
+func (b *fakeBroker) PublishEmailJob(ctx context.Context, queue string, job *models.EmailJob) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published[queue] = append(b.published[queue], job)
+	return nil
+}
+
+func (b *fakeBroker) PublishDelayedEmailJob(ctx context.Context, job *models.EmailJob, delay time.Duration) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published["delayed"] = append(b.published["delayed"], job)
+	b.delays = append(b.delays, delay)
+	return nil
+}
+
+// Published returns the jobs published to a queue
+func (b *fakeBroker) Published(queue string) []*models.EmailJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*models.EmailJob(nil), b.published[queue]...)
+}
+
+// fakeTemplates renders every template as the translation in locale
+type fakeTemplates struct {
+	templates.TemplateService
+
+	locale string
+}
+
+func (f *fakeTemplates) Render(ctx context.Context, templateID, locale string, variables map[string]any) (*templates.RenderedTemplate, error) {
+	return &templates.RenderedTemplate{Subject: "Bem-vindo", Text: "Olá", Locale: f.locale}, nil
+}
+
+// testConfig returns a config without the demo delays
+func testConfig() *config.Config {
+	return &config.Config{
+		RetryStrategy:     config.RetryStrategyFixed,
+		RetryDelay:        time.Minute,
+		IdempotencyWindow: time.Hour,
+		JobTTL:            24 * time.Hour,
+
+		SchedulerLockTTL:   10 * time.Second,
+		SchedulerBatchSize: 100,
+
+		AttachmentMaxCount:      10,
+		AttachmentMaxInlineSize: 1 << 20,
+		AttachmentMaxTotalSize:  20 << 20,
+	}
+}
+
+// newTestProcessor returns a use case sending through emailService
+func newTestProcessor(cacheService cache.CacheService, emailService email.EmailService) *ProcessEmailUseCaseImpl {
+	return NewProcessEmailUseCase(cacheService, emailService, nil, testConfig(), noop.NewTracerProvider().Tracer(""))
+}
@@ -15,4 +15,11 @@ type EmailProcessorUseCase interface {
 type RetryHandlerUseCase interface {
 	HandleRetry(ctx context.Context, job *models.EmailJob, err error) error
 	ShouldRetry(job *models.EmailJob, err error) bool
+}
+
+// SchedulerUseCase defines the interface for parking jobs until their send time
+type SchedulerUseCase interface {
+	ScheduleJob(ctx context.Context, job *models.EmailJob) error
+	PromoteDueJobs(ctx context.Context) (int, error)
+	Resign(ctx context.Context) error
 }
\ No newline at end of file
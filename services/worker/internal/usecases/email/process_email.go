@@ -2,7 +2,9 @@ package email
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -14,28 +16,40 @@ import (
 	"task-scheduler-worker/internal/domain/models"
 	"task-scheduler-worker/internal/infrastructure/cache"
 	"task-scheduler-worker/internal/infrastructure/email"
+	"task-scheduler-worker/internal/infrastructure/templates"
 )
 
 // ProcessEmailUseCaseImpl implements EmailProcessorUseCase
 type ProcessEmailUseCaseImpl struct {
 	cacheService cache.CacheService
 	emailService email.EmailService
+	templates    templates.TemplateService
 	config       *config.Config
 	tracer       trace.Tracer
+
+	// attachmentLimits bounds the attachments of each job, from config
+	attachmentLimits models.AttachmentLimits
 }
 
 // NewProcessEmailUseCase creates a new email processing use case
 func NewProcessEmailUseCase(
 	cacheService cache.CacheService,
 	emailService email.EmailService,
+	templateService templates.TemplateService,
 	config *config.Config,
 	tracer trace.Tracer,
 ) *ProcessEmailUseCaseImpl {
 	return &ProcessEmailUseCaseImpl{
 		cacheService: cacheService,
 		emailService: emailService,
+		templates:    templateService,
 		config:       config,
 		tracer:       tracer,
+		attachmentLimits: models.AttachmentLimits{
+			MaxCount:      config.AttachmentMaxCount,
+			MaxInlineSize: int64(config.AttachmentMaxInlineSize),
+			MaxTotalSize:  int64(config.AttachmentMaxTotalSize),
+		},
 	}
 }
 
@@ -47,11 +61,14 @@ func (uc *ProcessEmailUseCaseImpl) ProcessEmailJob(ctx context.Context, job *mod
 
 	span.SetAttributes(
 		attribute.String("email.job_id", job.JobID),
-		attribute.String("email.to", job.To),
+		attribute.String("email.to", job.To.String()),
+		attribute.Int("email.recipient_count", len(job.Recipients())),
 		attribute.String("email.subject", job.Subject),
 		attribute.Int("email.retry_count", job.RetryCount),
 		attribute.Int("email.max_retries", job.MaxRetries),
 		attribute.Int("email.body_length", len(job.Body)),
+		attribute.Int("email.html_body_length", len(job.HTMLBody)),
+		attribute.Int("email.attachment_count", len(job.Attachments)),
 	)
 
 	log.Printf("Processing email job: %s (retry %d/%d)", job.JobID, job.RetryCount, job.MaxRetries)
@@ -65,8 +82,21 @@ func (uc *ProcessEmailUseCaseImpl) ProcessEmailJob(ctx context.Context, job *mod
 		return err
 	}
 
+	// Sending after the deadline is worse than not sending at all
+	if err := uc.expireIfPastDeadline(ctx, job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
 	// Update status to processing
-	if err := uc.updateJobStatus(ctx, job.JobID, models.JobStatusProcessing, ""); err != nil {
+	if err := uc.updateJobStatus(ctx, job, models.JobStatusProcessing, "", nil); err != nil {
+		// A redelivered copy of a job that was already delivered or given up on
+		if errors.IsInvalidTransitionError(err) {
+			log.Printf("Email job %s skipped: %v", job.JobID, err)
+			span.SetStatus(codes.Ok, "Email job already finished")
+			return nil
+		}
 		log.Printf("Error updating job status to processing: %v", err)
 		span.RecordError(err)
 		// Continue processing even if status update fails
@@ -75,36 +105,104 @@ func (uc *ProcessEmailUseCaseImpl) ProcessEmailJob(ctx context.Context, job *mod
 	// Add processing delay to make status transitions visible
 	if uc.config.ProcessingDelay > 0 {
 		log.Printf("Simulating email processing delay for job: %s", job.JobID)
-		time.Sleep(uc.config.ProcessingDelay)
+		if err := pause(ctx, uc.config.ProcessingDelay); err != nil {
+			err := errors.NewJobProcessingErrorWithCause("job processing interrupted", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	// Render templated jobs into the content that is actually sent
+	original := job
+	if job.TemplateID != "" {
+		rendered, err := uc.renderTemplateWithTracing(ctx, job)
+		if err != nil {
+			log.Printf("Error rendering template %s for job %s: %v", job.TemplateID, job.JobID, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		job = rendered
+	}
+
+	// Oversized attachments will not fit on any later attempt either
+	if err := job.CheckAttachmentLimits(uc.attachmentLimits); err != nil {
+		err := errors.NewValidationErrorWithCause("attachments exceed the job limits", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// The deadline may have passed while the job was being prepared
+	if err := uc.expireIfPastDeadline(ctx, job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// Hold the idempotency key while sending, so no job carrying it sends twice
+	if job.IdempotencyKey != "" {
+		duplicate, err := uc.claimIdempotencyKey(ctx, job)
+		if err != nil {
+			log.Printf("Error claiming idempotency key for job %s: %v", job.JobID, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		if duplicate {
+			span.SetAttributes(attribute.String("email.status", string(models.JobStatusDuplicate)))
+			span.SetStatus(codes.Ok, "Duplicate email job skipped")
+			return nil
+		}
 	}
 
 	// Send email with tracing
-	if err := uc.sendEmailWithTracing(ctx, job); err != nil {
+	result, err := uc.sendEmailWithTracing(ctx, job)
+	// Once any recipient has the message the key counts as delivered, even if
+	// others were deferred: the retry for them goes out on the job's own claim
+	uc.settleIdempotencyKey(ctx, job, err == nil || (result != nil && len(result.Accepted) > 0))
+	if err == nil {
+		err = deferRecipients(original, result)
+	}
+	recordDelivery(job, result)
+	if job != original {
+		// A retry republishes the original job, which must keep the ID too
+		recordDelivery(original, result)
+	}
+	if err != nil {
 		log.Printf("Error sending email for job %s: %v", job.JobID, err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		
-		// Update job status to failed (retry logic handled by caller)
-		if statusErr := uc.updateJobStatus(ctx, job.JobID, models.JobStatusFailed, err.Error()); statusErr != nil {
-			log.Printf("Error updating job status to failed: %v", statusErr)
-		}
-		
+
+		// The caller's retry handler records whether the job is retried or
+		// has failed for good, so a job reaches the failed status only once
 		return err
 	}
 
 	// Add completion delay
+	// The message is out, so an interrupted delay still records the delivery
 	if uc.config.CompletionDelay > 0 {
 		log.Printf("Email sent successfully, finalizing job: %s", job.JobID)
-		time.Sleep(uc.config.CompletionDelay)
+		pause(ctx, uc.config.CompletionDelay)
+	}
+
+	// Some recipients were refused: the message went out, so the job is done
+	// but must not be retried, or the accepted recipients would get it twice
+	status, errorMsg := models.JobStatusCompleted, ""
+	if result.IsPartial() {
+		status = models.JobStatusPartiallyDelivered
+		errorMsg = fmt.Sprintf("%d of %d recipients rejected", len(result.Rejected), len(result.Accepted)+len(result.Rejected))
+		log.Printf("Email job %s partially delivered: %s", job.JobID, errorMsg)
 	}
 
 	// Update status to completed
-	if err := uc.updateJobStatus(ctx, job.JobID, models.JobStatusCompleted, ""); err != nil {
-		log.Printf("Error updating job status to completed: %v", err)
+	if err := uc.updateJobStatus(ctx, job, status, errorMsg, result.Details()); err != nil {
+		log.Printf("Error updating job status to %s: %v", status, err)
 		span.RecordError(err)
 		// Don't fail the job if status update fails after successful send
 	} else {
-		span.SetAttributes(attribute.String("email.status", "completed"))
+		span.SetAttributes(attribute.String("email.status", string(status)))
 		span.SetStatus(codes.Ok, "Email job completed successfully")
 	}
 
@@ -112,31 +210,207 @@ func (uc *ProcessEmailUseCaseImpl) ProcessEmailJob(ctx context.Context, job *mod
 	return nil
 }
 
+// pause waits for d, or returns ctx's error if ctx is cancelled first
+func pause(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// renderTemplateWithTracing renders the job's template and returns a copy of the
+// job carrying the rendered content. The original keeps its template so a retry
+// renders again rather than resending stale output; only the locale metadata is
+// recorded on it, so a retry or failure saves which translation was picked.
+func (uc *ProcessEmailUseCaseImpl) renderTemplateWithTracing(ctx context.Context, job *models.EmailJob) (*models.EmailJob, error) {
+	ctx, span := uc.tracer.Start(ctx, "render_template")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("template.id", job.TemplateID),
+		attribute.String("template.locale_requested", job.Locale),
+		attribute.Int("template.variable_count", len(job.Variables)),
+	)
+
+	rendered, err := uc.templates.Render(ctx, job.TemplateID, job.Locale, job.Variables)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Record which translation was sent, since it can differ from the one asked for
+	if job.Metadata == nil {
+		job.Metadata = make(map[string]string, 2)
+	}
+	job.Metadata[models.MetadataLocaleRequested] = job.Locale
+	job.Metadata[models.MetadataLocaleUsed] = rendered.Locale
+
+	renderedJob := *job
+	renderedJob.Subject = rendered.Subject
+	renderedJob.Body = rendered.Text
+	renderedJob.HTMLBody = rendered.HTML
+	renderedJob.Metadata = make(map[string]string, len(job.Metadata))
+	for key, value := range job.Metadata {
+		renderedJob.Metadata[key] = value
+	}
+
+	span.SetAttributes(attribute.String("template.locale_used", rendered.Locale))
+
+	span.SetStatus(codes.Ok, "Template rendered successfully")
+	return &renderedJob, nil
+}
+
 // sendEmailWithTracing sends email with distributed tracing
-func (uc *ProcessEmailUseCaseImpl) sendEmailWithTracing(ctx context.Context, job *models.EmailJob) error {
+func (uc *ProcessEmailUseCaseImpl) sendEmailWithTracing(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
 	ctx, span := uc.tracer.Start(ctx, "smtp_send_email")
 	defer span.End()
 
 	span.SetAttributes(
 		attribute.String("smtp.operation", "send"),
-		attribute.String("email.to", job.To),
+		attribute.String("email.to", job.To.String()),
 		attribute.String("email.subject", job.Subject),
 		attribute.String("smtp.protocol", "smtp"),
 	)
 
-	err := uc.emailService.SendEmail(ctx, job)
+	result, err := uc.emailService.SendEmail(ctx, job)
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int("smtp.recipients_accepted", len(result.Accepted)),
+			attribute.Int("smtp.recipients_rejected", len(result.Rejected)),
+		)
+	}
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return err
+		return result, err
 	}
 
 	span.SetStatus(codes.Ok, "Email sent successfully")
-	return nil
+	return result, nil
+}
+
+// claimIdempotencyKey claims the job's idempotency key. It returns true after
+// recording the job as a duplicate when a job with the key was already
+// delivered, and a retryable error while another job is sending with it.
+func (uc *ProcessEmailUseCaseImpl) claimIdempotencyKey(ctx context.Context, job *models.EmailJob) (bool, error) {
+	claim, err := uc.cacheService.ClaimIdempotencyKey(ctx, job.IdempotencyKey, job.JobID, uc.config.IdempotencyWindow)
+	if err != nil {
+		return false, err
+	}
+
+	switch claim.State {
+	case cache.IdempotencyClaimed:
+		return false, nil
+	case cache.IdempotencyDelivered:
+		log.Printf("Email job %s skipped: idempotency key already delivered by job %s", job.JobID, claim.JobID)
+
+		message := "Already delivered by job " + claim.JobID
+		job.UpdateStatus(models.JobStatusDuplicate, message, "")
+
+		statusChange := &models.StatusChange{
+			Status:  models.JobStatusDuplicate,
+			Message: message,
+			Details: map[string]string{
+				"idempotency_key": job.IdempotencyKey,
+				"original_job_id": claim.JobID,
+			},
+		}
+		if err := uc.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
+			log.Printf("Error updating job status to duplicate: %v", err)
+		}
+		return true, nil
+	default:
+		return false, errors.NewJobProcessingError(fmt.Sprintf("job %s is sending with the same idempotency key", claim.JobID))
+	}
+}
+
+// settleIdempotencyKey marks the job's idempotency key delivered once the
+// message reached a recipient, or releases it so a retry can claim it again
+func (uc *ProcessEmailUseCaseImpl) settleIdempotencyKey(ctx context.Context, job *models.EmailJob, delivered bool) {
+	if job.IdempotencyKey == "" {
+		return
+	}
+
+	if delivered {
+		if err := uc.cacheService.CompleteIdempotencyKey(ctx, job.IdempotencyKey, job.JobID, uc.config.IdempotencyWindow); err != nil {
+			log.Printf("Error recording idempotency key for job %s: %v", job.JobID, err)
+		}
+		return
+	}
+
+	if err := uc.cacheService.ReleaseIdempotencyKey(ctx, job.IdempotencyKey, job.JobID); err != nil {
+		log.Printf("Error releasing idempotency key for job %s: %v", job.JobID, err)
+	}
+}
+
+// deferRecipients narrows the job to the recipients the server turned away
+// with a transient reply, such as greylisting, and returns a retryable error
+// so the retry goes out to them alone. Recipients that accepted the message
+// or refused it for good are not sent it again.
+func deferRecipients(job *models.EmailJob, result *models.DeliveryResult) error {
+	deferred := result.Deferred()
+	if len(deferred) == 0 {
+		return nil
+	}
+
+	job.PendingRecipients = deferred
+	return errors.NewSMTPError(fmt.Sprintf("%d of %d recipients deferred: %s",
+		len(deferred), len(result.Accepted)+len(result.Rejected), strings.Join(deferred, ", ")))
 }
 
-// updateJobStatus updates job status with tracing
-func (uc *ProcessEmailUseCaseImpl) updateJobStatus(ctx context.Context, jobID string, status models.JobStatus, errorMsg string) error {
+// recordDelivery stores the Message-ID the message was sent with, the backend
+// that handled it and the recipients the server refused in the job's metadata,
+// so they are saved with the next status update and the Message-ID is reused
+// on retry
+func recordDelivery(job *models.EmailJob, result *models.DeliveryResult) {
+	delete(job.Metadata, models.MetadataRejectedRecipients)
+	if result == nil {
+		return
+	}
+	if job.Metadata == nil {
+		job.Metadata = make(map[string]string)
+	}
+	if rejected := result.Details()["rejected_recipients"]; rejected != "" {
+		job.Metadata[models.MetadataRejectedRecipients] = rejected
+	}
+	if result.MessageID != "" {
+		job.Metadata[models.MetadataMessageID] = result.MessageID
+	}
+	if result.Backend != "" {
+		job.Metadata[models.MetadataDeliveryBackend] = result.Backend
+	}
+}
+
+// expireIfPastDeadline moves a job whose deadline has been reached to the
+// expired status and returns a JobExpiredError, which is never retried
+func (uc *ProcessEmailUseCaseImpl) expireIfPastDeadline(ctx context.Context, job *models.EmailJob) error {
+	if !job.IsExpired(time.Now()) {
+		return nil
+	}
+
+	err := errors.NewJobExpiredError(job.JobID, *job.ExpiresAt)
+	log.Printf("Email job %s expired before it was sent", job.JobID)
+
+	details := errorDetails(err)
+	details["expires_at"] = job.ExpiresAt.UTC().Format(time.RFC3339)
+	if statusErr := uc.updateJobStatus(ctx, job, models.JobStatusExpired, err.Error(), details); statusErr != nil {
+		log.Printf("Error updating job status to expired: %v", statusErr)
+	}
+
+	return err
+}
+
+// updateJobStatus updates job status with tracing, recording details on the
+// history entry and saving the job's metadata alongside
+func (uc *ProcessEmailUseCaseImpl) updateJobStatus(ctx context.Context, job *models.EmailJob, status models.JobStatus, errorMsg string, details map[string]string) error {
+	jobID := job.JobID
+
 	ctx, span := uc.tracer.Start(ctx, "update_job_status")
 	defer span.End()
 
@@ -147,7 +421,12 @@ func (uc *ProcessEmailUseCaseImpl) updateJobStatus(ctx context.Context, jobID st
 		attribute.String("job.status", string(status)),
 	)
 
-	err := uc.cacheService.UpdateJobStatus(ctx, jobID, status, errorMsg, 0)
+	err := uc.cacheService.ApplyStatusChange(ctx, jobID, &models.StatusChange{
+		Status:   status,
+		Error:    errorMsg,
+		Details:  details,
+		Metadata: job.Metadata,
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
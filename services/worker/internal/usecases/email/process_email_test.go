@@ -0,0 +1,221 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+)
+
+func TestDeferRecipients(t *testing.T) {
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	job.Cc = models.AddressList{"John <john@b.test>", "nobody@c.test"}
+
+	result := &models.DeliveryResult{
+		Accepted: []string{"jane@a.test"},
+		Rejected: []models.RecipientRejection{
+			{Address: "john@b.test", Code: 450, EnhancedCode: "4.7.1"},
+			{Address: "nobody@c.test", Code: 550, EnhancedCode: "5.1.1", Permanent: true},
+		},
+	}
+
+	err := deferRecipients(job, result)
+	if !errors.IsRetryableError(err) {
+		t.Fatalf("deferRecipients returned %v, want a retryable error", err)
+	}
+	if got := strings.Join(job.Recipients(), ","); got != "john@b.test" {
+		t.Errorf("job now goes to %q, want john@b.test", got)
+	}
+	if err := job.Validate(); err != nil {
+		t.Errorf("narrowed job does not validate: %v", err)
+	}
+
+	// Permanent rejections alone leave the job partially delivered
+	job = models.NewEmailJob("job-2", "jane@a.test", "Hello", "Body text", 3)
+	result.Rejected = result.Rejected[1:]
+	if err := deferRecipients(job, result); err != nil {
+		t.Errorf("deferRecipients returned %v, want nil", err)
+	}
+	if job.PendingRecipients != nil {
+		t.Errorf("job narrowed to %v", job.PendingRecipients)
+	}
+}
+
+func TestPartialSendKeepsIdempotencyKey(t *testing.T) {
+	cacheService := newFakeCache()
+	emailService := &fakeEmailService{}
+	emailService.send = func(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+		if len(emailService.Attempts()) > 1 {
+			return &models.DeliveryResult{Accepted: job.Recipients()}, nil
+		}
+		return &models.DeliveryResult{
+			Accepted: []string{"jane@a.test"},
+			Rejected: []models.RecipientRejection{{Address: "john@b.test", Code: 450, EnhancedCode: "4.7.1"}},
+		}, nil
+	}
+	uc := newTestProcessor(cacheService, emailService)
+
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	job.Cc = models.AddressList{"john@b.test"}
+	job.IdempotencyKey = "order-42"
+
+	if err := uc.ProcessEmailJob(context.Background(), job); !errors.IsRetryableError(err) {
+		t.Fatalf("ProcessEmailJob returned %v, want a retryable error", err)
+	}
+	if got := cacheService.Key("order-42"); got != "sent:job-1" {
+		t.Fatalf("idempotency key holds %q after jane@a.test got the message, want sent:job-1", got)
+	}
+
+	// Another job with the key is a duplicate, not a second send to jane
+	duplicate := models.NewEmailJob("job-2", "jane@a.test", "Hello", "Body text", 3)
+	duplicate.IdempotencyKey = "order-42"
+	if err := uc.ProcessEmailJob(context.Background(), duplicate); err != nil {
+		t.Fatalf("ProcessEmailJob returned %v for the duplicate", err)
+	}
+	if got := cacheService.Job("job-2").Status; got != models.JobStatusDuplicate {
+		t.Errorf("second job ended %s, want duplicate", got)
+	}
+
+	// The retry for the deferred recipient goes out on the job's own claim
+	if err := cacheService.ApplyStatusChange(context.Background(), "job-1", &models.StatusChange{Status: models.JobStatusRetrying, RetryCount: 1}); err != nil {
+		t.Fatalf("ApplyStatusChange returned %v", err)
+	}
+	job.Status, job.RetryCount = models.JobStatusRetrying, 1
+	if err := uc.ProcessEmailJob(context.Background(), job); err != nil {
+		t.Fatalf("retry returned %v", err)
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusCompleted {
+		t.Errorf("job ended %s after the retry, want completed", got)
+	}
+
+	attempts := emailService.Attempts()
+	if len(attempts) != 2 {
+		t.Fatalf("%d sends, want the first and the retry", len(attempts))
+	}
+	if got := strings.Join(attempts[1], ","); got != "john@b.test" {
+		t.Errorf("retry went to %q, want john@b.test only", got)
+	}
+	if got := cacheService.Key("order-42"); got != "sent:job-1" {
+		t.Errorf("idempotency key holds %q after the retry, want sent:job-1", got)
+	}
+}
+
+func TestFailedSendReleasesIdempotencyKey(t *testing.T) {
+	cacheService := newFakeCache()
+	emailService := &fakeEmailService{send: func(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+		return nil, errors.NewSMTPError("connection refused")
+	}}
+	uc := newTestProcessor(cacheService, emailService)
+
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	job.IdempotencyKey = "order-42"
+	if err := uc.ProcessEmailJob(context.Background(), job); err == nil {
+		t.Fatal("ProcessEmailJob returned nil for a failed send")
+	}
+	if got := cacheService.Key("order-42"); got != "" {
+		t.Errorf("idempotency key holds %q after nothing was sent, want it released", got)
+	}
+}
+
+func TestProcessEmailJobEnforcesAttachmentLimits(t *testing.T) {
+	cacheService, emailService := newFakeCache(), &fakeEmailService{}
+	uc := newTestProcessor(cacheService, emailService)
+
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		job.Attachments = append(job.Attachments, models.Attachment{Filename: name, Content: "eA=="})
+	}
+
+	uc.attachmentLimits.MaxCount = 1
+	err := uc.ProcessEmailJob(context.Background(), job)
+	if !errors.IsValidationError(err) {
+		t.Fatalf("ProcessEmailJob returned %v, want a validation error", err)
+	}
+	if attempts := emailService.Attempts(); len(attempts) != 0 {
+		t.Errorf("job over the attachment limit was sent %d times", len(attempts))
+	}
+
+	uc.attachmentLimits.MaxCount = 2
+	if err := uc.ProcessEmailJob(context.Background(), job); err != nil {
+		t.Errorf("ProcessEmailJob returned %v within the limits", err)
+	}
+}
+
+func TestProcessingDelayStopsWithContext(t *testing.T) {
+	cacheService, emailService := newFakeCache(), &fakeEmailService{}
+	uc := newTestProcessor(cacheService, emailService)
+	uc.config.ProcessingDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	if err := uc.ProcessEmailJob(ctx, job); err == nil {
+		t.Fatal("ProcessEmailJob returned nil after ctx was cancelled during the processing delay")
+	}
+	if attempts := emailService.Attempts(); len(attempts) != 0 {
+		t.Errorf("job was sent %d times after ctx was cancelled", len(attempts))
+	}
+}
+
+func TestCompletionDelayStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cacheService := newFakeCache()
+	emailService := &fakeEmailService{send: func(_ context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+		cancel()
+		return &models.DeliveryResult{Accepted: job.Recipients()}, nil
+	}}
+	uc := newTestProcessor(cacheService, emailService)
+	uc.config.CompletionDelay = time.Hour
+
+	// The message went out, so the job still completes
+	job := models.NewEmailJob("job-1", "jane@a.test", "Hello", "Body text", 3)
+	if err := uc.ProcessEmailJob(ctx, job); err != nil {
+		t.Fatalf("ProcessEmailJob returned %v", err)
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusCompleted {
+		t.Errorf("job ended %s, want completed", got)
+	}
+}
+
+func TestTemplatedJobRecordsLocale(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	emailService := &fakeEmailService{send: func(ctx context.Context, job *models.EmailJob) (*models.DeliveryResult, error) {
+		if job.Subject != "Bem-vindo" || job.Metadata[models.MetadataLocaleUsed] != "pt" {
+			t.Errorf("sent subject %q with metadata %v, want the rendered job", job.Subject, job.Metadata)
+		}
+		return nil, errors.NewSMTPError("connection reset")
+	}}
+	uc := NewProcessEmailUseCase(cacheService, emailService, &fakeTemplates{locale: "pt"}, testConfig(), noop.NewTracerProvider().Tracer(""))
+
+	job := models.NewEmailJob("job-1", "jane@a.test", "", "", 3)
+	job.TemplateID = "welcome"
+	job.Locale = "pt-BR"
+
+	err := uc.ProcessEmailJob(context.Background(), job)
+	if !errors.IsRetryableError(err) {
+		t.Fatalf("ProcessEmailJob returned %v, want a retryable error", err)
+	}
+
+	// The job that is retried keeps its template but records the translation
+	if job.Subject != "" || job.Body != "" {
+		t.Errorf("original job was given rendered content %q / %q", job.Subject, job.Body)
+	}
+	if job.Metadata[models.MetadataLocaleRequested] != "pt-BR" || job.Metadata[models.MetadataLocaleUsed] != "pt" {
+		t.Errorf("original job metadata %v, want the requested and used locales", job.Metadata)
+	}
+
+	if err := newTestRetryHandler(cacheService, broker).HandleRetry(context.Background(), job, err); err != nil {
+		t.Fatalf("HandleRetry returned %v", err)
+	}
+	if got := cacheService.Job("job-1").Metadata[models.MetadataLocaleUsed]; got != "pt" {
+		t.Errorf("retrying job saved locale_used %q, want pt", got)
+	}
+}
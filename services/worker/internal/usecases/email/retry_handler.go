@@ -23,6 +23,7 @@ type RetryHandlerUseCaseImpl struct {
 	messagingService messaging.MessageBroker
 	config           *config.Config
 	tracer           trace.Tracer
+	retryPolicies    *RetryPolicySet
 }
 
 // NewRetryHandlerUseCase creates a new retry handler use case
@@ -37,10 +38,13 @@ func NewRetryHandlerUseCase(
 		messagingService: messagingService,
 		config:           config,
 		tracer:           tracer,
+		retryPolicies:    NewRetryPolicySet(config),
 	}
 }
 
-// HandleRetry handles job retry logic
+// HandleRetry handles job retry logic. It returns once the job has been parked
+// in the broker's delayed retry queue, so the caller can safely acknowledge the
+// original delivery.
 func (rh *RetryHandlerUseCaseImpl) HandleRetry(ctx context.Context, job *models.EmailJob, err error) error {
 	ctx, span := rh.tracer.Start(ctx, "handle_job_retry")
 	defer span.End()
@@ -52,52 +56,67 @@ func (rh *RetryHandlerUseCaseImpl) HandleRetry(ctx context.Context, job *models.
 		attribute.String("error.message", err.Error()),
 	)
 
+	// An expired job was already recorded as such when it was turned away
+	if errors.IsJobExpiredError(err) {
+		span.SetAttributes(attribute.String("email.status", string(models.JobStatusExpired)))
+		return nil
+	}
+	if job.IsExpired(time.Now()) {
+		return rh.handleExpired(ctx, job, err, "Deadline passed before the job could be retried")
+	}
+
 	// Check if we should retry
 	if !rh.ShouldRetry(job, err) {
 		return rh.handleMaxRetriesExceeded(ctx, job, err)
 	}
 
+	// Pick the schedule for this kind of failure. The broker only holds retries
+	// for a fixed set of delays, so the policy's delay becomes the nearest one.
+	policy := rh.retryPolicies.PolicyFor(err)
+	retryDelay := messaging.RetryTier(policy.NextDelay(job.RetryCount + 1))
+
+	// Never schedule an attempt the deadline would turn away
+	if job.ExpiresAt != nil && !time.Now().Add(retryDelay).Before(*job.ExpiresAt) {
+		return rh.handleExpired(ctx, job, err, "Deadline passes before the next retry")
+	}
+
 	// Increment retry count
 	job.IncrementRetry(err.Error())
 
-	// Update job status in cache
-	if statusErr := rh.cacheService.UpdateJobStatus(ctx, job.JobID, models.JobStatusRetrying, err.Error(), job.RetryCount); statusErr != nil {
+	// Update job status in cache, recording the schedule used for this attempt
+	statusChange := &models.StatusChange{
+		Status:     models.JobStatusRetrying,
+		Message:    "Job requeued for retry",
+		Error:      err.Error(),
+		RetryCount: job.RetryCount,
+		Metadata:   job.Metadata,
+		Details:    errorDetails(err),
+	}
+	statusChange.Details["retry_policy"] = policy.Name()
+	statusChange.Details["retry_delay"] = retryDelay.String()
+	if statusErr := rh.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); statusErr != nil {
 		log.Printf("Error updating job status for retry: %v", statusErr)
 		span.RecordError(statusErr)
 	}
 
-	// Fixed retry delay of 1 minute
-	retryDelaySeconds := 1 * time.Minute
-
-	log.Printf("Job %s will retry in %v (attempt %d/%d)", job.JobID, retryDelaySeconds, job.RetryCount, job.MaxRetries)
+	log.Printf("Job %s will retry in %v using %s policy (attempt %d/%d)", job.JobID, retryDelay, policy.Name(), job.RetryCount, job.MaxRetries)
 
-	// Add retry delay before republishing
-	go func() {
-		time.Sleep(retryDelaySeconds)
-
-		// Publish back to main email queue
-		queueNames := &messaging.QueueNames{
-			EmailTasks:  "email_tasks",
-			EmailRetry:  "email_tasks_retry",
-			EmailFailed: "email_tasks_failed",
-		}
+	// Park the job in the broker's delayed retry queue. The original delivery is
+	// only acked once the broker confirms, so the retry cannot be lost in between.
+	if retryErr := rh.messagingService.PublishDelayedEmailJob(ctx, job, retryDelay); retryErr != nil {
+		log.Printf("Failed to requeue job %s for retry: %v", job.JobID, retryErr)
+		span.RecordError(retryErr)
+		span.SetStatus(codes.Error, "Failed to requeue job")
+		return retryErr
+	}
 
-		retryErr := rh.messagingService.PublishEmailJob(context.Background(), queueNames.EmailTasks, job)
-		if retryErr != nil {
-			log.Printf("Failed to requeue job %s for retry: %v", job.JobID, retryErr)
-			// Mark job as failed if we can't requeue it
-			if statusErr := rh.cacheService.UpdateJobStatus(context.Background(), job.JobID, models.JobStatusFailed, fmt.Sprintf("Failed to requeue: %v", retryErr), job.RetryCount); statusErr != nil {
-				log.Printf("Error updating job status to failed after requeue failure: %v", statusErr)
-			}
-		} else {
-			log.Printf("Job %s requeued for retry %d/%d", job.JobID, job.RetryCount, job.MaxRetries)
-		}
-	}()
+	log.Printf("Job %s requeued for retry %d/%d", job.JobID, job.RetryCount, job.MaxRetries)
 
 	span.SetAttributes(
 		attribute.String("email.status", "requeued"),
-		attribute.String("queue.name", "email_tasks"),
-		attribute.String("retry.delay", retryDelaySeconds.String()),
+		attribute.String("queue.name", messaging.DefaultQueueNames().RetryQueue(job.Lane(), retryDelay)),
+		attribute.String("retry.delay", retryDelay.String()),
+		attribute.String("retry.policy", policy.Name()),
 	)
 	span.SetStatus(codes.Ok, "Job scheduled for retry")
 
@@ -126,6 +145,11 @@ func (rh *RetryHandlerUseCaseImpl) ShouldRetry(job *models.EmailJob, err error)
 		return false
 	}
 
+	// Don't retry failures the mail server reported as permanent
+	if errors.IsPermanentError(err) {
+		return false
+	}
+
 	// Retry infrastructure errors (Redis, RabbitMQ, SMTP)
 	if errors.IsRetryableError(err) {
 		return true
@@ -135,6 +159,43 @@ func (rh *RetryHandlerUseCaseImpl) ShouldRetry(job *models.EmailJob, err error)
 	return true
 }
 
+// handleExpired ends a failed job whose deadline leaves no room for another
+// attempt. Unlike a job out of retries it is not sent to the failed queue:
+// the message is no longer wanted.
+func (rh *RetryHandlerUseCaseImpl) handleExpired(ctx context.Context, job *models.EmailJob, originalErr error, message string) error {
+	ctx, span := rh.tracer.Start(ctx, "handle_job_expired")
+	defer span.End()
+
+	expiresAt := job.ExpiresAt.UTC().Format(time.RFC3339)
+	span.SetAttributes(
+		attribute.String("email.job_id", job.JobID),
+		attribute.String("email.expires_at", expiresAt),
+	)
+
+	log.Printf("Job %s will not be retried: %s (deadline %s)", job.JobID, message, expiresAt)
+
+	finalError := fmt.Sprintf("Expired at %s: %s", expiresAt, originalErr.Error())
+	job.UpdateStatus(models.JobStatusExpired, message, finalError)
+
+	statusChange := &models.StatusChange{
+		Status:     models.JobStatusExpired,
+		Message:    message,
+		Error:      finalError,
+		RetryCount: job.RetryCount,
+		Metadata:   job.Metadata,
+		Details:    errorDetails(originalErr),
+	}
+	statusChange.Details["expires_at"] = expiresAt
+	if err := rh.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
+		log.Printf("Error updating job status to expired: %v", err)
+		span.RecordError(err)
+	}
+
+	span.SetAttributes(attribute.String("email.status", string(models.JobStatusExpired)))
+	span.SetStatus(codes.Ok, "Job expired")
+	return nil
+}
+
 // handleMaxRetriesExceeded handles jobs that have exceeded max retries
 func (rh *RetryHandlerUseCaseImpl) handleMaxRetriesExceeded(ctx context.Context, job *models.EmailJob, originalErr error) error {
 	ctx, span := rh.tracer.Start(ctx, "handle_max_retries_exceeded")
@@ -146,26 +207,41 @@ func (rh *RetryHandlerUseCaseImpl) handleMaxRetriesExceeded(ctx context.Context,
 		attribute.Int("email.max_retries", job.MaxRetries),
 	)
 
-	log.Printf("Job %s exceeded max retries (%d), sending to failed queue", job.JobID, job.MaxRetries)
-
 	// Create final error message
+	statusMessage := "Max retries exceeded"
 	finalError := fmt.Sprintf("Failed after %d retries: %s", job.RetryCount, originalErr.Error())
+	switch {
+	case errors.IsPermanentError(originalErr):
+		statusMessage = "Permanent delivery failure"
+		finalError = fmt.Sprintf("Permanent failure: %s", originalErr.Error())
+		log.Printf("Job %s failed permanently, sending to failed queue", job.JobID)
+	case errors.IsValidationError(originalErr):
+		statusMessage = "Invalid job"
+		finalError = fmt.Sprintf("Validation failed: %s", originalErr.Error())
+		log.Printf("Job %s is invalid, sending to failed queue", job.JobID)
+	default:
+		log.Printf("Job %s exceeded max retries (%d), sending to failed queue", job.JobID, job.MaxRetries)
+	}
 
 	// Update job status to failed
-	job.UpdateStatus(models.JobStatusFailed, "Max retries exceeded", finalError)
+	job.UpdateStatus(models.JobStatusFailed, statusMessage, finalError)
 
 	// Update in cache
-	if err := rh.cacheService.UpdateJobStatus(ctx, job.JobID, models.JobStatusFailed, finalError, job.RetryCount); err != nil {
+	statusChange := &models.StatusChange{
+		Status:     models.JobStatusFailed,
+		Message:    statusMessage,
+		Error:      finalError,
+		RetryCount: job.RetryCount,
+		Metadata:   job.Metadata,
+		Details:    errorDetails(originalErr),
+	}
+	if err := rh.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
 		log.Printf("Error updating job status to failed: %v", err)
 		span.RecordError(err)
 	}
 
 	// Send to failed queue
-	queueNames := &messaging.QueueNames{
-		EmailTasks:  "email_tasks",
-		EmailRetry:  "email_tasks_retry",
-		EmailFailed: "email_tasks_failed",
-	}
+	queueNames := messaging.DefaultQueueNames()
 
 	if err := rh.messagingService.PublishEmailJob(ctx, queueNames.EmailFailed, job); err != nil {
 		span.RecordError(err)
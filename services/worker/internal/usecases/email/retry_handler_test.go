@@ -0,0 +1,38 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/infrastructure/messaging"
+)
+
+// newTestRetryHandler returns a retry handler publishing to broker
+func newTestRetryHandler(cacheService *fakeCache, broker *fakeBroker) *RetryHandlerUseCaseImpl {
+	return NewRetryHandlerUseCase(cacheService, broker, testConfig(), noop.NewTracerProvider().Tracer(""))
+}
+
+func TestHandleRetrySendsInvalidJobToFailedQueue(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	rh := newTestRetryHandler(cacheService, broker)
+
+	job := models.NewEmailJob("job-1", "not an address", "Hello", "Body text", 3)
+	err := rh.HandleRetry(context.Background(), job, errors.NewValidationErrorWithCause("invalid job", job.Validate()))
+	if errors.ErrorCode(err) != errors.RetryExceededErrorCode {
+		t.Fatalf("HandleRetry returned %v, want the retry exceeded error", err)
+	}
+
+	if failed := broker.Published(messaging.DefaultQueueNames().EmailFailed); len(failed) != 1 || failed[0].JobID != "job-1" {
+		t.Errorf("failed queue received %v, want job-1", failed)
+	}
+	if delayed := broker.Published("delayed"); len(delayed) != 0 {
+		t.Errorf("invalid job was retried %d times", len(delayed))
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusFailed {
+		t.Errorf("job ended %s, want failed", got)
+	}
+}
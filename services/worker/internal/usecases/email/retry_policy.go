@@ -0,0 +1,127 @@
+package email
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+// RetryPolicy decides how long a job waits before its next attempt
+type RetryPolicy interface {
+	// NextDelay returns the delay before the given retry attempt, starting at 1
+	NextDelay(attempt int) time.Duration
+	// Name identifies the policy in job history and traces
+	Name() string
+}
+
+// FixedRetryPolicy waits the same delay before every attempt
+type FixedRetryPolicy struct {
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// NextDelay returns the fixed delay, capped at MaxDelay
+func (p *FixedRetryPolicy) NextDelay(attempt int) time.Duration {
+	return capDelay(p.Delay, p.MaxDelay)
+}
+
+// Name returns the policy name
+func (p *FixedRetryPolicy) Name() string {
+	return config.RetryStrategyFixed
+}
+
+// LinearRetryPolicy grows the delay by the base delay on every attempt
+type LinearRetryPolicy struct {
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// NextDelay returns attempt times the base delay, capped at MaxDelay
+func (p *LinearRetryPolicy) NextDelay(attempt int) time.Duration {
+	return capDelay(p.Delay*time.Duration(max(attempt, 1)), p.MaxDelay)
+}
+
+// Name returns the policy name
+func (p *LinearRetryPolicy) Name() string {
+	return config.RetryStrategyLinear
+}
+
+// ExponentialRetryPolicy doubles the delay on every attempt and randomizes it
+// downwards by up to Jitter so retries from a burst of failures spread out.
+// The broker only holds retries for a few fixed delays (see
+// messaging.RetryDelayTiers), so the jittered delay is quantized to the nearest
+// of them: jitter spreads a burst over neighbouring tiers rather than over
+// every second in between.
+type ExponentialRetryPolicy struct {
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Jitter   float64
+}
+
+// NextDelay returns the jittered exponential delay, capped at MaxDelay
+func (p *ExponentialRetryPolicy) NextDelay(attempt int) time.Duration {
+	exp := math.Pow(2, float64(max(attempt, 1)-1))
+	delay := capDelay(time.Duration(float64(p.Delay)*exp), p.MaxDelay)
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * p.Jitter * rand.Float64())
+	}
+
+	return delay
+}
+
+// Name returns the policy name
+func (p *ExponentialRetryPolicy) Name() string {
+	return config.RetryStrategyExponential
+}
+
+// capDelay limits delay to maxDelay, also guarding against overflow
+func capDelay(delay, maxDelay time.Duration) time.Duration {
+	if maxDelay > 0 && (delay > maxDelay || delay < 0) {
+		return maxDelay
+	}
+	return delay
+}
+
+// RetryPolicySet picks a retry policy by the DomainError code of a failure
+type RetryPolicySet struct {
+	defaultPolicy RetryPolicy
+	byErrorCode   map[string]RetryPolicy
+}
+
+// NewRetryPolicySet builds the default policy and per-error-code overrides from config
+func NewRetryPolicySet(cfg *config.Config) *RetryPolicySet {
+	set := &RetryPolicySet{
+		defaultPolicy: newRetryPolicy(cfg.RetryStrategy, cfg.RetryDelay, cfg),
+		byErrorCode:   make(map[string]RetryPolicy),
+	}
+
+	for code, override := range cfg.RetryPolicyOverrides {
+		set.byErrorCode[code] = newRetryPolicy(override.Strategy, override.Delay, cfg)
+	}
+
+	return set
+}
+
+// PolicyFor returns the policy for the error's code, or the default policy
+func (s *RetryPolicySet) PolicyFor(err error) RetryPolicy {
+	if policy, ok := s.byErrorCode[errors.ErrorCode(err)]; ok {
+		return policy
+	}
+	return s.defaultPolicy
+}
+
+// newRetryPolicy creates a policy for a strategy, sharing the configured cap and jitter
+func newRetryPolicy(strategy string, delay time.Duration, cfg *config.Config) RetryPolicy {
+	switch strategy {
+	case config.RetryStrategyFixed:
+		return &FixedRetryPolicy{Delay: delay, MaxDelay: cfg.RetryMaxDelay}
+	case config.RetryStrategyLinear:
+		return &LinearRetryPolicy{Delay: delay, MaxDelay: cfg.RetryMaxDelay}
+	default:
+		return &ExponentialRetryPolicy{Delay: delay, MaxDelay: cfg.RetryMaxDelay, Jitter: cfg.RetryJitter}
+	}
+}
@@ -0,0 +1,136 @@
+package email
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/errors"
+)
+
+func TestRetryPolicySchedules(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		{
+			name:   "fixed",
+			policy: &FixedRetryPolicy{Delay: time.Minute},
+			want:   []time.Duration{time.Minute, time.Minute, time.Minute},
+		},
+		{
+			name:   "fixed capped",
+			policy: &FixedRetryPolicy{Delay: time.Hour, MaxDelay: 30 * time.Minute},
+			want:   []time.Duration{30 * time.Minute, 30 * time.Minute},
+		},
+		{
+			name:   "linear",
+			policy: &LinearRetryPolicy{Delay: 30 * time.Second, MaxDelay: 2 * time.Minute},
+			want:   []time.Duration{30 * time.Second, time.Minute, 90 * time.Second, 2 * time.Minute, 2 * time.Minute},
+		},
+		{
+			name:   "linear attempt zero counts as the first",
+			policy: &LinearRetryPolicy{Delay: 30 * time.Second},
+			want:   []time.Duration{30 * time.Second},
+		},
+		{
+			name:   "exponential",
+			policy: &ExponentialRetryPolicy{Delay: time.Minute, MaxDelay: 30 * time.Minute},
+			want:   []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute},
+		},
+		{
+			name:   "exponential overflow is capped",
+			policy: &ExponentialRetryPolicy{Delay: time.Hour, MaxDelay: 2 * time.Hour},
+			want:   []time.Duration{time.Hour, 2 * time.Hour, 2 * time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				if got := tt.policy.NextDelay(i + 1); got != want {
+					t.Errorf("attempt %d: got %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+
+	// Attempts far past any sensible retry count must not overflow into a negative delay
+	policy := &ExponentialRetryPolicy{Delay: time.Minute, MaxDelay: 30 * time.Minute}
+	if got := policy.NextDelay(200); got != 30*time.Minute {
+		t.Errorf("attempt 200: got %v, want the 30m cap", got)
+	}
+}
+
+func TestExponentialRetryPolicyJitter(t *testing.T) {
+	policy := &ExponentialRetryPolicy{Delay: time.Minute, MaxDelay: 30 * time.Minute, Jitter: 0.2}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 200; i++ {
+		delay := policy.NextDelay(3)
+		if delay <= 3*time.Minute+12*time.Second || delay > 4*time.Minute {
+			t.Fatalf("got %v, want within 20%% below 4m", delay)
+		}
+		seen[delay] = true
+	}
+	if len(seen) < 2 {
+		t.Error("jitter did not vary the delay")
+	}
+}
+
+func TestCapDelay(t *testing.T) {
+	tests := []struct {
+		delay, maxDelay, want time.Duration
+	}{
+		{delay: time.Minute, maxDelay: 0, want: time.Minute},
+		{delay: time.Minute, maxDelay: time.Hour, want: time.Minute},
+		{delay: 2 * time.Hour, maxDelay: time.Hour, want: time.Hour},
+		{delay: -time.Second, maxDelay: time.Hour, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := capDelay(tt.delay, tt.maxDelay); got != tt.want {
+			t.Errorf("capDelay(%v, %v) = %v, want %v", tt.delay, tt.maxDelay, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicySetPolicyFor(t *testing.T) {
+	cfg := &config.Config{
+		RetryStrategy: config.RetryStrategyExponential,
+		RetryDelay:    time.Minute,
+		RetryMaxDelay: 30 * time.Minute,
+		RetryPolicyOverrides: map[string]config.RetryPolicyConfig{
+			errors.SMTPErrorCode:     {Strategy: config.RetryStrategyFixed, Delay: 5 * time.Second},
+			errors.RabbitMQErrorCode: {Strategy: config.RetryStrategyLinear, Delay: 5 * time.Minute},
+		},
+	}
+	set := NewRetryPolicySet(cfg)
+
+	tests := []struct {
+		name     string
+		err      error
+		strategy string
+		delay    time.Duration
+	}{
+		{name: "SMTP", err: errors.NewSMTPError("try again"), strategy: config.RetryStrategyFixed, delay: 5 * time.Second},
+		{name: "RabbitMQ", err: errors.NewRabbitMQError("broker down"), strategy: config.RetryStrategyLinear, delay: 5 * time.Minute},
+		{name: "Redis falls back to the default", err: errors.NewRedisError("timeout"), strategy: config.RetryStrategyExponential, delay: time.Minute},
+		{name: "job processing error falls back to the default", err: errors.NewJobProcessingError("boom"), strategy: config.RetryStrategyExponential, delay: time.Minute},
+		{name: "plain error falls back to the default", err: stderrors.New("boom"), strategy: config.RetryStrategyExponential, delay: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := set.PolicyFor(tt.err)
+			if policy.Name() != tt.strategy {
+				t.Errorf("picked %s, want %s", policy.Name(), tt.strategy)
+			}
+			if got := policy.NextDelay(1); got != tt.delay {
+				t.Errorf("first delay %v, want %v", got, tt.delay)
+			}
+		})
+	}
+}
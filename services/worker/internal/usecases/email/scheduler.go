@@ -0,0 +1,202 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/infrastructure/cache"
+	"task-scheduler-worker/internal/infrastructure/messaging"
+)
+
+// promoterLock is held by the one worker instance releasing due jobs
+const promoterLock = "scheduled_jobs_promoter"
+
+// SchedulerUseCaseImpl implements SchedulerUseCase on top of the cache's
+// scheduled job set, so parked jobs survive worker restarts
+type SchedulerUseCaseImpl struct {
+	cacheService     cache.CacheService
+	messagingService messaging.MessageBroker
+	config           *config.Config
+	tracer           trace.Tracer
+
+	// owner identifies this instance when it holds the promoter lock
+	owner string
+}
+
+// NewSchedulerUseCase creates a new scheduler use case
+func NewSchedulerUseCase(
+	cacheService cache.CacheService,
+	messagingService messaging.MessageBroker,
+	config *config.Config,
+	tracer trace.Tracer,
+) *SchedulerUseCaseImpl {
+	return &SchedulerUseCaseImpl{
+		cacheService:     cacheService,
+		messagingService: messagingService,
+		config:           config,
+		tracer:           tracer,
+		owner:            instanceID(),
+	}
+}
+
+// instanceID returns a name unique to this process, for lock ownership
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// ScheduleJob parks a job until its send time. It returns once the job is
+// stored, so the caller can safely acknowledge the delivery.
+func (s *SchedulerUseCaseImpl) ScheduleJob(ctx context.Context, job *models.EmailJob) error {
+	ctx, span := s.tracer.Start(ctx, "schedule_job")
+	defer span.End()
+
+	if job.SendAt == nil {
+		err := errors.NewValidationError("job has no send time")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	sendAt := job.SendAt.UTC().Format(time.RFC3339)
+	span.SetAttributes(
+		attribute.String("email.job_id", job.JobID),
+		attribute.String("email.send_at", sendAt),
+	)
+
+	// Parking a job until after its deadline would only delay expiring it
+	if job.IsExpired(*job.SendAt) {
+		return s.expire(ctx, job, sendAt)
+	}
+
+	job.UpdateStatus(models.JobStatusScheduled, "Job scheduled for "+sendAt, "")
+
+	// Record the status first: if parking fails the delivery is requeued and
+	// the job is parked again, whereas a job parked without the status would
+	// show as queued until it is sent
+	statusChange := &models.StatusChange{
+		Status:  models.JobStatusScheduled,
+		Message: "Job scheduled for " + sendAt,
+		Details: map[string]string{"send_at": sendAt},
+	}
+	if err := s.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
+		log.Printf("Error updating job status to scheduled: %v", err)
+		span.RecordError(err)
+	}
+
+	if err := s.cacheService.ScheduleJob(ctx, job, s.config.JobTTL); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to park job")
+		return err
+	}
+
+	log.Printf("Job %s scheduled for %s", job.JobID, sendAt)
+	span.SetStatus(codes.Ok, "Job scheduled")
+	return nil
+}
+
+// expire ends a job whose send time is not before its deadline
+func (s *SchedulerUseCaseImpl) expire(ctx context.Context, job *models.EmailJob, sendAt string) error {
+	expiresAt := job.ExpiresAt.UTC().Format(time.RFC3339)
+	message := "Send time is past the deadline"
+	finalError := errors.NewJobExpiredError(job.JobID, *job.ExpiresAt).Error()
+
+	job.UpdateStatus(models.JobStatusExpired, message, finalError)
+
+	statusChange := &models.StatusChange{
+		Status:  models.JobStatusExpired,
+		Message: message,
+		Error:   finalError,
+		Details: map[string]string{"send_at": sendAt, "expires_at": expiresAt},
+	}
+	if err := s.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
+		log.Printf("Error updating job status to expired: %v", err)
+	}
+
+	log.Printf("Job %s not scheduled: send time %s is past its deadline %s", job.JobID, sendAt, expiresAt)
+	return nil
+}
+
+// PromoteDueJobs releases due jobs to the main queue and returns how many it
+// released. Only the instance holding the promoter lock releases anything;
+// the others return straight away.
+//
+// A job is removed from the schedule only after the broker confirms it, so a
+// crash in between never loses the job but releases it again on the next run.
+// The second copy is dropped if the first has finished by the time it is
+// consumed, since a job in a final status is not processed again. A copy
+// consumed while the first is still sending or waiting to retry is sent
+// again; idempotency keys do not prevent this, because both copies carry the
+// same job ID and so hold the same claim.
+func (s *SchedulerUseCaseImpl) PromoteDueJobs(ctx context.Context) (int, error) {
+	held, err := s.cacheService.AcquireLock(ctx, promoterLock, s.owner, s.config.SchedulerLockTTL)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	jobs, err := s.cacheService.DueJobs(ctx, time.Now(), s.config.SchedulerBatchSize)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+
+	ctx, span := s.tracer.Start(ctx, "promote_due_jobs")
+	defer span.End()
+	span.SetAttributes(attribute.Int("scheduler.due_jobs", len(jobs)))
+
+	queueNames := messaging.DefaultQueueNames()
+	promoted := 0
+	for _, job := range jobs {
+		job.UpdateStatus(models.JobStatusPending, "Scheduled send time reached", "")
+
+		if err := s.messagingService.PublishEmailJob(ctx, queueNames.LaneQueue(job.Lane()), job); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to release scheduled job")
+			return promoted, err
+		}
+
+		// A crash here leaves the job parked, to be released once more
+		if err := s.cacheService.UnscheduleJob(ctx, job.JobID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to unschedule released job")
+			return promoted, err
+		}
+		promoted++
+
+		statusChange := &models.StatusChange{
+			Status:  models.JobStatusPending,
+			Message: "Scheduled send time reached",
+		}
+		if err := s.cacheService.ApplyStatusChange(ctx, job.JobID, statusChange); err != nil {
+			log.Printf("Error updating job status to pending: %v", err)
+			span.RecordError(err)
+		}
+
+		log.Printf("Scheduled job %s released for sending", job.JobID)
+	}
+
+	span.SetAttributes(attribute.Int("scheduler.promoted_jobs", promoted))
+	span.SetStatus(codes.Ok, "Due jobs released")
+	return promoted, nil
+}
+
+// Resign gives up the promoter lock so another instance can take over at once
+func (s *SchedulerUseCaseImpl) Resign(ctx context.Context) error {
+	return s.cacheService.ReleaseLock(ctx, promoterLock, s.owner)
+}
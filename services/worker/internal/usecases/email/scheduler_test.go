@@ -0,0 +1,198 @@
+package email
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/infrastructure/messaging"
+)
+
+// newTestScheduler returns a scheduler publishing to broker
+func newTestScheduler(cacheService *fakeCache, broker *fakeBroker) *SchedulerUseCaseImpl {
+	return NewSchedulerUseCase(cacheService, broker, testConfig(), noop.NewTracerProvider().Tracer(""))
+}
+
+// scheduledJob returns a job to be sent at sendAt
+func scheduledJob(jobID string, sendAt time.Time) *models.EmailJob {
+	job := models.NewEmailJob(jobID, "jane@a.test", "Hello", "Body text", 3)
+	job.SendAt = &sendAt
+	return job
+}
+
+func TestScheduleJob(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	scheduler := newTestScheduler(cacheService, broker)
+
+	if err := scheduler.ScheduleJob(context.Background(), scheduledJob("job-1", time.Now().Add(time.Hour))); err != nil {
+		t.Fatalf("ScheduleJob returned %v", err)
+	}
+	if got := cacheService.Scheduled(); !reflect.DeepEqual(got, []string{"job-1"}) {
+		t.Errorf("parked jobs %v, want job-1", got)
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusScheduled {
+		t.Errorf("job is %s, want scheduled", got)
+	}
+
+	// A job without a send time has nothing to wait for
+	err := scheduler.ScheduleJob(context.Background(), models.NewEmailJob("job-2", "jane@a.test", "Hello", "Body text", 3))
+	if !errors.IsValidationError(err) {
+		t.Errorf("ScheduleJob returned %v without a send time, want a validation error", err)
+	}
+}
+
+func TestScheduleJobPastDeadlineExpires(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	scheduler := newTestScheduler(cacheService, broker)
+
+	job := scheduledJob("job-1", time.Now().Add(2*time.Hour))
+	expiresAt := time.Now().Add(time.Hour)
+	job.ExpiresAt = &expiresAt
+
+	if err := scheduler.ScheduleJob(context.Background(), job); err != nil {
+		t.Fatalf("ScheduleJob returned %v", err)
+	}
+	if got := cacheService.Scheduled(); len(got) != 0 {
+		t.Errorf("parked %v past their deadline", got)
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusExpired {
+		t.Errorf("job is %s, want expired", got)
+	}
+}
+
+func TestPromoteDueJobs(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	scheduler := newTestScheduler(cacheService, broker)
+	now := time.Now()
+
+	urgent := scheduledJob("job-urgent", now.Add(-time.Minute))
+	urgent.Priority = models.JobPriorityHigh
+	for _, job := range []*models.EmailJob{scheduledJob("job-due", now.Add(-time.Hour)), urgent, scheduledJob("job-later", now.Add(time.Hour))} {
+		if err := scheduler.ScheduleJob(context.Background(), job); err != nil {
+			t.Fatalf("ScheduleJob returned %v", err)
+		}
+	}
+
+	promoted, err := scheduler.PromoteDueJobs(context.Background())
+	if err != nil || promoted != 2 {
+		t.Fatalf("PromoteDueJobs returned %d, %v, want 2 jobs", promoted, err)
+	}
+
+	queues := messaging.DefaultQueueNames()
+	if got := broker.Published(queues.EmailTasks); len(got) != 1 || got[0].JobID != "job-due" {
+		t.Errorf("main queue received %v, want job-due", got)
+	}
+	if got := broker.Published(queues.LaneQueue(models.JobPriorityHigh)); len(got) != 1 || got[0].JobID != "job-urgent" {
+		t.Errorf("high lane received %v, want job-urgent", got)
+	}
+	if got := cacheService.Scheduled(); !reflect.DeepEqual(got, []string{"job-later"}) {
+		t.Errorf("still parked %v, want job-later", got)
+	}
+	for _, id := range []string{"job-due", "job-urgent"} {
+		if got := cacheService.Statuses(id); !reflect.DeepEqual(got, []models.JobStatus{models.JobStatusScheduled, models.JobStatusPending}) {
+			t.Errorf("%s went through %v, want scheduled then pending", id, got)
+		}
+	}
+
+	// Nothing else is due
+	if promoted, err := scheduler.PromoteDueJobs(context.Background()); err != nil || promoted != 0 {
+		t.Errorf("second PromoteDueJobs returned %d, %v, want nothing", promoted, err)
+	}
+}
+
+func TestPromoteDueJobsHonorsBatchSize(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	scheduler := newTestScheduler(cacheService, broker)
+	scheduler.config.SchedulerBatchSize = 2
+
+	for i, id := range []string{"job-1", "job-2", "job-3"} {
+		if err := scheduler.ScheduleJob(context.Background(), scheduledJob(id, time.Now().Add(time.Duration(i-10)*time.Minute))); err != nil {
+			t.Fatalf("ScheduleJob returned %v", err)
+		}
+	}
+
+	if promoted, _ := scheduler.PromoteDueJobs(context.Background()); promoted != 2 {
+		t.Fatalf("promoted %d jobs, want a batch of 2", promoted)
+	}
+	if got := cacheService.Scheduled(); !reflect.DeepEqual(got, []string{"job-3"}) {
+		t.Errorf("still parked %v, want the latest job", got)
+	}
+}
+
+func TestPromoteDueJobsOnlyOnLeader(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	leader := newTestScheduler(cacheService, broker)
+	follower := newTestScheduler(cacheService, broker)
+
+	if err := leader.ScheduleJob(context.Background(), scheduledJob("job-1", time.Now().Add(-time.Minute))); err != nil {
+		t.Fatalf("ScheduleJob returned %v", err)
+	}
+	if held, _ := cacheService.AcquireLock(context.Background(), promoterLock, leader.owner, time.Minute); !held {
+		t.Fatal("leader could not take the promoter lock")
+	}
+
+	if promoted, err := follower.PromoteDueJobs(context.Background()); err != nil || promoted != 0 {
+		t.Fatalf("follower promoted %d jobs (%v) while the leader holds the lock", promoted, err)
+	}
+	if got := cacheService.Scheduled(); len(got) != 1 {
+		t.Fatalf("parked jobs %v after the follower ran, want job-1", got)
+	}
+
+	// Once the leader resigns, the follower takes over
+	if err := leader.Resign(context.Background()); err != nil {
+		t.Fatalf("Resign returned %v", err)
+	}
+	if promoted, err := follower.PromoteDueJobs(context.Background()); err != nil || promoted != 1 {
+		t.Errorf("follower promoted %d jobs (%v) after the leader resigned, want 1", promoted, err)
+	}
+}
+
+func TestPromoteDueJobsKeepsJobWhenPublishFails(t *testing.T) {
+	cacheService, broker := newFakeCache(), newFakeBroker()
+	scheduler := newTestScheduler(cacheService, broker)
+
+	if err := scheduler.ScheduleJob(context.Background(), scheduledJob("job-1", time.Now().Add(-time.Minute))); err != nil {
+		t.Fatalf("ScheduleJob returned %v", err)
+	}
+
+	broker.err = errors.NewRabbitMQError("connection closed")
+	if promoted, err := scheduler.PromoteDueJobs(context.Background()); err == nil || promoted != 0 {
+		t.Fatalf("PromoteDueJobs returned %d, %v, want the publish error", promoted, err)
+	}
+	if got := cacheService.Scheduled(); !reflect.DeepEqual(got, []string{"job-1"}) {
+		t.Errorf("parked jobs %v after a failed publish, want job-1 kept", got)
+	}
+	if got := cacheService.Job("job-1").Status; got != models.JobStatusScheduled {
+		t.Errorf("job is %s, want still scheduled", got)
+	}
+
+	broker.err = nil
+	if promoted, err := scheduler.PromoteDueJobs(context.Background()); err != nil || promoted != 1 {
+		t.Errorf("PromoteDueJobs returned %d, %v once the broker is back, want 1", promoted, err)
+	}
+}
+
+func TestJobReleasedTwiceIsSentOnce(t *testing.T) {
+	cacheService, emailService := newFakeCache(), &fakeEmailService{}
+	uc := newTestProcessor(cacheService, emailService)
+
+	// A crash between publishing and unscheduling releases the job again
+	// on the next promotion; the copy arriving after the first was sent is
+	// turned away by the job's status
+	job := scheduledJob("job-1", time.Now().Add(-time.Minute))
+	for i := 0; i < 2; i++ {
+		released := *job
+		if err := uc.ProcessEmailJob(context.Background(), &released); err != nil {
+			t.Fatalf("ProcessEmailJob returned %v for copy %d", err, i+1)
+		}
+	}
+
+	if attempts := emailService.Attempts(); len(attempts) != 1 {
+		t.Errorf("job was sent %d times, want once", len(attempts))
+	}
+}
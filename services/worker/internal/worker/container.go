@@ -3,13 +3,16 @@ package worker
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/models"
 	"task-scheduler-worker/internal/handlers"
 	"task-scheduler-worker/internal/infrastructure/cache"
 	"task-scheduler-worker/internal/infrastructure/email"
 	"task-scheduler-worker/internal/infrastructure/messaging"
+	"task-scheduler-worker/internal/infrastructure/templates"
 	"task-scheduler-worker/internal/infrastructure/tracing"
 	emailUC "task-scheduler-worker/internal/usecases/email"
 	"task-scheduler-worker/pkg/logger"
@@ -26,10 +29,12 @@ type Container struct {
 	CacheService     cache.CacheService
 	MessagingService messaging.MessageBroker
 	EmailService     email.EmailService
+	TemplateService  templates.TemplateService
 
 	// Use cases
 	EmailProcessorUseCase emailUC.EmailProcessorUseCase
 	RetryHandlerUseCase   emailUC.RetryHandlerUseCase
+	SchedulerUseCase      emailUC.SchedulerUseCase
 
 	// Handlers
 	HealthHandler *handlers.HealthHandler
@@ -105,28 +110,134 @@ func (c *Container) initTracing() error {
 // initInfrastructure initializes all infrastructure services
 func (c *Container) initInfrastructure() error {
 	// Initialize Redis cache service
-	redisService, err := cache.NewRedisService(c.Config.RedisURL)
+	redisService, err := cache.NewRedisService(c.Config.RedisURL, c.Config.JobTTL)
 	if err != nil {
 		return fmt.Errorf("failed to create Redis service: %w", err)
 	}
 	c.CacheService = redisService
 
 	// Initialize RabbitMQ messaging service
-	rabbitMQService := messaging.NewRabbitMQService(c.Config.RabbitMQURL)
+	rabbitMQService := messaging.NewRabbitMQService(
+		c.Config.RabbitMQURL,
+		c.Config.WorkerConcurrency,
+		c.Config.PrefetchCount,
+		laneWeights(c.Config.PriorityWeights),
+		c.Logger,
+	)
 	c.MessagingService = rabbitMQService
 
-	// Initialize SMTP email service
+	// Initialize the email service for the configured transport
 	emailConfig := &email.EmailConfig{
 		SMTPHost: c.Config.SMTPHost,
 		SMTPPort: c.Config.SMTPPort,
 		From:     "noreply@distributed-scheduler.com",
+		BlobDir:  c.Config.AttachmentBlobDir,
+
+		TLSMode:       c.Config.SMTPTLSMode,
+		CAFile:        c.Config.SMTPCAFile,
+		Username:      c.Config.SMTPUsername,
+		Password:      c.Config.SMTPPassword,
+		AuthMechanism: c.Config.SMTPAuthMechanism,
+
+		MaxActiveConns:     c.Config.SMTPPoolMaxActive,
+		MaxIdleConns:       c.Config.SMTPPoolMaxIdle,
+		MaxMessagesPerConn: c.Config.SMTPPoolMaxMessages,
+		IdleTimeout:        c.Config.SMTPPoolIdleTimeout,
+
+		CheckRecipientDomains: c.Config.RecipientDomainCheck,
+
+		HTTPEndpoint:       c.Config.HTTPAPIEndpoint,
+		HTTPAuthHeader:     c.Config.HTTPAPIAuthHeader,
+		HTTPAuthValue:      c.Config.HTTPAPIAuthToken,
+		HTTPTimeout:        c.Config.HTTPAPITimeout,
+		HTTPPayloadMapping: c.Config.HTTPAPIPayloadMapping,
+
+		SinkDir: c.Config.EmailSinkDir,
+
+		MXPort:            c.Config.MXPort,
+		HeloName:          c.Config.MXHeloName,
+		MaxConnsPerDomain: c.Config.MXMaxConnsPerDomain,
+	}
+	if c.Config.DKIMPrivateKeyFile != "" {
+		var headers []string
+		if c.Config.DKIMHeaders != "" {
+			headers = strings.Split(c.Config.DKIMHeaders, ":")
+		}
+		signer, err := email.NewDKIMSigner(email.DKIMOptions{
+			Domain:           c.Config.DKIMDomain,
+			Selector:         c.Config.DKIMSelector,
+			PrivateKeyFile:   c.Config.DKIMPrivateKeyFile,
+			Canonicalization: c.Config.DKIMCanonicalization,
+			Headers:          headers,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to configure DKIM signing: %w", err)
+		}
+		emailConfig.DKIM = signer
+	}
+	transports := c.Config.EmailTransports()
+	if len(transports) == 1 {
+		c.EmailService = c.newEmailTransport(transports[0], emailConfig)
+	} else {
+		// Several transports form a failover chain, tried in the configured order
+		backends := make([]email.Backend, len(transports))
+		for i, name := range transports {
+			backends[i] = email.Backend{Name: name, Service: c.newEmailTransport(name, emailConfig)}
+		}
+		c.EmailService = email.NewFailoverService(backends, email.CircuitBreakerConfig{
+			FailureThreshold: c.Config.EmailBreakerFailureThreshold,
+			OpenTimeout:      c.Config.EmailBreakerOpenTimeout,
+		})
 	}
-	smtpService := email.NewSMTPService(emailConfig)
-	c.EmailService = smtpService
+
+	// Initialize template service
+	templateService, err := templates.NewFileTemplateService(
+		c.Config.TemplateDir,
+		c.Config.TemplateDefaultLocale,
+		c.Config.TemplateReloadInterval,
+		c.Logger,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load templates: %w", err)
+	}
+	c.TemplateService = templateService
 
 	return nil
 }
 
+// newEmailTransport creates the email service for one EMAIL_TRANSPORT entry
+func (c *Container) newEmailTransport(name string, emailConfig *email.EmailConfig) email.EmailService {
+	switch name {
+	case config.EmailTransportMX:
+		return email.NewMXService(emailConfig)
+	case config.EmailTransportHTTP:
+		return email.NewHTTPAPIService(emailConfig)
+	case config.EmailTransportFile:
+		return email.NewFileSinkService(emailConfig)
+	case config.EmailTransportStdout:
+		return email.NewStdoutSinkService(emailConfig)
+	case config.EmailTransportSMTPSecondary:
+		secondary := *emailConfig
+		secondary.SMTPHost = c.Config.SMTPSecondaryHost
+		secondary.SMTPPort = c.Config.SMTPSecondaryPort
+		secondary.TLSMode = c.Config.SMTPSecondaryTLSMode
+		secondary.Username = c.Config.SMTPSecondaryUsername
+		secondary.Password = c.Config.SMTPSecondaryPassword
+		return email.NewSMTPService(&secondary)
+	default:
+		return email.NewSMTPService(emailConfig)
+	}
+}
+
+// laneWeights keys the configured priority weights by job priority
+func laneWeights(weights map[string]int) map[models.JobPriority]int {
+	lanes := make(map[models.JobPriority]int, len(weights))
+	for priority, weight := range weights {
+		lanes[models.JobPriority(priority)] = weight
+	}
+	return lanes
+}
+
 // initUseCases initializes business logic use cases
 func (c *Container) initUseCases() error {
 	tracer := c.TracingService.GetTracer()
@@ -135,6 +246,7 @@ func (c *Container) initUseCases() error {
 	c.EmailProcessorUseCase = emailUC.NewProcessEmailUseCase(
 		c.CacheService,
 		c.EmailService,
+		c.TemplateService,
 		c.Config,
 		tracer,
 	)
@@ -147,6 +259,14 @@ func (c *Container) initUseCases() error {
 		tracer,
 	)
 
+	// Initialize scheduled send use case
+	c.SchedulerUseCase = emailUC.NewSchedulerUseCase(
+		c.CacheService,
+		c.MessagingService,
+		c.Config,
+		tracer,
+	)
+
 	return nil
 }
 
@@ -158,6 +278,7 @@ func (c *Container) initHandlers() error {
 		c.MessagingService,
 		c.EmailService,
 		c.Logger,
+		c.Config.WorkerConcurrency,
 	)
 
 	return nil
@@ -215,6 +336,16 @@ func (c *Container) Shutdown(ctx context.Context) error {
 		errors = append(errors, fmt.Errorf("failed to close messaging service: %w", err))
 	}
 
+	// Close email service connections
+	if err := c.EmailService.Close(); err != nil {
+		errors = append(errors, fmt.Errorf("failed to close email service: %w", err))
+	}
+
+	// Stop watching templates
+	if err := c.TemplateService.Close(); err != nil {
+		errors = append(errors, fmt.Errorf("failed to close template service: %w", err))
+	}
+
 	// Close cache service
 	if err := c.CacheService.Close(); err != nil {
 		errors = append(errors, fmt.Errorf("failed to close cache service: %w", err))
@@ -2,79 +2,184 @@ package worker
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"task-scheduler-worker/internal/domain/errors"
 	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/infrastructure/messaging"
 )
 
 // WorkerService orchestrates email job processing
 type WorkerService struct {
 	container *Container
-	isRunning bool
+	isRunning atomic.Bool
+
+	// stopped is cancelled by Stop; done is closed when Start returns. Both
+	// exist before Start runs, so Stop works even if it comes first.
+	stopped context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
 }
 
 // NewWorkerService creates a new worker service
 func NewWorkerService(container *Container) *WorkerService {
+	stopped, cancel := context.WithCancel(context.Background())
 	return &WorkerService{
 		container: container,
-		isRunning: false,
+		stopped:   stopped,
+		cancel:    cancel,
+		done:      make(chan struct{}),
 	}
 }
 
-// Start starts the worker service
+// Start starts the worker service and blocks until ctx is cancelled or Stop is
+// called. It must be called exactly once.
 func (w *WorkerService) Start(ctx context.Context) error {
-	w.isRunning = true
-	w.container.HealthHandler.SetRunning(true)
+	defer close(w.done)
 
-	w.container.Logger.Info("Starting email job consumer...")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopOnStop := context.AfterFunc(w.stopped, cancel)
+	defer stopOnStop()
 
-	// Start consuming jobs from RabbitMQ
-	err := w.container.MessagingService.ConsumeEmailJobs(ctx, w.handleEmailJob)
-	if err != nil {
-		return err
+	// Stopped before it got going
+	if w.stopped.Err() != nil || ctx.Err() != nil {
+		return nil
 	}
 
+	w.isRunning.Store(true)
+	w.container.HealthHandler.SetRunning(true)
+
+	// Release scheduled jobs as they fall due, stopping with the consumer
+	var promoter sync.WaitGroup
+	promoter.Add(1)
+	go func() {
+		defer promoter.Done()
+		w.promoteScheduledJobs(ctx)
+	}()
+	defer promoter.Wait()
+	defer cancel()
+
+	// Jobs being sent run under their own context, so stopping the consumer
+	// does not cut off a message half way through. It is only cancelled if
+	// they are still running ShutdownTimeout after the stop.
+	drainCtx, cancelDrain := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelDrain()
+	stopDrain := context.AfterFunc(ctx, func() {
+		time.AfterFunc(w.container.Config.ShutdownTimeout, cancelDrain)
+	})
+	defer stopDrain()
+
+	w.container.Logger.Info("Starting email job consumer...", "concurrency", w.container.Config.WorkerConcurrency)
 	w.container.Logger.Info("Email worker ready to process jobs")
-	return nil
+
+	// Consume jobs from RabbitMQ until the context is cancelled
+	return w.container.MessagingService.ConsumeEmailJobs(ctx, func(_ context.Context, job *models.EmailJob) messaging.DeliveryOutcome {
+		return w.handleEmailJob(drainCtx, job)
+	})
 }
 
-// Stop stops the worker service
+// Stop stops the worker service and waits for in-flight jobs to be settled,
+// which takes at most the shutdown timeout. Start must have been called,
+// though it may not have begun running yet.
 func (w *WorkerService) Stop() {
-	w.isRunning = false
+	w.isRunning.Store(false)
 	w.container.HealthHandler.SetRunning(false)
+
+	w.cancel()
+	<-w.done
+
 	w.container.Logger.Info("Worker service stopped")
 }
 
+// promoteScheduledJobs releases due scheduled jobs every poll interval until
+// ctx is cancelled. Every instance runs it, but only the one holding the
+// promoter lock releases anything; the lock moves to another instance when
+// this one stops or stops renewing it.
+func (w *WorkerService) promoteScheduledJobs(ctx context.Context) {
+	logger := w.container.Logger.WithComponent("scheduler")
+
+	ticker := time.NewTicker(w.container.Config.SchedulerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Hand the lock over now rather than when it expires
+			resignCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := w.container.SchedulerUseCase.Resign(resignCtx); err != nil {
+				logger.Warn("Failed to release scheduler lock", "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+
+		promoted, err := w.container.SchedulerUseCase.PromoteDueJobs(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Failed to release scheduled jobs", "error", err)
+		}
+		if promoted > 0 {
+			logger.Info("Released scheduled jobs", "count", promoted)
+		}
+	}
+}
+
 // IsRunning returns whether the worker is currently running
 func (w *WorkerService) IsRunning() bool {
-	return w.isRunning
+	return w.isRunning.Load()
 }
 
-// handleEmailJob processes an individual email job
-func (w *WorkerService) handleEmailJob(job *models.EmailJob) {
-	ctx := context.Background()
-	
+// handleEmailJob processes an individual email job and decides how its delivery is settled
+func (w *WorkerService) handleEmailJob(ctx context.Context, job *models.EmailJob) messaging.DeliveryOutcome {
 	logger := w.container.Logger.WithJobID(job.JobID)
 	
-	logger.LogJobStart(ctx, job.JobID, job.To, job.Subject, job.RetryCount, job.MaxRetries)
+	logger.LogJobStart(ctx, job.JobID, job.To.String(), job.Subject, job.RetryCount, job.MaxRetries)
 
-	if !w.isRunning {
-		logger.Warn("Worker not running, skipping job")
-		return
+	if !w.isRunning.Load() {
+		logger.Warn("Worker not running, requeueing job")
+		return messaging.DeliveryRequeue
 	}
 
+	// Jobs due later are parked until their send time. Invalid jobs are not
+	// parked; sending rejects them and their failure is recorded.
+	if !job.IsDue(time.Now()) && job.Validate() == nil {
+		if err := w.container.SchedulerUseCase.ScheduleJob(ctx, job); err != nil {
+			logger.Warn("Job was not scheduled, returning delivery to the queue", "error", err)
+			return messaging.DeliveryRequeue
+		}
+		return messaging.DeliveryAck
+	}
+
+	w.container.HealthHandler.JobStarted()
+	defer w.container.HealthHandler.JobFinished()
+
 	// Process the email job
 	err := w.container.EmailProcessorUseCase.ProcessEmailJob(ctx, job)
 	if err != nil {
+		if ctx.Err() != nil {
+			logger.Warn("Shutdown timeout reached, requeueing job", "error", err)
+			return messaging.DeliveryRequeue
+		}
+
 		logger.LogJobFailed(ctx, job.JobID, err, job.RetryCount)
 		
 		// Handle retry logic
 		if retryErr := w.container.RetryHandlerUseCase.HandleRetry(ctx, job, err); retryErr != nil {
+			// The retry never reached the broker, keep the original delivery instead
+			if ctx.Err() != nil || errors.IsInfrastructureError(retryErr) {
+				logger.Warn("Job retry was not requeued, returning delivery to the queue", "error", retryErr)
+				return messaging.DeliveryRequeue
+			}
 			logger.Error("Failed to handle job retry", "error", retryErr)
 		}
-		return
+		return messaging.DeliveryAck
 	}
 
 	// Job completed successfully
 	logger.LogJobCompleted(ctx, job.JobID)
 	w.container.HealthHandler.IncrementJobsProcessed()
-}
\ No newline at end of file
+	return messaging.DeliveryAck
+}
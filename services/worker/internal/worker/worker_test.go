@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"task-scheduler-worker/internal/config"
+	"task-scheduler-worker/internal/domain/errors"
+	"task-scheduler-worker/internal/domain/models"
+	"task-scheduler-worker/internal/handlers"
+	"task-scheduler-worker/internal/infrastructure/messaging"
+	"task-scheduler-worker/pkg/logger"
+)
+
+// fakeBroker hands the jobs sent on deliveries to the handler, one goroutine
+// each, and records how each delivery was settled
+type fakeBroker struct {
+	messaging.MessageBroker
+
+	deliveries chan *models.EmailJob
+
+	mu       sync.Mutex
+	outcomes map[string]messaging.DeliveryOutcome
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		deliveries: make(chan *models.EmailJob),
+		outcomes:   make(map[string]messaging.DeliveryOutcome),
+	}
+}
+
+// ConsumeEmailJobs returns once ctx is cancelled and every handler returned
+func (b *fakeBroker) ConsumeEmailJobs(ctx context.Context, handler messaging.EmailJobHandler) error {
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case job := <-b.deliveries:
+			inflight.Add(1)
+			go func() {
+				defer inflight.Done()
+				outcome := handler(ctx, job)
+				b.mu.Lock()
+				b.outcomes[job.JobID] = outcome
+				b.mu.Unlock()
+			}()
+		}
+	}
+}
+
+// Outcome returns how a job's delivery was settled
+func (b *fakeBroker) Outcome(jobID string) (messaging.DeliveryOutcome, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	outcome, ok := b.outcomes[jobID]
+	return outcome, ok
+}
+
+// processorFunc adapts a function to EmailProcessorUseCase
+type processorFunc func(ctx context.Context, job *models.EmailJob) error
+
+func (f processorFunc) ProcessEmailJob(ctx context.Context, job *models.EmailJob) error {
+	return f(ctx, job)
+}
+
+// fakeRetryHandler answers every failure with err
+type fakeRetryHandler struct {
+	err error
+}
+
+func (h *fakeRetryHandler) HandleRetry(ctx context.Context, job *models.EmailJob, err error) error {
+	return h.err
+}
+
+func (h *fakeRetryHandler) ShouldRetry(job *models.EmailJob, err error) bool {
+	return true
+}
+
+// fakeScheduler parks jobs, failing with err
+type fakeScheduler struct {
+	err error
+}
+
+func (s *fakeScheduler) ScheduleJob(ctx context.Context, job *models.EmailJob) error {
+	return s.err
+}
+
+func (s *fakeScheduler) PromoteDueJobs(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (s *fakeScheduler) Resign(ctx context.Context) error {
+	return nil
+}
+
+// newTestWorker returns a worker consuming from broker and processing jobs
+// with process
+func newTestWorker(broker *fakeBroker, process processorFunc, shutdownTimeout time.Duration) *WorkerService {
+	log := logger.NewLogger(&logger.Config{Level: "error"})
+	return NewWorkerService(&Container{
+		Config: &config.Config{
+			WorkerConcurrency:     1,
+			SchedulerPollInterval: time.Hour,
+			ShutdownTimeout:       shutdownTimeout,
+		},
+		Logger:                log,
+		MessagingService:      broker,
+		EmailProcessorUseCase: process,
+		RetryHandlerUseCase:   &fakeRetryHandler{},
+		SchedulerUseCase:      &fakeScheduler{},
+		HealthHandler:         handlers.NewHealthHandler(nil, nil, nil, log, 1),
+	})
+}
+
+func TestHandleEmailJobSettlement(t *testing.T) {
+	failure := errors.NewSMTPError("connection refused")
+
+	tests := []struct {
+		name        string
+		processErr  error
+		retryErr    error
+		scheduleErr error
+		later       bool
+		stopped     bool
+		want        messaging.DeliveryOutcome
+	}{
+		{name: "sent", want: messaging.DeliveryAck},
+		{name: "retry scheduled", processErr: failure, want: messaging.DeliveryAck},
+		{name: "failed for good", processErr: failure, retryErr: errors.NewRetryExceededError("job-1", 3), want: messaging.DeliveryAck},
+		{name: "retry not published", processErr: failure, retryErr: errors.NewRabbitMQError("broker rejected published job"), want: messaging.DeliveryRequeue},
+		{name: "parked for later", later: true, want: messaging.DeliveryAck},
+		{name: "not parked", later: true, scheduleErr: errors.NewRedisError("connection refused"), want: messaging.DeliveryRequeue},
+		{name: "worker stopped", stopped: true, want: messaging.DeliveryRequeue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorker(newFakeBroker(), func(ctx context.Context, job *models.EmailJob) error {
+				return tt.processErr
+			}, time.Second)
+			w.container.RetryHandlerUseCase = &fakeRetryHandler{err: tt.retryErr}
+			w.container.SchedulerUseCase = &fakeScheduler{err: tt.scheduleErr}
+			w.isRunning.Store(!tt.stopped)
+
+			job := models.NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+			if tt.later {
+				sendAt := time.Now().Add(time.Hour)
+				job.SendAt = &sendAt
+			}
+
+			if got := w.handleEmailJob(context.Background(), job); got != tt.want {
+				t.Errorf("handleEmailJob settled with %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopLetsInFlightJobsFinish(t *testing.T) {
+	broker := newFakeBroker()
+	started, release := make(chan struct{}), make(chan struct{})
+	w := newTestWorker(broker, func(ctx context.Context, job *models.EmailJob) error {
+		close(started)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, time.Hour)
+
+	go w.Start(context.Background())
+	broker.deliveries <- models.NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a job was still being sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-stopped
+	if outcome, ok := broker.Outcome("job-1"); !ok || outcome != messaging.DeliveryAck {
+		t.Errorf("in-flight job settled with %v (%v), want it acked", outcome, ok)
+	}
+}
+
+func TestStopRequeuesJobsPastShutdownTimeout(t *testing.T) {
+	broker := newFakeBroker()
+	started := make(chan struct{})
+	w := newTestWorker(broker, func(ctx context.Context, job *models.EmailJob) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, 20*time.Millisecond)
+
+	go w.Start(context.Background())
+	broker.deliveries <- models.NewEmailJob("job-1", "jane@example.com", "Hello", "Body text", 3)
+	<-started
+
+	w.Stop()
+	if outcome, ok := broker.Outcome("job-1"); !ok || outcome != messaging.DeliveryRequeue {
+		t.Errorf("job cut off by the shutdown timeout settled with %v (%v), want it requeued", outcome, ok)
+	}
+}